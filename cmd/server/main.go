@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,10 +15,16 @@ import (
 	"mathsvg/internal/renderer"
 	"mathsvg/internal/server"
 
+	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
 
 func main() {
+	// 以渲染 worker 子进程身份启动时只负责渲染，不加载配置与 HTTP 服务
+	if library, ok := os.LookupEnv(renderer.WorkerEnv); ok {
+		os.Exit(renderer.RunWorker(library))
+	}
+
 	bootTime := time.Now()
 
 	// 加载配置，确保不同环境都能读取统一的服务参数
@@ -40,22 +47,64 @@ func main() {
 	}
 	defer func() { _ = cacheManager.Close() }()
 
-	// 优先尝试加载 Rust 渲染器，如失败则降级为占位实现
-	rendererImpl, err := renderer.NewFFIRenderer()
-	if err != nil {
-		logger.Warn("Rust 渲染器初始化失败，降级为占位实现", zap.Error(err))
-		rendererImpl = renderer.NewStub()
+	// 优先尝试加载 Rust 渲染器，如失败则降级为占位实现；之后可通过文件监听或管理接口热更新
+	rendererImpl := renderer.NewHotSwap(cfg.Renderer, logger)
+	defer func() { _ = rendererImpl.Close() }()
+	if err := rendererImpl.Reload(""); err != nil {
+		logger.Warn("Rust 渲染器初始化失败，降级为占位实现",
+			zap.String("library_path", cfg.Renderer.LibraryPath),
+			zap.Error(err),
+		)
 	} else {
-		logger.Info("Rust 渲染器初始化成功，启用真实渲染")
+		logger.Info("Rust 渲染器初始化成功，启用真实渲染",
+			zap.String("library_path", cfg.Renderer.LibraryPath),
+			zap.String("mode", cfg.Renderer.Mode),
+		)
 	}
 
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if cfg.Renderer.WatchLibrary && cfg.Renderer.LibraryPath != "" {
+		if err := rendererImpl.Watch(watchCtx); err != nil {
+			logger.Warn("共享库监听启动失败，仅支持手动热更新", zap.Error(err))
+		}
+	}
+
+	// 限制同时进行的渲染数量，超出队列上限时直接拒绝，避免拖垮健康检查等轻量请求
+	scheduler := renderer.NewScheduler(rendererImpl, cfg.Renderer)
+
 	// 将渲染逻辑封装到统一的 Handler 中，方便后续扩展监控与鉴权
-	renderHandler := api.NewRenderHandler(cacheManager, rendererImpl, logger, cfg.Server.RequestTimeout)
-	healthHandler := api.NewHealthHandler(cacheManager, logger, bootTime)
+	renderHandler := api.NewRenderHandler(cacheManager, scheduler, logger, cfg.Server, cfg.Render)
+	healthHandler := api.NewHealthHandler(cacheManager, rendererImpl, scheduler, logger, bootTime)
+
+	// 在开始接收流量前恢复上次停机时保存的热点缓存；prefork 子进程会被主进程直接结束，无法保存快照
+	snapshotEnabled := cfg.Cache.SnapshotPath != "" && !cfg.Server.Prefork
+	if cfg.Cache.SnapshotPath != "" && cfg.Server.Prefork && !fiber.IsChild() {
+		logger.Warn("prefork 模式下不支持本地缓存快照，已忽略 cache.snapshot_path")
+	}
+	if snapshotEnabled {
+		restoreSnapshot(cacheManager, renderHandler, cfg.Cache.SnapshotPath, logger)
+	}
 
 	// 构建 HTTP 服务，里面会自动挂载路由、中间件等组件
 	httpServer := server.NewHTTPServer(cfg.Server, logger, renderHandler, healthHandler)
 
+	// 管理接口使用独立监听；prefork 时只在主进程启动，子进程的本地缓存由失效频道同步
+	var adminServer *server.AdminServer
+	if cfg.Admin.Token != "" && !fiber.IsChild() {
+		if cfg.Server.Prefork && !cacheManager.Propagates() {
+			logger.Warn("prefork 模式下未启用 Redis 失效频道，管理接口的缓存操作无法同步到子进程，将返回 409")
+		}
+		reloadHandler := newReloadHandler(cfg.Server, cfg.Renderer, rendererImpl, logger)
+		adminHandler := api.NewAdminHandler(renderHandler, cacheManager, reloadHandler, cfg.Server.Prefork, cfg.Admin, logger)
+		adminServer = server.NewAdminServer(cfg.Admin, logger, adminHandler)
+		go func() {
+			if err := adminServer.Start(); err != nil {
+				logger.Error("管理服务启动失败", zap.Error(err))
+			}
+		}()
+	}
+
 	// 采用独立协程启动服务，主协程负责监听退出信号
 	go func() {
 		if err := httpServer.Start(); err != nil {
@@ -72,8 +121,18 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
-		logger.Error("优雅停机失败", zap.Error(err))
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Warn("管理服务停止失败", zap.Error(err))
+		}
+	}
+	shutdownErr := httpServer.Shutdown(ctx)
+	// 请求已全部结束，此时的命中统计最完整；停机失败也尽量保存
+	if snapshotEnabled {
+		saveSnapshot(cacheManager, cfg.Cache, logger)
+	}
+	if shutdownErr != nil {
+		logger.Error("优雅停机失败", zap.Error(shutdownErr))
 		return
 	}
 
@@ -81,3 +140,50 @@ func main() {
 	time.Sleep(200 * time.Millisecond)
 	logger.Info("服务已安全退出")
 }
+
+// restoreSnapshot 将快照写回本地缓存，文件不存在视为首次启动；失败只记录日志，不影响启动
+func restoreSnapshot(cacheManager *cache.Manager, renderHandler *api.RenderHandler, path string, logger *zap.Logger) {
+	started := time.Now()
+	restored, skipped, err := cacheManager.RestoreSnapshot(context.Background(), path, renderHandler.SnapshotFilter())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logger.Info("未找到本地缓存快照，跳过恢复", zap.String("path", path))
+	case err != nil:
+		logger.Warn("本地缓存快照恢复失败", zap.String("path", path), zap.Error(err))
+	default:
+		logger.Info("本地缓存快照已恢复",
+			zap.String("path", path),
+			zap.Int("restored", restored),
+			zap.Int("skipped", skipped),
+			zap.Duration("duration", time.Since(started)),
+		)
+	}
+}
+
+// saveSnapshot 在停机时保存本地缓存中的热点条目
+func saveSnapshot(cacheManager *cache.Manager, cfg config.Cache, logger *zap.Logger) {
+	started := time.Now()
+	saved, err := cacheManager.SaveSnapshot(cfg.SnapshotPath, cfg.SnapshotMaxEntries, int64(cfg.SnapshotMaxMB)<<20)
+	if err != nil {
+		logger.Warn("本地缓存快照保存失败", zap.String("path", cfg.SnapshotPath), zap.Error(err))
+		return
+	}
+	logger.Info("本地缓存快照已保存",
+		zap.String("path", cfg.SnapshotPath),
+		zap.Int("entries", saved),
+		zap.Duration("duration", time.Since(started)),
+	)
+}
+
+// newReloadHandler 构建渲染库热更新接口。prefork 时管理接口运行在主进程，
+// 需借助各子进程的共享库监听完成重载，未开启监听时不挂载该接口
+func newReloadHandler(serverCfg config.Server, rendererCfg config.Renderer, swap *renderer.HotSwap, logger *zap.Logger) *api.ReloadHandler {
+	if !serverCfg.Prefork {
+		return api.NewReloadHandler(swap, false, logger)
+	}
+	if !rendererCfg.WatchLibrary || rendererCfg.LibraryPath == "" {
+		logger.Warn("prefork 模式下渲染库热更新接口依赖 renderer.watch_library 通知各子进程，已禁用该接口")
+		return nil
+	}
+	return api.NewReloadHandler(swap, true, logger)
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/svgutil"
+)
+
+var (
+	ErrMissingTarget = errors.New("需要指定 key、tex 或 all")
+	ErrJobNotFound   = errors.New("预热任务不存在")
+	// ErrCacheNotShared 表示 prefork 下缓存无法同步到子进程，管理进程的缓存操作对线上请求无效
+	ErrCacheNotShared = errors.New("prefork 模式下缓存管理需要启用 Redis 与 cache.invalidation_enabled")
+)
+
+// adminTimeout 为单次管理操作访问缓存的超时时间
+const adminTimeout = 5 * time.Second
+
+// purgeRequest 对应 POST /admin/cache/purge：key 为缓存键，tex 连同选项按渲染接口的规则计算缓存键，
+// all 为 true 时清空全部缓存
+type purgeRequest struct {
+	renderRequest
+	Key string `json:"key"`
+	All bool   `json:"all"`
+}
+
+// entryMeta 是缓存值中记录的元数据，不含渲染结果本身
+type entryMeta struct {
+	RendererVersion string           `json:"renderer_version,omitempty"`
+	Options         string           `json:"options,omitempty"`
+	Encoding        string           `json:"encoding,omitempty"`
+	CreatedAt       *time.Time       `json:"created_at,omitempty"`
+	RenderMS        float64          `json:"render_ms,omitempty"`
+	Metrics         *svgutil.Metrics `json:"metrics,omitempty"`
+	// Unrecognized 表示缓存值格式无法识别，渲染接口会按未命中处理
+	Unrecognized bool `json:"unrecognized,omitempty"`
+}
+
+// AdminHandler 提供缓存查询、清除与预热的管理接口，挂载在独立的监听地址上；
+// 所有操作及鉴权失败都记录审计日志
+type AdminHandler struct {
+	render *RenderHandler
+	cache  *cache.Manager
+	// reload 为空时不挂载渲染库热更新接口
+	reload *ReloadHandler
+	// localOnly 为 true 时（prefork 且缓存无法同步到子进程）拒绝所有缓存接口
+	localOnly bool
+	token     string
+	audit     *zap.Logger
+
+	maxBodyBytes int
+	warmups      *warmupRegistry
+}
+
+// NewAdminHandler 构建管理处理器，cfg.Token 为空时不应挂载；reload 可为 nil。
+// prefork 时管理接口运行在主进程，主进程的缓存只有经失效频道才能影响子进程，否则缓存接口一律返回 409
+func NewAdminHandler(render *RenderHandler, cache *cache.Manager, reload *ReloadHandler, prefork bool, cfg config.Admin, logger *zap.Logger) *AdminHandler {
+	audit := logger.Named("audit")
+	return &AdminHandler{
+		render:       render,
+		cache:        cache,
+		reload:       reload,
+		localOnly:    prefork && !cache.Propagates(),
+		token:        cfg.Token,
+		audit:        audit,
+		maxBodyBytes: cfg.MaxRequestBodyMB * 1024 * 1024,
+		warmups:      newWarmupRegistry(render, cfg.WarmupMaxItems, cfg.WarmupWorkers, audit),
+	}
+}
+
+// Register 将管理接口挂载到路由上，所有接口都需要 Bearer 令牌
+func (h *AdminHandler) Register(router fiber.Router) {
+	admin := router.Group("/admin", h.authenticate)
+	if h.reload != nil {
+		h.reload.Register(admin)
+	}
+	group := admin.Group("/cache", h.requireShared)
+	group.Get("/entry", h.handleLookup)
+	group.Post("/purge", h.handlePurge)
+	group.Post("/warmup", h.handleWarmup)
+	group.Get("/warmup", h.handleWarmupList)
+	group.Get("/warmup/:id", h.handleWarmupStatus)
+	group.Delete("/warmup/:id", h.handleWarmupCancel)
+}
+
+// Close 取消所有进行中的预热任务
+func (h *AdminHandler) Close() {
+	h.warmups.cancelAll()
+}
+
+// authenticate 校验 Authorization: Bearer <token>，使用常量时间比较
+func (h *AdminHandler) authenticate(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if ok && h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1 {
+		return c.Next()
+	}
+	requestID := requestIDFromCtx(c)
+	h.audit.Warn("管理接口鉴权失败",
+		zap.String("request_id", requestID),
+		zap.String("ip", c.IP()),
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+	)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"request_id": requestID, "error": "未授权"})
+}
+
+// requireShared 在缓存操作无法作用于处理请求的子进程时拒绝，避免返回看似成功的结果
+func (h *AdminHandler) requireShared(c *fiber.Ctx) error {
+	if h.localOnly {
+		return h.reject(c, "cache", ErrCacheNotShared)
+	}
+	return c.Next()
+}
+
+// handleLookup 查询缓存键在各层中的状态：?key= 直接指定缓存键，或以 ?tex= 加渲染选项计算
+func (h *AdminHandler) handleLookup(c *fiber.Ctx) error {
+	requestID := requestIDFromCtx(c)
+	key := strings.Clone(c.Query("key"))
+	if key == "" {
+		var err error
+		if key, err = h.keyFromQuery(c); err != nil {
+			return h.reject(c, "lookup", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+	namespaced, tiers := h.cache.Inspect(ctx, key)
+
+	response := fiber.Map{
+		"request_id":     requestID,
+		"key":            key,
+		"namespaced_key": namespaced,
+		"generation":     h.cache.Generation(),
+		"tiers":          tiers,
+	}
+	for _, tier := range tiers {
+		if tier.Present {
+			response["entry"] = describeEntry(decodeEntry(string(tier.Value)))
+			break
+		}
+	}
+
+	h.audit.Info("管理操作",
+		zap.String("action", "lookup"),
+		zap.String("request_id", requestID),
+		zap.String("ip", c.IP()),
+		zap.String("key", key),
+		zap.Bool("found", response["entry"] != nil),
+	)
+	return c.JSON(response)
+}
+
+// handlePurge 按缓存键、公式或全部清除缓存，其他实例通过失效频道同步逐出本地副本
+func (h *AdminHandler) handlePurge(c *fiber.Ctx) error {
+	requestID := requestIDFromCtx(c)
+	var req purgeRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.reject(c, "purge", ErrInvalidBody)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	if req.All {
+		generation, err := h.cache.PurgeAll(ctx)
+		h.audit.Info("管理操作",
+			zap.String("action", "purge_all"),
+			zap.String("request_id", requestID),
+			zap.String("ip", c.IP()),
+			zap.Uint64("generation", generation),
+			zap.Error(err),
+		)
+		if err != nil {
+			return h.cacheFailure(c, err)
+		}
+		return c.JSON(fiber.Map{"request_id": requestID, "purged": "all", "generation": generation})
+	}
+
+	key := req.Key
+	if key == "" {
+		if req.Tex == "" {
+			return h.reject(c, "purge", ErrMissingTarget)
+		}
+		var err error
+		if key, err = h.keyFor(req.Tex, req.options()); err != nil {
+			return h.reject(c, "purge", err)
+		}
+	}
+
+	err := h.cache.Delete(ctx, key)
+	h.audit.Info("管理操作",
+		zap.String("action", "purge"),
+		zap.String("request_id", requestID),
+		zap.String("ip", c.IP()),
+		zap.String("key", key),
+		zap.Error(err),
+	)
+	if err != nil {
+		return h.cacheFailure(c, err)
+	}
+	return c.JSON(fiber.Map{"request_id": requestID, "purged": key})
+}
+
+// keyFromQuery 以查询参数中的公式与选项计算缓存键
+func (h *AdminHandler) keyFromQuery(c *fiber.Ctx) (string, error) {
+	tex := c.Query("tex")
+	if tex == "" {
+		return "", ErrMissingTarget
+	}
+	opts, err := optionsFromQuery(c)
+	if err != nil {
+		return "", err
+	}
+	return h.keyFor(tex, opts)
+}
+
+// keyFor 按渲染接口相同的规则补齐选项并计算缓存键，不含命名空间代数
+func (h *AdminHandler) keyFor(tex string, opts renderOptions) (string, error) {
+	opts, err := h.render.withDefaults(opts).normalize()
+	if err != nil {
+		return "", err
+	}
+	normalized, err := validateFormula(tex)
+	if err != nil {
+		return "", err
+	}
+	key, _ := h.render.cacheKey(normalized, opts)
+	return strings.Clone(key), nil
+}
+
+// reject 返回结构化错误并记录被拒绝的操作
+func (h *AdminHandler) reject(c *fiber.Ctx, action string, err error) error {
+	resp := describeError(err, "", requestIDFromCtx(c))
+	h.audit.Warn("管理操作被拒绝",
+		zap.String("action", action),
+		zap.String("request_id", resp.RequestID),
+		zap.String("ip", c.IP()),
+		zap.String("code", resp.Code),
+		zap.Error(err),
+	)
+	return c.Status(resp.status).JSON(resp)
+}
+
+// cacheFailure 在缓存层（通常是 Redis）不可用时返回 503
+func (h *AdminHandler) cacheFailure(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(errorResponse{
+		Code:      CodeCacheUnavailable,
+		Message:   err.Error(),
+		RequestID: requestIDFromCtx(c),
+	})
+}
+
+// describeEntry 提取缓存值的元数据，ok 为 decodeEntry 的解析结果
+func describeEntry(entry renderEntry, ok bool) entryMeta {
+	if !ok {
+		return entryMeta{Unrecognized: true}
+	}
+	meta := entryMeta{
+		RendererVersion: entry.RendererVersion,
+		Options:         entry.Options,
+		Encoding:        entry.Encoding,
+		RenderMS:        float64(entry.RenderDuration.Microseconds()) / 1000.0,
+	}
+	if !entry.CreatedAt.IsZero() {
+		meta.CreatedAt = &entry.CreatedAt
+	}
+	if entry.HasMetrics {
+		meta.Metrics = &entry.Metrics
+	}
+	return meta
+}
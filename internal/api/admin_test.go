@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/pkg/redistest"
+	"mathsvg/internal/renderer"
+)
+
+func newAdminTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	manager := cache.NewManagerWithTiers(config.Cache{}, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{})}, zap.NewNop())
+	t.Cleanup(func() { _ = manager.Close() })
+	return newAdminTestAppWith(t, manager, false)
+}
+
+func newAdminTestAppWith(t *testing.T, manager *cache.Manager, prefork bool) *fiber.App {
+	t.Helper()
+	render := NewRenderHandler(manager, flakyRenderer{stub: renderer.NewStub()}, zap.NewNop(), config.Server{
+		RequestTimeout: 200 * time.Millisecond,
+	}, config.Render{SpeechLang: "en"})
+	admin := NewAdminHandler(render, manager, nil, prefork, config.Admin{Token: "secret", WarmupMaxItems: 10, WarmupWorkers: 2}, zap.NewNop())
+	t.Cleanup(admin.Close)
+
+	app := fiber.New()
+	render.Register(app)
+	admin.Register(app)
+	return app
+}
+
+func adminRequest(t *testing.T, app *fiber.App, method, target, contentType, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer secret")
+	if contentType != "" {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("响应无法解析: %v %s", err, raw)
+	}
+	return resp.StatusCode, out
+}
+
+func TestAdminHandler_Unauthorized(t *testing.T) {
+	app := newAdminTestApp(t)
+	req := httptest.NewRequest(fiber.MethodPost, "/admin/cache/purge", strings.NewReader(`{"all":true}`))
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer wrong")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("令牌错误应返回 401，实际: %d", resp.StatusCode)
+	}
+}
+
+func TestAdminHandler_LookupAndPurge(t *testing.T) {
+	app := newAdminTestApp(t)
+	lookup := "/admin/cache/entry?tex=" + url.QueryEscape("x+y") + "&display=block"
+
+	if _, out := adminRequest(t, app, fiber.MethodGet, lookup, "", ""); out["entry"] != nil {
+		t.Fatalf("未渲染前不应有缓存条目: %v", out)
+	}
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/render?tex=x%2By&display=block", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("渲染失败: %v", err)
+	}
+
+	status, out := adminRequest(t, app, fiber.MethodGet, lookup, "", "")
+	tiers, _ := out["tiers"].([]any)
+	if status != fiber.StatusOK || out["entry"] == nil || len(tiers) != 1 || !tiers[0].(map[string]any)["present"].(bool) {
+		t.Fatalf("渲染后应能查到各层的缓存条目: %v", out)
+	}
+	key := out["key"].(string)
+	if _, byKey := adminRequest(t, app, fiber.MethodGet, "/admin/cache/entry?key="+url.QueryEscape(key), "", ""); byKey["entry"] == nil {
+		t.Fatalf("按缓存键也应能查到条目: %v", byKey)
+	}
+
+	if status, _ := adminRequest(t, app, fiber.MethodPost, "/admin/cache/purge", "", `{"tex":"x+y","display":"block"}`); status != fiber.StatusOK {
+		t.Fatalf("按公式清除失败: %d", status)
+	}
+	if _, out := adminRequest(t, app, fiber.MethodGet, lookup, "", ""); out["entry"] != nil {
+		t.Fatalf("清除后不应再有缓存条目: %v", out)
+	}
+
+	status, out = adminRequest(t, app, fiber.MethodPost, "/admin/cache/purge", "", `{"all":true}`)
+	if status != fiber.StatusOK || out["generation"].(float64) != 1 {
+		t.Fatalf("清空全部缓存应提升命名空间代数: %d %v", status, out)
+	}
+	if status, out := adminRequest(t, app, fiber.MethodPost, "/admin/cache/purge", "", `{}`); status != fiber.StatusBadRequest || out["code"] != CodeMissingTarget {
+		t.Fatalf("未指定清除目标应返回 400: %d %v", status, out)
+	}
+}
+
+func TestAdminHandler_Warmup(t *testing.T) {
+	app := newAdminTestApp(t)
+	status, out := adminRequest(t, app, fiber.MethodPost, "/admin/cache/warmup", fiber.MIMETextPlain, "a+b\n\nfail\nc^2\n")
+	if status != fiber.StatusAccepted {
+		t.Fatalf("预热应异步受理，实际: %d %v", status, out)
+	}
+	id := out["job"].(map[string]any)["id"].(string)
+
+	job := waitWarmup(t, app, id)
+	if job["total"].(float64) != 3 || job["rendered"].(float64) != 2 || job["failed"].(float64) != 1 || len(job["errors"].([]any)) != 1 {
+		t.Fatalf("预热进度不符合预期: %v", job)
+	}
+
+	_, out = adminRequest(t, app, fiber.MethodPost, "/admin/cache/warmup", fiber.MIMEApplicationJSON, `{"items":[{"tex":"a+b"},{"tex":"c^2","display":"block"}]}`)
+	job = waitWarmup(t, app, out["job"].(map[string]any)["id"].(string))
+	if job["cached"].(float64) != 1 || job["rendered"].(float64) != 1 {
+		t.Fatalf("已缓存的公式应跳过渲染: %v", job)
+	}
+
+	if status, _ := adminRequest(t, app, fiber.MethodGet, "/admin/cache/warmup/unknown", "", ""); status != fiber.StatusNotFound {
+		t.Fatalf("未知任务应返回 404，实际: %d", status)
+	}
+	if _, out := adminRequest(t, app, fiber.MethodGet, "/admin/cache/warmup", "", ""); len(out["jobs"].([]any)) != 2 {
+		t.Fatalf("应列出全部预热任务: %v", out)
+	}
+}
+
+func waitWarmup(t *testing.T, app *fiber.App, id string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, out := adminRequest(t, app, fiber.MethodGet, "/admin/cache/warmup/"+id, "", "")
+		job := out["job"].(map[string]any)
+		if job["status"] != warmupRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待预热完成超时: %v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAdminHandler_PreforkRequiresSharedCache(t *testing.T) {
+	local := cache.NewManagerWithTiers(config.Cache{}, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{})}, zap.NewNop())
+	t.Cleanup(func() { _ = local.Close() })
+	app := newAdminTestAppWith(t, local, true)
+
+	// 主进程的本地缓存不参与服务，缓存操作应明确失败而不是返回 200
+	for _, tc := range []struct{ method, target, body string }{
+		{fiber.MethodGet, "/admin/cache/entry?key=k", ""},
+		{fiber.MethodPost, "/admin/cache/purge", `{"all":true}`},
+		{fiber.MethodPost, "/admin/cache/warmup", "x+y"},
+	} {
+		status, out := adminRequest(t, app, tc.method, tc.target, "text/plain", tc.body)
+		if status != fiber.StatusConflict || out["code"] != CodeCacheNotShared {
+			t.Fatalf("%s %s 应返回 409: %d %v", tc.method, tc.target, status, out)
+		}
+	}
+	if local.Generation() != 0 {
+		t.Fatal("被拒绝的清空操作不应提升代数")
+	}
+
+	server, err := redistest.Start("")
+	if err != nil {
+		t.Fatalf("启动 Redis 替身失败: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	cfg := config.Cache{
+		RedisAddress:             server.Addr(),
+		RedisDialTimeout:         200 * time.Millisecond,
+		RedisReadTimeout:         time.Second,
+		RedisWriteTimeout:        time.Second,
+		RedisBreakerThreshold:    3,
+		RedisReconnectMinBackoff: time.Hour,
+		RedisReconnectMaxBackoff: time.Hour,
+		InvalidationEnabled:      true,
+		InvalidationChannel:      "mathsvg:test",
+	}
+	redisTier, err := cache.NewRedisTier(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("创建 Redis 缓存层失败: %v", err)
+	}
+	shared := cache.NewManagerWithTiers(cfg, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{}), redisTier}, zap.NewNop())
+	t.Cleanup(func() { _ = shared.Close() })
+	app = newAdminTestAppWith(t, shared, true)
+	if status, out := adminRequest(t, app, fiber.MethodGet, "/admin/cache/entry?key=k", "", ""); status != fiber.StatusOK {
+		t.Fatalf("启用失效频道后应允许缓存操作: %d %v", status, out)
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/svgutil"
+)
+
+// batchRequest 对应 POST /render/batch 的请求体，每一项可携带独立选项
+type batchRequest struct {
+	Items []renderRequest `json:"items"`
+}
+
+// batchItemResult 描述单个公式的处理结果，成功时携带 SVG，失败时携带错误信息
+type batchItemResult struct {
+	Index         int              `json:"index"`
+	SVG           string           `json:"svg,omitempty"`
+	PNG           []byte           `json:"png,omitempty"`
+	Metrics       *svgutil.Metrics `json:"metrics,omitempty"`
+	MathML        string           `json:"mathml,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Code          string           `json:"code,omitempty"`
+	Position      *errorPosition   `json:"position,omitempty"`
+	Status        int              `json:"status"`
+	CacheHitLevel cache.HitLevel   `json:"cache_hit_level,omitempty"`
+	RenderMS      float64          `json:"render_ms"`
+}
+
+// batchJob 表示一组缓存键相同、需要实际渲染的条目
+type batchJob struct {
+	key        string
+	version    string
+	normalized string
+	opts       renderOptions
+	indexes    []int
+}
+
+// handleBatch 批量渲染整页公式：先逐条查缓存，再以有限并发渲染未命中的部分
+func (h *RenderHandler) handleBatch(c *fiber.Ctx) error {
+	start := time.Now()
+	requestID := requestIDFromCtx(c)
+	log := h.logger.With(zap.String("request_id", requestID))
+
+	body := c.Body()
+	if h.maxBodyBytes > 0 && len(body) > h.maxBodyBytes {
+		return h.rejectBatch(c, ErrBodyTooLarge)
+	}
+
+	var req batchRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return h.rejectBatch(c, ErrInvalidBody)
+	}
+	if len(req.Items) == 0 {
+		return h.rejectBatch(c, ErrEmptyBatch)
+	}
+	if h.batchMaxItems > 0 && len(req.Items) > h.batchMaxItems {
+		return h.rejectBatch(c, ErrTooManyItems)
+	}
+
+	results := make([]batchItemResult, len(req.Items))
+	jobs := make(map[string]*batchJob)
+	var pending []*batchJob
+
+	// 第一阶段：校验并查询缓存，同一批次内重复的公式只渲染一次
+	for i, item := range req.Items {
+		results[i] = batchItemResult{Index: i}
+
+		opts, err := h.withDefaults(item.options()).normalize()
+		normalized := ""
+		if err == nil {
+			normalized, err = validateFormula(item.Tex)
+		}
+		if err != nil {
+			results[i].setError(describeError(err, item.Tex, ""))
+			continue
+		}
+
+		key, version := h.cacheKey(normalized, opts)
+		if job, ok := jobs[key]; ok {
+			job.indexes = append(job.indexes, i)
+			continue
+		}
+
+		lookupCtx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+		entry, hitLevel := h.lookup(lookupCtx, key, version, "")
+		cancel()
+		if hitLevel.Stale() {
+			h.revalidate(key, version, normalized, opts)
+		}
+		if hitLevel != cache.HitNone {
+			h.remember(key, version, normalized, opts)
+			h.fillBatchResult(&results[i], opts, entry)
+			results[i].Status = fiber.StatusOK
+			results[i].CacheHitLevel = hitLevel
+			continue
+		}
+
+		job := &batchJob{key: key, version: version, normalized: normalized, opts: opts, indexes: []int{i}}
+		jobs[key] = job
+		pending = append(pending, job)
+	}
+
+	// 第二阶段：固定数量的 worker 并发渲染未命中的公式
+	h.runBatchJobs(pending, results, log)
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Error == "" {
+			succeeded++
+		}
+	}
+
+	log.Info("批量渲染完成",
+		zap.Float64("request_duration_ms", float64(time.Since(start).Microseconds())/1000.0),
+		zap.Int("items", len(results)),
+		zap.Int("rendered", len(pending)),
+		zap.Int("failed", len(results)-succeeded),
+	)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"request_id": requestID,
+		"items":      results,
+		"succeeded":  succeeded,
+		"failed":     len(results) - succeeded,
+	})
+}
+
+// runBatchJobs 以 batchWorkers 为上限并发渲染，结果按原始下标回填
+func (h *RenderHandler) runBatchJobs(pending []*batchJob, results []batchItemResult, log *zap.Logger) {
+	workers := h.batchWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	queue := make(chan *batchJob)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				h.renderBatchJob(job, results, log)
+			}
+		}()
+	}
+	for _, job := range pending {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// renderBatchJob 渲染单个任务；各任务写入互不重叠的下标，无需加锁
+func (h *RenderHandler) renderBatchJob(job *batchJob, results []batchItemResult, log *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	defer cancel()
+
+	res, _ := h.renderShared(ctx, job.key, job.version, job.normalized, job.opts)
+	entry, err := res.entry, res.err
+	renderMS := float64(res.renderDuration.Microseconds()) / 1000.0
+	if err != nil {
+		log.Warn("批量渲染单项失败", zap.Ints("indexes", job.indexes), zap.Error(err))
+	}
+
+	for _, i := range job.indexes {
+		results[i].RenderMS = renderMS
+		results[i].CacheHitLevel = cache.HitNone
+		if err != nil {
+			results[i].setError(describeError(err, job.normalized, ""))
+			continue
+		}
+		h.fillBatchResult(&results[i], job.opts, entry)
+		results[i].Status = fiber.StatusOK
+	}
+	if err == nil {
+		h.remember(job.key, job.version, job.normalized, job.opts)
+	}
+}
+
+// setError 将结构化错误写入单项结果
+func (r *batchItemResult) setError(resp errorResponse) {
+	r.Error = resp.Message
+	r.Code = resp.Code
+	r.Position = resp.Position
+	r.Status = resp.status
+}
+
+// fillBatchResult 按输出格式填充结果字段，PNG 在 JSON 中以 base64 表示
+func (h *RenderHandler) fillBatchResult(r *batchItemResult, opts renderOptions, entry renderEntry) {
+	switch opts.Format {
+	case formatPNG:
+		r.PNG = []byte(entry.Body)
+	case formatMathML:
+		r.MathML = entry.Body
+	case formatJSON:
+		metrics := h.metricsOf(entry, opts)
+		r.SVG = entry.Body
+		r.Metrics = &metrics
+	default:
+		r.SVG = entry.Body
+	}
+}
+
+// rejectBatch 批量接口面向程序调用，整体性错误直接返回 JSON
+func (h *RenderHandler) rejectBatch(c *fiber.Ctx, err error) error {
+	requestID := requestIDFromCtx(c)
+	h.logger.Warn("批量请求不合法", zap.String("request_id", requestID), zap.Error(err))
+	resp := describeError(err, "", requestID)
+	return c.Status(resp.status).JSON(resp)
+}
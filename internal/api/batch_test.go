@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/renderer"
+)
+
+// flakyRenderer 对包含 fail 的公式返回错误，对 \bad 返回带位置的渲染错误，
+// 对 hang 一直阻塞到超时，其余交给占位渲染器
+type flakyRenderer struct {
+	stub *renderer.Stub
+}
+
+func (r flakyRenderer) Render(ctx context.Context, tex string) (string, error) {
+	if strings.Contains(tex, "fail") {
+		return "", errors.New("模拟渲染失败")
+	}
+	if strings.Contains(tex, "hang") {
+		<-ctx.Done()
+		return "", fmt.Errorf("%w: %w", renderer.ErrRenderTimeout, ctx.Err())
+	}
+	if i := strings.Index(tex, `\bad`); i >= 0 {
+		return "", &renderer.RenderError{Message: `undefined control sequence \bad`, Line: 1, Column: utf8.RuneCountInString(tex[:i]) + 1}
+	}
+	return r.stub.Render(ctx, tex)
+}
+
+func newTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	manager := cache.NewManagerWithTiers(config.Cache{}, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{})}, zap.NewNop())
+	t.Cleanup(func() { _ = manager.Close() })
+
+	handler := NewRenderHandler(manager, flakyRenderer{stub: renderer.NewStub()}, zap.NewNop(), config.Server{
+		RequestTimeout:   200 * time.Millisecond,
+		MaxRequestBodyMB: 1,
+		BatchMaxItems:    10,
+		BatchWorkers:     2,
+	}, config.Render{SpeechLang: "en", Compression: encodingGzip})
+	app := fiber.New()
+	handler.Register(app.Group("/api/v1"))
+	return app
+}
+
+func TestHandleBatch_PartialFailure(t *testing.T) {
+	app := newTestApp(t)
+	body := `{"items":[{"tex":"a+b"},{"tex":"   "},{"tex":"fail"},{"tex":"a+b"},{"tex":"x","display":"block"}]}`
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/v1/render/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("部分失败不应影响整体状态码，实际: %d", resp.StatusCode)
+	}
+
+	raw, _ := io.ReadAll(resp.Body)
+	var out struct {
+		Items     []batchItemResult `json:"items"`
+		Succeeded int               `json:"succeeded"`
+		Failed    int               `json:"failed"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("响应无法解析: %v", err)
+	}
+	if len(out.Items) != 5 || out.Succeeded != 3 || out.Failed != 2 {
+		t.Fatalf("统计不符合预期: %s", raw)
+	}
+	for i, item := range out.Items {
+		if item.Index != i {
+			t.Fatalf("结果顺序错误: %s", raw)
+		}
+	}
+	if out.Items[1].Status != fiber.StatusBadRequest || out.Items[2].Status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("失败项状态码不符合预期: %s", raw)
+	}
+	if out.Items[0].SVG == "" || out.Items[0].SVG != out.Items[3].SVG {
+		t.Fatalf("重复公式应得到相同结果: %s", raw)
+	}
+}
+
+func TestHandleBatch_TooManyItems(t *testing.T) {
+	app := newTestApp(t)
+	body := `{"items":[` + strings.TrimSuffix(strings.Repeat(`{"tex":"x"},`, 11), ",") + `]}`
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/v1/render/batch", strings.NewReader(body))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusRequestEntityTooLarge {
+		t.Fatalf("超出数量限制应返回 413，实际: %d", resp.StatusCode)
+	}
+}
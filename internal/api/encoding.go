@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 缓存值支持的压缩编码，取值与 HTTP Content-Encoding 一致，命中时可原样返回
+const (
+	encodingNone   = "none"
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+)
+
+// compressBody 按指定编码压缩内容
+func compressBody(body, encoding string) (string, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case encodingGzip:
+		w = gzip.NewWriter(&buf)
+	case encodingBrotli:
+		w = brotli.NewWriter(&buf)
+	default:
+		return "", fmt.Errorf("不支持的压缩编码: %s", encoding)
+	}
+	if _, err := io.WriteString(w, body); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// decompressBody 还原 compressBody 的结果
+func decompressBody(data, encoding string) (string, error) {
+	var r io.Reader
+	switch encoding {
+	case encodingGzip:
+		gz, err := gzip.NewReader(strings.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		r = gz
+	case encodingBrotli:
+		r = brotli.NewReader(strings.NewReader(data))
+	default:
+		return "", fmt.Errorf("不支持的压缩编码: %s", encoding)
+	}
+	var b strings.Builder
+	if _, err := io.Copy(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受指定编码，q=0 表示明确拒绝
+func acceptsEncoding(header, encoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		accepted := qValue(params) > 0
+		switch {
+		case strings.EqualFold(name, encoding):
+			return accepted
+		case name == "*":
+			wildcard = accepted
+		}
+	}
+	return wildcard
+}
+
+// qValue 解析 q 参数，缺省为 1，无法解析时按 0 处理
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
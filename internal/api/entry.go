@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"mathsvg/internal/svgutil"
+)
+
+// entryPrefix 标记缓存值格式：其后为单行 JSON 头部记录元数据，换行后接内容
+const entryPrefix = "msv1 "
+
+// renderEntry 是写入缓存的值：渲染结果与度量信息一起保存，命中时无需再次解析
+type renderEntry struct {
+	Body       string
+	Metrics    svgutil.Metrics
+	HasMetrics bool
+
+	// Encoded 为按 Encoding 压缩后的内容，未压缩时为空。
+	// 从缓存读出且客户端可直接接收该编码时 Body 为空，需原样返回 Encoded
+	Encoded  string
+	Encoding string
+
+	// RendererVersion、Options、CreatedAt、RenderDuration 记录结果由哪个版本、以何种选项、何时生成及耗时
+	RendererVersion string
+	Options         string
+	CreatedAt       time.Time
+	RenderDuration  time.Duration
+}
+
+// entryHeader 是缓存值的头部，字段名保持简短以减少每条缓存的开销
+type entryHeader struct {
+	Encoding        string           `json:"enc,omitempty"`
+	Metrics         *svgutil.Metrics `json:"metrics,omitempty"`
+	RendererVersion string           `json:"rv,omitempty"`
+	Options         string           `json:"opts,omitempty"`
+	CreatedAtMS     int64            `json:"at,omitempty"`
+	RenderUS        int64            `json:"render_us,omitempty"`
+}
+
+// inflate 解压 Encoded 填充 Body
+func (e *renderEntry) inflate() error {
+	if e.Body != "" || e.Encoding == "" {
+		return nil
+	}
+	body, err := decompressBody(e.Encoded, e.Encoding)
+	if err != nil {
+		return err
+	}
+	e.Body = body
+	return nil
+}
+
+// encodeEntry 将元数据写成单行 JSON 头部，紧跟内容（已压缩时写入压缩后的内容）
+func encodeEntry(entry renderEntry) string {
+	header := entryHeader{
+		Encoding:        entry.Encoding,
+		RendererVersion: entry.RendererVersion,
+		Options:         entry.Options,
+		RenderUS:        entry.RenderDuration.Microseconds(),
+	}
+	if entry.HasMetrics {
+		metrics := entry.Metrics
+		header.Metrics = &metrics
+	}
+	if !entry.CreatedAt.IsZero() {
+		header.CreatedAtMS = entry.CreatedAt.UnixMilli()
+	}
+	payload := entry.Body
+	if entry.Encoding != "" {
+		payload = entry.Encoded
+	}
+
+	// 头部只含可序列化的基本类型，不会出错
+	raw, _ := json.Marshal(header)
+	var b strings.Builder
+	b.Grow(len(entryPrefix) + len(raw) + 1 + len(payload))
+	b.WriteString(entryPrefix)
+	b.Write(raw)
+	b.WriteByte('\n')
+	b.WriteString(payload)
+	return b.String()
+}
+
+// decodeEntry 解析缓存值，格式无法识别时返回 false；压缩内容不在此处解压
+func decodeEntry(raw string) (renderEntry, bool) {
+	rest, ok := strings.CutPrefix(raw, entryPrefix)
+	if !ok {
+		return renderEntry{}, false
+	}
+	header, body, found := strings.Cut(rest, "\n")
+	if !found {
+		return renderEntry{}, false
+	}
+	var h entryHeader
+	if err := json.Unmarshal([]byte(header), &h); err != nil {
+		return renderEntry{}, false
+	}
+	entry := renderEntry{
+		Body:            body,
+		RendererVersion: h.RendererVersion,
+		Options:         h.Options,
+		RenderDuration:  time.Duration(h.RenderUS) * time.Microsecond,
+	}
+	if h.Encoding != "" {
+		entry.Body, entry.Encoded, entry.Encoding = "", body, h.Encoding
+	}
+	if h.Metrics != nil {
+		entry.Metrics, entry.HasMetrics = *h.Metrics, true
+	}
+	if h.CreatedAtMS != 0 {
+		entry.CreatedAt = time.UnixMilli(h.CreatedAtMS)
+	}
+	return entry, true
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mathsvg/internal/latex"
+	"mathsvg/internal/renderer"
+	"mathsvg/internal/svgutil"
+)
+
+// 对外稳定的错误代码，调用方应依赖代码而非中文提示
+const (
+	CodeEmptyFormula        = "empty_formula"
+	CodeFormulaTooLarge     = "formula_too_large"
+	CodeInvalidCharacters   = "invalid_characters"
+	CodeInvalidBody         = "invalid_body"
+	CodeBodyTooLarge        = "body_too_large"
+	CodeInvalidOption       = "invalid_option"
+	CodeUnsupportedFormat   = "unsupported_format"
+	CodeEmptyBatch          = "empty_batch"
+	CodeTooManyItems        = "too_many_items"
+	CodeRenderFailed        = "render_failed"
+	CodeRendererNoOutput    = "renderer_no_output"
+	CodeRendererAlloc       = "renderer_alloc_failed"
+	CodeRendererUnavailable = "renderer_unavailable"
+	CodeRendererCrashed     = "renderer_crashed"
+	CodeOverloaded          = "overloaded"
+	CodeQueueTimeout        = "queue_timeout"
+	CodeRenderTimeout       = "render_timeout"
+	CodeRasterTooLarge      = "raster_too_large"
+	CodeMissingTarget       = "missing_target"
+	CodeJobNotFound         = "job_not_found"
+	CodeCacheUnavailable    = "cache_unavailable"
+	CodeCacheNotShared      = "cache_not_shared"
+)
+
+// errorSpec 描述一种错误对应的代码与 HTTP 状态码
+type errorSpec struct {
+	code   string
+	status int
+}
+
+// errorCatalog 按顺序匹配，未列出的错误统一视为渲染失败
+var errorCatalog = []struct {
+	err  error
+	spec errorSpec
+}{
+	{ErrEmptyFormula, errorSpec{CodeEmptyFormula, fiber.StatusBadRequest}},
+	{ErrFormulaTooLarge, errorSpec{CodeFormulaTooLarge, fiber.StatusRequestEntityTooLarge}},
+	{ErrInvalidCharacters, errorSpec{CodeInvalidCharacters, fiber.StatusBadRequest}},
+	{ErrInvalidBody, errorSpec{CodeInvalidBody, fiber.StatusBadRequest}},
+	{ErrBodyTooLarge, errorSpec{CodeBodyTooLarge, fiber.StatusRequestEntityTooLarge}},
+	{ErrInvalidDisplay, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidFontSize, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidColor, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidScale, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidDPI, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidBackground, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidA11y, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrInvalidLang, errorSpec{CodeInvalidOption, fiber.StatusBadRequest}},
+	{ErrUnsupportedFormat, errorSpec{CodeUnsupportedFormat, fiber.StatusBadRequest}},
+	{ErrEmptyBatch, errorSpec{CodeEmptyBatch, fiber.StatusBadRequest}},
+	{ErrTooManyItems, errorSpec{CodeTooManyItems, fiber.StatusRequestEntityTooLarge}},
+	{renderer.ErrFFINilResult, errorSpec{CodeRendererNoOutput, fiber.StatusUnprocessableEntity}},
+	{renderer.ErrFFIMallocFailed, errorSpec{CodeRendererAlloc, fiber.StatusInternalServerError}},
+	{renderer.ErrCGODisabled, errorSpec{CodeRendererUnavailable, fiber.StatusServiceUnavailable}},
+	{renderer.ErrWorkerStart, errorSpec{CodeRendererUnavailable, fiber.StatusServiceUnavailable}},
+	{renderer.ErrPoolClosed, errorSpec{CodeRendererUnavailable, fiber.StatusServiceUnavailable}},
+	{renderer.ErrWorkerCrashed, errorSpec{CodeRendererCrashed, fiber.StatusInternalServerError}},
+	{renderer.ErrWorkerProtocol, errorSpec{CodeRendererCrashed, fiber.StatusInternalServerError}},
+	{renderer.ErrOverloaded, errorSpec{CodeOverloaded, fiber.StatusServiceUnavailable}},
+	{renderer.ErrQueueTimeout, errorSpec{CodeQueueTimeout, fiber.StatusServiceUnavailable}},
+	{renderer.ErrRenderTimeout, errorSpec{CodeRenderTimeout, fiber.StatusGatewayTimeout}},
+	{context.DeadlineExceeded, errorSpec{CodeRenderTimeout, fiber.StatusGatewayTimeout}},
+	{svgutil.ErrRasterTooLarge, errorSpec{CodeRasterTooLarge, fiber.StatusUnprocessableEntity}},
+	{ErrMissingTarget, errorSpec{CodeMissingTarget, fiber.StatusBadRequest}},
+	{ErrJobNotFound, errorSpec{CodeJobNotFound, fiber.StatusNotFound}},
+	{ErrCacheNotShared, errorSpec{CodeCacheNotShared, fiber.StatusConflict}},
+}
+
+// errorPosition 指出公式中出错的位置，行列从 1 开始
+type errorPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// errorResponse 是面向程序调用方的结构化错误
+type errorResponse struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Command   string         `json:"command,omitempty"`
+	RequestID string         `json:"request_id"`
+	Position  *errorPosition `json:"position,omitempty"`
+
+	status     int
+	retryAfter time.Duration
+}
+
+// describeError 根据错误目录生成结构化错误，tex 用于定位非法字符
+func describeError(err error, tex, requestID string) errorResponse {
+	resp := errorResponse{
+		Code:      CodeRenderFailed,
+		Message:   err.Error(),
+		RequestID: requestID,
+		status:    fiber.StatusUnprocessableEntity,
+	}
+
+	var parseErr *latex.Error
+	if errors.As(err, &parseErr) {
+		resp.Code = parseErr.Code
+		resp.Message = parseErr.Message
+		resp.Command = parseErr.Command
+		resp.Position = &errorPosition{Offset: parseErr.Offset, Line: parseErr.Line, Column: parseErr.Column}
+		return resp
+	}
+
+	var renderErr *renderer.RenderError
+	if errors.As(err, &renderErr) {
+		resp.Message = renderErr.Message
+		resp.Position = sourcePosition(tex, renderErr.Line, renderErr.Column)
+		return resp
+	}
+
+	for _, entry := range errorCatalog {
+		if errors.Is(err, entry.err) {
+			resp.Code = entry.spec.code
+			resp.status = entry.spec.status
+			break
+		}
+	}
+	if errors.Is(err, ErrInvalidCharacters) {
+		resp.Position = controlCharPosition(strings.TrimSpace(tex))
+	}
+	var overload *renderer.OverloadError
+	if errors.As(err, &overload) {
+		resp.retryAfter = overload.RetryAfter
+	}
+	return resp
+}
+
+// controlCharPosition 找到第一个非法控制字符的位置
+func controlCharPosition(tex string) *errorPosition {
+	line, column := 1, 1
+	offset := 0
+	for _, r := range tex {
+		if r != '\n' && r != '\r' && r != '\t' && (r < 0x20 || r == 0x7f) {
+			return &errorPosition{Offset: offset, Line: line, Column: column}
+		}
+		offset++
+		if r == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+	return nil
+}
+
+// sourcePosition 根据渲染器报告的行列换算字符偏移，行列未知时返回 nil
+func sourcePosition(tex string, line, column int) *errorPosition {
+	if line <= 0 || column <= 0 {
+		return nil
+	}
+	offset, current := 0, 1
+	for _, r := range tex {
+		if current == line {
+			break
+		}
+		offset++
+		if r == '\n' {
+			current++
+		}
+	}
+	return &errorPosition{Offset: offset + column - 1, Line: line, Column: column}
+}
+
+// wantsJSONError 判断调用方是否期望 JSON 错误；<img> 等场景仍返回错误 SVG
+func wantsJSONError(c *fiber.Ctx, format string) bool {
+	if format == formatJSON || format == formatMathML {
+		return true
+	}
+	return c.Accepts("image/svg+xml", jsonContentType) == jsonContentType
+}
+
+// sendError 根据内容协商返回结构化 JSON 或带有真实错误信息的 SVG
+func sendError(c *fiber.Ctx, resp errorResponse, format string) error {
+	if resp.retryAfter > 0 {
+		// Retry-After 只接受整秒，不足一秒按一秒计
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(resp.retryAfter.Seconds()))))
+	}
+	if wantsJSONError(c, format) {
+		return c.Status(resp.status).JSON(resp)
+	}
+	c.Set("Content-Type", responseContentType)
+	return c.Status(resp.status).SendString(errorSVGFor(resp))
+}
+
+// errorSVGFor 将错误信息绘制到 SVG 中，宽度按字符宽度粗略估算
+func errorSVGFor(resp errorResponse) string {
+	message := resp.Message
+	if resp.Position != nil {
+		message = fmt.Sprintf("%s（第 %d 行第 %d 列）", message, resp.Position.Line, resp.Position.Column)
+	}
+	if utf8.RuneCountInString(message) > 80 {
+		message = string([]rune(message)[:80]) + "…"
+	}
+
+	width := 40
+	for _, r := range message {
+		if r < 0x80 {
+			width += 10
+		} else {
+			width += 18
+		}
+	}
+	if width < 320 {
+		width = 320
+	}
+	return fmt.Sprintf(errorSVGTemplate, width, html.EscapeString(message))
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mathsvg/internal/renderer"
+)
+
+// errFlightAborted 是执行渲染的请求异常退出（panic）时返回给等待者的错误
+var errFlightAborted = errors.New("合并的渲染异常中止")
+
+// flightResult 是一次合并渲染的结果，所有等待者共享
+type flightResult struct {
+	entry          renderEntry
+	renderDuration time.Duration
+	// peer 表示结果来自持有跨实例锁的其他实例
+	peer bool
+	err  error
+}
+
+// flightCall 表示一次进行中的渲染
+type flightCall struct {
+	done   chan struct{}
+	result flightResult
+	// waiters 为加入此次渲染的重复请求数，完成前只在持有 flightGroup.mu 时修改
+	waiters int
+}
+
+// flightGroup 按缓存键合并并发的相同渲染，只有首个请求真正执行
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do 执行或加入 key 对应的渲染，返回结果与被合并的重复请求数；
+// 等待者可因自身 ctx 结束而提前返回，不影响正在执行的渲染。fn panic 时同样移除记录并唤醒等待者，
+// 等待者得到 errFlightAborted，panic 继续向上传递
+func (g *flightGroup) do(ctx context.Context, key string, fn func() flightResult) (result flightResult, waiters int) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		call.waiters++
+		g.mu.Unlock()
+
+		select {
+		case <-call.done:
+			return call.result, call.waiters
+		case <-ctx.Done():
+			return flightResult{err: fmt.Errorf("%w: %w", renderer.ErrRenderTimeout, ctx.Err())}, 0
+		}
+	}
+
+	call := &flightCall{done: make(chan struct{}), result: flightResult{err: errFlightAborted}}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		waiters = call.waiters
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.result = fn()
+	// waiters 在 defer 中按移除记录时的值填入
+	return call.result, 0
+}
+
+// renderShared 合并本进程内的重复渲染；启用跨实例锁时，其他实例正在渲染则等待其结果
+func (h *RenderHandler) renderShared(ctx context.Context, cacheKey, version, normalized string, opts renderOptions) (flightResult, int) {
+	return h.flights.do(ctx, cacheKey, func() flightResult {
+		release, acquired := h.cache.TryLock(ctx, cacheKey)
+		if acquired {
+			defer release()
+		} else if raw, ok := h.cache.WaitForPeer(ctx, cacheKey); ok {
+			entry, ok := decodeEntry(raw)
+			if err := entry.inflate(); ok && err == nil && entry.RendererVersion == version {
+				return flightResult{entry: entry, peer: true}
+			}
+		}
+
+		entry, renderDuration, err := h.renderAndStore(ctx, cacheKey, version, normalized, opts)
+		return flightResult{entry: entry, renderDuration: renderDuration, err: err}
+	})
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/pkg/redistest"
+	"mathsvg/internal/renderer"
+)
+
+func TestFlightGroup_Coalesce(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]flightResult, 5)
+	leader := func(i int) {
+		defer wg.Done()
+		results[i], _ = g.do(context.Background(), "k", func() flightResult {
+			calls.Add(1)
+			close(started)
+			<-release
+			return flightResult{entry: renderEntry{Body: "<svg/>"}}
+		})
+	}
+	wg.Add(1)
+	go leader(0)
+	<-started
+
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = g.do(context.Background(), "k", func() flightResult {
+				calls.Add(1)
+				return flightResult{}
+			})
+		}(i)
+	}
+	// 等待所有重复请求加入后再放行
+	for {
+		g.mu.Lock()
+		waiters := g.calls["k"].waiters
+		g.mu.Unlock()
+		if waiters == len(results)-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("相同缓存键应只渲染一次，实际: %d", calls.Load())
+	}
+	for i, res := range results {
+		if res.entry.Body != "<svg/>" {
+			t.Fatalf("第 %d 个请求未得到共享结果: %+v", i, res)
+		}
+	}
+	if len(g.calls) != 0 {
+		t.Fatalf("完成后应移除进行中的记录")
+	}
+}
+
+func TestFlightGroup_WaiterTimeout(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.do(context.Background(), "k", func() flightResult {
+			close(started)
+			<-release
+			return flightResult{}
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res, _ := g.do(ctx, "k", func() flightResult { return flightResult{} })
+	if !errors.Is(res.err, renderer.ErrRenderTimeout) {
+		t.Fatalf("等待者超时应返回 ErrRenderTimeout，实际: %v", res.err)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestFlightGroup_PanicReleasesWaiters(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	started := make(chan struct{})
+	panicked := make(chan any, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		g.do(context.Background(), "k", func() flightResult {
+			close(started)
+			<-release
+			panic("渲染崩溃")
+		})
+	}()
+	<-started
+
+	waiter := make(chan flightResult, 1)
+	go func() {
+		res, _ := g.do(context.Background(), "k", func() flightResult { return flightResult{} })
+		waiter <- res
+	}()
+	for {
+		g.mu.Lock()
+		waiters := g.calls["k"].waiters
+		g.mu.Unlock()
+		if waiters == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if p := <-panicked; p == nil {
+		t.Fatal("panic 应继续向上传递")
+	}
+	if res := <-waiter; !errors.Is(res.err, errFlightAborted) {
+		t.Fatalf("等待者应得到 errFlightAborted，实际: %v", res.err)
+	}
+	if res, _ := g.do(context.Background(), "k", func() flightResult {
+		return flightResult{entry: renderEntry{Body: "<svg/>"}}
+	}); res.entry.Body != "<svg/>" {
+		t.Fatalf("panic 后同一缓存键应能重新渲染: %+v", res)
+	}
+}
+
+func TestRenderShared_WaitsForPeer(t *testing.T) {
+	server, err := redistest.Start("")
+	if err != nil {
+		t.Fatalf("启动 Redis 替身失败: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	cfg := config.Cache{
+		RedisAddress:      server.Addr(),
+		RedisDialTimeout:  200 * time.Millisecond,
+		RedisReadTimeout:  time.Second,
+		RedisWriteTimeout: time.Second,
+		RenderLockEnabled: true,
+		RenderLockTTL:     time.Second,
+		RenderLockPoll:    5 * time.Millisecond,
+	}
+	newManager := func(tiers ...cache.Tier) *cache.Manager {
+		redisTier, err := cache.NewRedisTier(cfg, zap.NewNop())
+		if err != nil {
+			t.Fatalf("创建 Redis 缓存层失败: %v", err)
+		}
+		m := cache.NewManagerWithTiers(cfg, append(tiers, redisTier), zap.NewNop())
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	}
+	local := cache.NewMemoryTier(cache.TierMemory, cache.TierPolicy{})
+	manager, peer := newManager(local), newManager()
+
+	r := &versionedRenderer{stub: renderer.NewStub(), calls: make(map[string]int)}
+	r.version.Store("")
+	h := NewRenderHandler(manager, r, zap.NewNop(), config.Server{}, config.Render{})
+
+	// 其他实例抢先持有渲染锁，稍后写入结果并释放
+	_, acquired := peer.TryLock(context.Background(), "k")
+	if !acquired {
+		t.Fatal("对方实例应获得渲染锁")
+	}
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		peer.Set(context.Background(), "k", encodeEntry(renderEntry{Body: "<svg>peer</svg>"}))
+		// Redis 替身未注册释放脚本，直接删除锁键
+		server.Del("render-lock:k")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	res, _ := h.renderShared(ctx, "k", "", "x", h.withDefaults(renderOptions{}))
+	if res.err != nil || !res.peer || res.entry.Body != "<svg>peer</svg>" {
+		t.Fatalf("未抢到锁时应等待并使用对方的结果: %+v", res)
+	}
+	if r.count("x") != 0 {
+		t.Fatalf("使用对方结果时不应再渲染，实际渲染 %d 次", r.count("x"))
+	}
+	if value, level := manager.Get(context.Background(), "k"); level != cache.HitLevel(cache.TierMemory) || value == "" {
+		t.Fatalf("对方的结果应回填本地缓存，实际: %s", level)
+	}
+}
@@ -8,21 +8,26 @@ import (
 
 	"mathsvg/internal/cache"
 	"mathsvg/internal/pkg/ctxkeys"
+	"mathsvg/internal/renderer"
 )
 
 // HealthHandler 提供基础健康检查接口
 type HealthHandler struct {
-	cache   *cache.Manager
-	logger  *zap.Logger
-	started time.Time
+	cache     *cache.Manager
+	renderer  *renderer.HotSwap
+	scheduler *renderer.Scheduler
+	logger    *zap.Logger
+	started   time.Time
 }
 
-// NewHealthHandler 构建健康检查处理器
-func NewHealthHandler(cache *cache.Manager, logger *zap.Logger, started time.Time) *HealthHandler {
+// NewHealthHandler 构建健康检查处理器，renderer、scheduler 为空时不输出对应状态
+func NewHealthHandler(cache *cache.Manager, renderer *renderer.HotSwap, scheduler *renderer.Scheduler, logger *zap.Logger, started time.Time) *HealthHandler {
 	return &HealthHandler{
-		cache:   cache,
-		logger:  logger,
-		started: started,
+		cache:     cache,
+		renderer:  renderer,
+		scheduler: scheduler,
+		logger:    logger,
+		started:   started,
 	}
 }
 
@@ -44,10 +49,20 @@ func (h *HealthHandler) handleHealth(c *fiber.Ctx) error {
 			"hit_local":     stats.HitsLocal,
 			"hit_redis":     stats.HitsRedis,
 			"miss":          stats.Misses,
+			"stale_served":  stats.StaleServed,
 			"redis_enabled": stats.RedisEnabled,
 			"redis_alive":   stats.RedisAlive,
+			"tiers":         stats.Tiers,
+			"invalidation":  stats.Invalidation,
 		},
 	}
+	if h.renderer != nil {
+		// 热更新失败时旧版本仍在服务，这里只暴露状态不影响整体可用性
+		response["renderer"] = h.renderer.Status()
+	}
+	if h.scheduler != nil {
+		response["scheduler"] = h.scheduler.Stats()
+	}
 
 	h.logger.Info("健康检查", zap.String("request_id", requestID))
 
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mathsvg/internal/latex"
+	"mathsvg/internal/renderer"
+	"mathsvg/internal/svgutil"
+)
+
+const (
+	displayInline = "inline"
+	displayBlock  = "block"
+
+	formatSVG    = "svg"
+	formatPNG    = "png"
+	formatJSON   = "json"
+	formatMathML = "mathml"
+
+	minFontSize = 4
+	maxFontSize = 256
+	minScale    = 0.1
+	maxScale    = 10
+	defaultDPI  = 96
+	minDPI      = 24
+	maxDPI      = 1200
+
+	backgroundTransparent = "transparent"
+
+	// displayPrefix 为块级公式追加的前缀，渲染器报告的列号需扣除其长度
+	displayPrefix = `\displaystyle `
+)
+
+// speechLangs 是无障碍朗读文本支持的语言
+var speechLangs = map[string]bool{latex.LangEnglish: true, latex.LangChinese: true}
+
+// colorPattern 仅允许十六进制色值或纯字母颜色名，防止向 SVG 注入属性
+var colorPattern = regexp.MustCompile(`^(#[0-9a-f]{3}|#[0-9a-f]{4}|#[0-9a-f]{6}|#[0-9a-f]{8}|[a-z]{3,20})$`)
+
+// renderOptions 描述单次渲染可调整的参数，normalize 之后才可用于缓存键
+type renderOptions struct {
+	Display  string
+	FontSize float64
+	Color    string
+	Scale    float64
+	Format   string
+	// DPI 与 Background 仅对 PNG 生效，其余格式会被清空以免拆分缓存
+	DPI        float64
+	Background string
+	// A11y 为 nil 表示沿用配置默认值；Lang 为朗读文本语言，仅在启用无障碍时保留
+	A11y *bool
+	Lang string
+}
+
+// detach 复制所有字符串字段，使选项可以在请求结束后继续使用
+func (o renderOptions) detach() renderOptions {
+	o.Display = strings.Clone(o.Display)
+	o.Color = strings.Clone(o.Color)
+	o.Format = strings.Clone(o.Format)
+	o.Background = strings.Clone(o.Background)
+	o.Lang = strings.Clone(o.Lang)
+	return o
+}
+
+// renderRequest 对应 POST /render 的 JSON 请求体
+type renderRequest struct {
+	Tex        string  `json:"tex"`
+	Display    string  `json:"display"`
+	FontSize   float64 `json:"font_size"`
+	Color      string  `json:"color"`
+	Scale      float64 `json:"scale"`
+	Format     string  `json:"format"`
+	DPI        float64 `json:"dpi"`
+	Background string  `json:"background"`
+	A11y       *bool   `json:"a11y"`
+	Lang       string  `json:"lang"`
+}
+
+func (r renderRequest) options() renderOptions {
+	return renderOptions{
+		Display:    r.Display,
+		FontSize:   r.FontSize,
+		Color:      r.Color,
+		Scale:      r.Scale,
+		Format:     r.Format,
+		DPI:        r.DPI,
+		Background: r.Background,
+		A11y:       r.A11y,
+		Lang:       r.Lang,
+	}
+}
+
+// optionsFromQuery 从 GET 请求的查询参数中读取渲染选项，尚未 normalize
+func optionsFromQuery(c *fiber.Ctx) (renderOptions, error) {
+	opts := renderOptions{
+		Display:    c.Query("display"),
+		Color:      c.Query("color"),
+		Format:     c.Query("format"),
+		Background: c.Query("background"),
+		Lang:       c.Query("lang"),
+	}
+
+	if raw := c.Query("font_size"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return renderOptions{}, ErrInvalidFontSize
+		}
+		opts.FontSize = value
+	}
+	if raw := c.Query("scale"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return renderOptions{}, ErrInvalidScale
+		}
+		opts.Scale = value
+	}
+	if raw := c.Query("dpi"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return renderOptions{}, ErrInvalidDPI
+		}
+		opts.DPI = value
+	}
+	if raw := c.Query("a11y"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return renderOptions{}, ErrInvalidA11y
+		}
+		opts.A11y = &value
+	}
+
+	return opts, nil
+}
+
+// negotiateFormat 在未显式指定 format 时，根据 Accept 头决定是否返回 JSON 元数据
+func negotiateFormat(c *fiber.Ctx, opts renderOptions) renderOptions {
+	if strings.TrimSpace(opts.Format) != "" {
+		return opts
+	}
+	if c.Accepts("image/svg+xml", jsonContentType) == jsonContentType {
+		opts.Format = formatJSON
+	}
+	return opts
+}
+
+// normalize 校验选项并补齐默认值，保证等价请求得到相同的缓存键
+func (o renderOptions) normalize() (renderOptions, error) {
+	switch strings.ToLower(strings.TrimSpace(o.Display)) {
+	case "", displayInline:
+		o.Display = displayInline
+	case displayBlock:
+		o.Display = displayBlock
+	default:
+		return renderOptions{}, ErrInvalidDisplay
+	}
+
+	// 范围判断写成取反形式，NaN 与任何值比较都为 false，会随之被拒绝
+	if o.FontSize != 0 && !(o.FontSize >= minFontSize && o.FontSize <= maxFontSize) {
+		return renderOptions{}, ErrInvalidFontSize
+	}
+
+	o.Color = strings.ToLower(strings.TrimSpace(o.Color))
+	if o.Color != "" && !colorPattern.MatchString(o.Color) {
+		return renderOptions{}, ErrInvalidColor
+	}
+
+	if o.Scale == 0 {
+		o.Scale = 1
+	}
+	if !(o.Scale >= minScale && o.Scale <= maxScale) {
+		return renderOptions{}, ErrInvalidScale
+	}
+
+	switch strings.ToLower(strings.TrimSpace(o.Format)) {
+	case "", formatSVG:
+		o.Format = formatSVG
+	case formatPNG:
+		o.Format = formatPNG
+	case formatJSON:
+		o.Format = formatJSON
+	case formatMathML:
+		// MathML 由浏览器排版，缩放参数无意义，统一清空以免拆分缓存
+		o.Format = formatMathML
+		o.Scale = 1
+	default:
+		return renderOptions{}, ErrUnsupportedFormat
+	}
+
+	// 无障碍信息只注入 SVG 文本，PNG 与 MathML 不受影响
+	if o.Format != formatSVG && o.Format != formatJSON {
+		o.A11y = nil
+	}
+	if o.accessible() {
+		o.Lang = strings.ToLower(strings.TrimSpace(o.Lang))
+		if o.Lang == "" {
+			o.Lang = latex.LangEnglish
+		}
+		if !speechLangs[o.Lang] {
+			return renderOptions{}, ErrInvalidLang
+		}
+	} else {
+		o.A11y = nil
+		o.Lang = ""
+	}
+
+	if o.Format != formatPNG {
+		o.DPI = 0
+		o.Background = ""
+		return o, nil
+	}
+
+	if o.DPI == 0 {
+		o.DPI = defaultDPI
+	}
+	if !(o.DPI >= minDPI && o.DPI <= maxDPI) {
+		return renderOptions{}, ErrInvalidDPI
+	}
+
+	o.Background = strings.ToLower(strings.TrimSpace(o.Background))
+	if o.Background == backgroundTransparent {
+		o.Background = ""
+	}
+	if o.Background != "" {
+		if !colorPattern.MatchString(o.Background) {
+			return renderOptions{}, ErrInvalidBackground
+		}
+		if _, err := svgutil.ParseColor(o.Background); err != nil {
+			return renderOptions{}, ErrInvalidBackground
+		}
+	}
+
+	return o, nil
+}
+
+// canonical 只输出非默认值，默认选项得到空串以沿用历史缓存键
+func (o renderOptions) canonical() string {
+	var parts []string
+	if o.Display != displayInline {
+		parts = append(parts, "display="+o.Display)
+	}
+	if o.FontSize != 0 {
+		parts = append(parts, "font_size="+strconv.FormatFloat(o.FontSize, 'f', -1, 64))
+	}
+	if o.Color != "" {
+		parts = append(parts, "color="+o.Color)
+	}
+	if o.Scale != 1 {
+		parts = append(parts, "scale="+strconv.FormatFloat(o.Scale, 'f', -1, 64))
+	}
+	// JSON 元数据与 SVG 共用同一份缓存值，度量信息随 SVG 一起保存
+	if o.Format != formatSVG && o.Format != formatJSON {
+		parts = append(parts, "format="+o.Format)
+	}
+	if o.DPI != 0 {
+		parts = append(parts, "dpi="+strconv.FormatFloat(o.DPI, 'f', -1, 64))
+	}
+	if o.Background != "" {
+		parts = append(parts, "background="+o.Background)
+	}
+	if o.accessible() {
+		parts = append(parts, "a11y="+o.Lang)
+	}
+	return strings.Join(parts, ";")
+}
+
+// accessible 判断是否需要注入无障碍信息
+func (o renderOptions) accessible() bool {
+	return o.A11y != nil && *o.A11y
+}
+
+// rendererInput 生成交给渲染器的公式文本，块级公式使用 \displaystyle
+func (o renderOptions) rendererInput(tex string) string {
+	if o.Display == displayBlock {
+		return displayPrefix + tex
+	}
+	return tex
+}
+
+// rendererError 将渲染器报告的列号换算回用户输入，扣除块级公式添加的前缀
+func (o renderOptions) rendererError(err error) error {
+	var renderErr *renderer.RenderError
+	if o.Display != displayBlock || !errors.As(err, &renderErr) || renderErr.Line != 1 {
+		return err
+	}
+	adjusted := *renderErr
+	adjusted.Column -= utf8.RuneCountInString(displayPrefix)
+	if adjusted.Column < 1 {
+		adjusted.Column = 1
+	}
+	return &adjusted
+}
+
+func (o renderOptions) style() svgutil.Style {
+	return svgutil.Style{
+		FontSize: o.FontSize,
+		Color:    o.Color,
+		Scale:    o.Scale,
+	}
+}
+
+// rasterOptions 生成 PNG 栅格化参数，背景色已在 normalize 中校验过
+func (o renderOptions) rasterOptions() svgutil.RasterOptions {
+	raster := svgutil.RasterOptions{
+		DPI:        o.DPI,
+		FontSize:   o.FontSize,
+		Foreground: o.Color,
+	}
+	if o.Background != "" {
+		raster.Background, _ = svgutil.ParseColor(o.Background)
+	}
+	return raster
+}
+
+// contentType 返回当前输出格式对应的响应类型
+func (o renderOptions) contentType() string {
+	switch o.Format {
+	case formatPNG:
+		return pngContentType
+	case formatMathML:
+		return mathmlContentType
+	default:
+		return responseContentType
+	}
+}
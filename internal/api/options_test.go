@@ -0,0 +1,96 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRenderOptions_NormalizeDefaults(t *testing.T) {
+	opts, err := renderOptions{}.normalize()
+	if err != nil {
+		t.Fatalf("默认选项不应报错: %v", err)
+	}
+	if opts.Display != displayInline || opts.Scale != 1 || opts.Format != formatSVG {
+		t.Fatalf("默认值不符合预期: %+v", opts)
+	}
+	if opts.canonical() != "" {
+		t.Fatalf("默认选项的规范化串应为空: %q", opts.canonical())
+	}
+}
+
+func TestRenderOptions_NormalizeInvalid(t *testing.T) {
+	cases := map[string]struct {
+		opts renderOptions
+		want error
+	}{
+		"display":    {renderOptions{Display: "center"}, ErrInvalidDisplay},
+		"font":       {renderOptions{FontSize: 1000}, ErrInvalidFontSize},
+		"font-nan":   {renderOptions{FontSize: math.NaN()}, ErrInvalidFontSize},
+		"font-inf":   {renderOptions{FontSize: math.Inf(1)}, ErrInvalidFontSize},
+		"font-ninf":  {renderOptions{FontSize: math.Inf(-1)}, ErrInvalidFontSize},
+		"color":      {renderOptions{Color: `red" onload="x`}, ErrInvalidColor},
+		"scale":      {renderOptions{Scale: 50}, ErrInvalidScale},
+		"scale-nan":  {renderOptions{Scale: math.NaN()}, ErrInvalidScale},
+		"scale-inf":  {renderOptions{Scale: math.Inf(1)}, ErrInvalidScale},
+		"scale-ninf": {renderOptions{Scale: math.Inf(-1)}, ErrInvalidScale},
+		"format":     {renderOptions{Format: "gif"}, ErrUnsupportedFormat},
+	}
+	for name, tc := range cases {
+		if _, err := tc.opts.normalize(); err != tc.want {
+			t.Fatalf("%s: 期望 %v，实际 %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestRenderOptions_NormalizeInvalidPNG(t *testing.T) {
+	cases := map[string]struct {
+		opts renderOptions
+		want error
+	}{
+		"dpi":        {renderOptions{Format: "png", DPI: 5000}, ErrInvalidDPI},
+		"dpi-nan":    {renderOptions{Format: "png", DPI: math.NaN()}, ErrInvalidDPI},
+		"dpi-inf":    {renderOptions{Format: "png", DPI: math.Inf(1)}, ErrInvalidDPI},
+		"dpi-ninf":   {renderOptions{Format: "png", DPI: math.Inf(-1)}, ErrInvalidDPI},
+		"scale-nan":  {renderOptions{Format: "png", Scale: math.NaN()}, ErrInvalidScale},
+		"background": {renderOptions{Format: "png", Background: "url(x)"}, ErrInvalidBackground},
+	}
+	for name, tc := range cases {
+		if _, err := tc.opts.normalize(); err != tc.want {
+			t.Fatalf("%s: 期望 %v，实际 %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestHashFormula_OptionsDoNotCollide(t *testing.T) {
+	plain, _ := renderOptions{}.normalize()
+	block, _ := renderOptions{Display: "BLOCK"}.normalize()
+	colored, _ := renderOptions{Color: "#FFF"}.normalize()
+
+	keys := map[string]bool{
+		hashFormula("E=mc^2", plain):   true,
+		hashFormula("E=mc^2", block):   true,
+		hashFormula("E=mc^2", colored): true,
+	}
+	if len(keys) != 3 {
+		t.Fatal("不同选项的缓存键不应相同")
+	}
+
+	again, _ := renderOptions{Display: " block "}.normalize()
+	if hashFormula("E=mc^2", block) != hashFormula("E=mc^2", again) {
+		t.Fatal("等价选项应得到相同缓存键")
+	}
+}
+
+func TestHashFormula_PNGResolution(t *testing.T) {
+	low, _ := renderOptions{Format: "png"}.normalize()
+	high, _ := renderOptions{Format: "png", DPI: 192}.normalize()
+	svg, _ := renderOptions{DPI: 192}.normalize()
+	plain, _ := renderOptions{}.normalize()
+
+	if hashFormula("x", low) == hashFormula("x", high) {
+		t.Fatal("不同分辨率的 PNG 不应共用缓存键")
+	}
+	if hashFormula("x", svg) != hashFormula("x", plain) {
+		t.Fatal("SVG 应忽略 dpi 参数")
+	}
+}
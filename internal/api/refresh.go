@@ -0,0 +1,134 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+)
+
+// recentFormula 是最近请求过的公式及其选项，渲染库升级后据此在后台重新渲染
+type recentFormula struct {
+	hash       string
+	normalized string
+	opts       renderOptions
+}
+
+// recentSet 按最近使用顺序保存有限数量的公式
+type recentSet struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List
+	index map[string]*list.Element
+}
+
+func newRecentSet(max int) *recentSet {
+	return &recentSet{max: max, order: list.New(), index: make(map[string]*list.Element)}
+}
+
+func (r *recentSet) add(f recentFormula) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.index[f.hash]; ok {
+		r.order.MoveToFront(elem)
+		return
+	}
+	r.index[f.hash] = r.order.PushFront(f)
+	if r.order.Len() > r.max {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.index, oldest.Value.(recentFormula).hash)
+	}
+}
+
+// snapshot 按最近使用在前的顺序返回当前保存的公式
+func (r *recentSet) snapshot() []recentFormula {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	formulas := make([]recentFormula, 0, r.order.Len())
+	for elem := r.order.Front(); elem != nil; elem = elem.Next() {
+		formulas = append(formulas, elem.Value.(recentFormula))
+	}
+	return formulas
+}
+
+// remember 记录成功返回的公式，并在发现渲染库版本变化时触发后台重新渲染
+func (h *RenderHandler) remember(cacheKey, version, normalized string, opts renderOptions) {
+	if h.recent == nil || version == "" {
+		return
+	}
+	// GET 参数直接引用 fiber 的请求缓冲区，请求结束后会被复用，保存前需复制
+	h.recent.add(recentFormula{
+		hash:       strings.TrimPrefix(cacheKey, version+":"),
+		normalized: strings.Clone(normalized),
+		opts:       opts.detach(),
+	})
+
+	h.versionMu.Lock()
+	previous := h.seenVersion
+	h.seenVersion = version
+	h.versionMu.Unlock()
+	if previous != "" && previous != version {
+		go h.refreshRecent(previous, version)
+	}
+}
+
+// revalidate 在后台重新渲染已过期但仍被返回的缓存，同一键同时只刷新一次
+func (h *RenderHandler) revalidate(cacheKey, version, normalized string, opts renderOptions) {
+	if _, busy := h.revalidating.LoadOrStore(cacheKey, struct{}{}); busy {
+		return
+	}
+	normalized, opts = strings.Clone(normalized), opts.detach()
+	go func() {
+		defer h.revalidating.Delete(cacheKey)
+		ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+		defer cancel()
+		if res, _ := h.renderShared(ctx, cacheKey, version, normalized, opts); res.err != nil {
+			h.logger.Warn("过期缓存后台刷新失败", zap.String("cache_key", cacheKey), zap.Error(res.err))
+		}
+	}()
+}
+
+// refreshRecent 依次重新渲染最近的公式写入新版本的缓存，同一时间只运行一轮；
+// 渲染经过调度器，繁忙时被拒绝的公式留待请求到来时再渲染
+func (h *RenderHandler) refreshRecent(previous, version string) {
+	if !h.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer h.refreshing.Store(false)
+
+	start := time.Now()
+	formulas := h.recent.snapshot()
+	rendered, failed := 0, 0
+	for _, f := range formulas {
+		ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+		key, current := h.cacheKey(f.normalized, f.opts)
+		if current != version {
+			// 刷新期间渲染库再次升级，交给下一轮处理
+			cancel()
+			break
+		}
+		if _, hitLevel := h.lookup(ctx, key, current, ""); hitLevel == cache.HitNone {
+			if res, _ := h.renderShared(ctx, key, current, f.normalized, f.opts); res.err != nil {
+				failed++
+			} else {
+				rendered++
+			}
+		}
+		cancel()
+	}
+
+	h.logger.Info("渲染库版本变化，已在后台重新渲染最近的公式",
+		zap.String("previous_version", previous),
+		zap.String("version", version),
+		zap.Int("recent", len(formulas)),
+		zap.Int("rendered", rendered),
+		zap.Int("failed", failed),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
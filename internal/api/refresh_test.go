@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/renderer"
+)
+
+// versionedRenderer 模拟可升级的渲染库，并按公式统计渲染次数
+type versionedRenderer struct {
+	stub    *renderer.Stub
+	version atomic.Value
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *versionedRenderer) Render(ctx context.Context, tex string) (string, error) {
+	r.mu.Lock()
+	r.calls[strings.Clone(tex)]++
+	r.mu.Unlock()
+	return r.stub.Render(ctx, tex)
+}
+
+func (r *versionedRenderer) Version() string { return r.version.Load().(string) }
+
+func (r *versionedRenderer) count(tex string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[tex]
+}
+
+func TestRenderHandler_RendererUpgrade(t *testing.T) {
+	r := &versionedRenderer{stub: renderer.NewStub(), calls: make(map[string]int)}
+	r.version.Store("v1")
+	manager := cache.NewManagerWithTiers(config.Cache{}, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{})}, zap.NewNop())
+	handler := NewRenderHandler(manager, r, zap.NewNop(), config.Server{RequestTimeout: time.Second},
+		config.Render{RefreshOnUpgrade: true, RefreshRecent: 10})
+	app := fiber.New()
+	handler.Register(app)
+
+	hitLevel := func(tex string) string {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/render?format=json&tex="+url.QueryEscape(tex), nil))
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		var out metricsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("响应无法解析: %v", err)
+		}
+		return string(out.CacheHitLevel)
+	}
+
+	if hitLevel("x^2") != "miss" || hitLevel("x^2") != cache.TierMemory {
+		t.Fatalf("首次请求应未命中，再次请求应命中缓存")
+	}
+
+	// 升级后旧版本的结果不再命中；任一请求发现版本变化后在后台重新渲染最近的公式
+	r.version.Store("v2")
+	if hitLevel("y^3") != "miss" {
+		t.Fatalf("新公式应未命中")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for r.count("x^2") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("升级后应在后台重新渲染最近的公式")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if hitLevel("x^2") != cache.TierMemory || r.count("x^2") != 2 {
+		t.Fatalf("后台重新渲染后应直接命中新版本缓存")
+	}
+
+	key, version := handler.cacheKey("x^2", mustOptions(t, handler))
+	if !strings.HasPrefix(key, "v2:") || version != "v2" {
+		t.Fatalf("缓存键应以渲染库版本为命名空间: %s", key)
+	}
+	entry, _ := handler.lookup(context.Background(), key, version, "")
+	if entry.RendererVersion != "v2" || entry.CreatedAt.IsZero() {
+		t.Fatalf("缓存值应记录版本与生成时间: %+v", entry)
+	}
+	if _, level := handler.lookup(context.Background(), key, "v3", ""); level != cache.HitNone {
+		t.Fatalf("其他版本生成的结果应按未命中处理")
+	}
+}
+
+func mustOptions(t *testing.T, h *RenderHandler) renderOptions {
+	t.Helper()
+	opts, err := h.withDefaults(renderOptions{Format: formatJSON}).normalize()
+	if err != nil {
+		t.Fatalf("选项无效: %v", err)
+	}
+	return opts
+}
+
+func TestRenderHandler_StaleRevalidate(t *testing.T) {
+	r := &versionedRenderer{stub: renderer.NewStub(), calls: make(map[string]int)}
+	r.version.Store("")
+	tier := cache.NewMemoryTier(cache.TierMemory, cache.TierPolicy{TTL: 50 * time.Millisecond, StaleWindow: time.Hour})
+	manager := cache.NewManagerWithTiers(config.Cache{StaleWhileRevalidate: true}, []cache.Tier{tier}, zap.NewNop())
+	handler := NewRenderHandler(manager, r, zap.NewNop(), config.Server{RequestTimeout: time.Second}, config.Render{})
+	app := fiber.New()
+	handler.Register(app)
+
+	hitLevel := func() string {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/render?format=json&tex=x", nil))
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		var out metricsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("响应无法解析: %v", err)
+		}
+		return string(out.CacheHitLevel)
+	}
+
+	if hitLevel() != "miss" {
+		t.Fatalf("首次请求应未命中")
+	}
+	time.Sleep(70 * time.Millisecond)
+	if level := hitLevel(); level != "memory:stale" {
+		t.Fatalf("过期后应先返回旧值，实际: %s", level)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for r.count("x") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("返回旧值后应在后台重新渲染")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 等待后台结果写入缓存
+	for hitLevel() != cache.TierMemory {
+		if time.Now().After(deadline) {
+			t.Fatalf("后台刷新后应命中新鲜的缓存")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRenderHandler_SnapshotFilter(t *testing.T) {
+	r := &versionedRenderer{stub: renderer.NewStub(), calls: make(map[string]int)}
+	r.version.Store("v2")
+	manager := cache.NewManagerWithTiers(config.Cache{}, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{})}, zap.NewNop())
+	keep := NewRenderHandler(manager, r, zap.NewNop(), config.Server{}, config.Render{}).SnapshotFilter()
+
+	cases := map[string]bool{
+		encodeEntry(renderEntry{Body: "<svg/>", RendererVersion: "v2"}): true,
+		encodeEntry(renderEntry{Body: "<svg/>", RendererVersion: "v1"}): false,
+		encodeEntry(renderEntry{Body: "<math/>"}):                       true,
+		"<svg/>": false,
+	}
+	for value, want := range cases {
+		if got := keep("k", []byte(value)); got != want {
+			t.Fatalf("%q 的筛选结果应为 %v", value, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/renderer"
+)
+
+// ReloadHandler 提供渲染库热更新的管理接口，挂载在管理监听上并由 AdminHandler 统一鉴权。
+// 只重载 renderer.library_path 配置的共享库，不接受请求指定路径
+type ReloadHandler struct {
+	swap *renderer.HotSwap
+	// broadcast 为 true 时（prefork），本进程校验通过后再通知监听共享库的各子进程重载
+	broadcast bool
+	audit     *zap.Logger
+}
+
+// NewReloadHandler 构建热更新处理器；broadcast 依赖各进程开启 renderer.watch_library
+func NewReloadHandler(swap *renderer.HotSwap, broadcast bool, logger *zap.Logger) *ReloadHandler {
+	return &ReloadHandler{
+		swap:      swap,
+		broadcast: broadcast,
+		audit:     logger.Named("audit"),
+	}
+}
+
+// Register 将热更新接口挂载到已鉴权的 /admin 路由组上
+func (h *ReloadHandler) Register(router fiber.Router) {
+	router.Post("/renderer/reload", h.handleReload)
+}
+
+func (h *ReloadHandler) handleReload(c *fiber.Ctx) error {
+	requestID := requestIDFromCtx(c)
+	// 先在本进程加载并通过金丝雀，失败时不通知其他进程
+	err := h.swap.Reload("")
+	broadcast := false
+	if err == nil && h.broadcast {
+		err = h.swap.Broadcast()
+		broadcast = err == nil
+	}
+	h.audit.Info("管理操作",
+		zap.String("action", "renderer_reload"),
+		zap.String("request_id", requestID),
+		zap.String("ip", c.IP()),
+		zap.Bool("broadcast", broadcast),
+		zap.Error(err),
+	)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"request_id": requestID,
+			"error":      err.Error(),
+			"renderer":   h.swap.Status(),
+		})
+	}
+	return c.JSON(fiber.Map{"request_id": requestID, "renderer": h.swap.Status(), "broadcast": broadcast})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/renderer"
+)
+
+func TestReloadHandler(t *testing.T) {
+	swap := renderer.NewHotSwap(config.Renderer{LibraryPath: "/nonexistent/libformula.so"}, zap.NewNop())
+	manager := cache.NewManagerWithTiers(config.Cache{}, []cache.Tier{cache.NewMemoryTier("", cache.TierPolicy{})}, zap.NewNop())
+	t.Cleanup(func() { _ = manager.Close() })
+	render := NewRenderHandler(manager, swap, zap.NewNop(), config.Server{}, config.Render{})
+	app := fiber.New()
+	NewAdminHandler(render, manager, NewReloadHandler(swap, false, zap.NewNop()), true, config.Admin{Token: "secret"}, zap.NewNop()).Register(app)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/admin/renderer/reload", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer wrong")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("令牌错误应返回 401，实际: %d", resp.StatusCode)
+	}
+
+	// prefork 下即使缓存接口不可用，热更新接口仍然挂载；请求体中的路径不再生效，只会重载配置的共享库
+	req = httptest.NewRequest(fiber.MethodPost, "/admin/renderer/reload", strings.NewReader(`{"library_path":"/tmp/evil.so"}`))
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer secret")
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	var out struct {
+		Error    string                `json:"error"`
+		Renderer renderer.ReloadStatus `json:"renderer"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("响应无法解析: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnprocessableEntity || !strings.Contains(out.Error, "/nonexistent/libformula.so") {
+		t.Fatalf("应尝试加载配置的共享库并返回 422: %d %+v", resp.StatusCode, out)
+	}
+	if out.Renderer.Engine != renderer.EngineStub || out.Renderer.LastError != out.Error {
+		t.Fatalf("失败后应保留原渲染器并记录错误: %+v", out.Renderer)
+	}
+}
@@ -4,80 +4,196 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 
 	"mathsvg/internal/cache"
+	"mathsvg/internal/config"
+	"mathsvg/internal/latex"
 	"mathsvg/internal/renderer"
+	"mathsvg/internal/svgutil"
 )
 
 const (
 	responseContentType = "image/svg+xml; charset=utf-8"
-	errorSVG            = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 320 80"><rect width="100%" height="100%" fill="#fef2f2"/><text x="20" y="45" font-size="20" font-family="sans-serif" fill="#b91c1c">公式渲染失败，请检查输入</text></svg>`
+	pngContentType      = "image/png"
+	jsonContentType     = "application/json"
+	mathmlContentType   = "application/mathml+xml; charset=utf-8"
+	errorSVGTemplate    = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d 80"><rect width="100%%" height="100%%" fill="#fef2f2"/><text x="20" y="45" font-size="20" font-family="sans-serif" fill="#b91c1c">%s</text></svg>`
 )
 
+// metricsResponse 是 format=json 时的响应体，便于前端按基线对齐行内公式
+type metricsResponse struct {
+	SVG string `json:"svg"`
+	svgutil.Metrics
+	CacheHitLevel cache.HitLevel `json:"cache_hit_level"`
+	RenderMS      float64        `json:"render_ms"`
+	RequestID     string         `json:"request_id"`
+}
+
 // RenderHandler 负责对外暴露渲染 API
 type RenderHandler struct {
 	cache          *cache.Manager
 	renderer       renderer.Renderer
 	logger         *zap.Logger
 	requestTimeout time.Duration
+	maxBodyBytes   int
+	batchMaxItems  int
+	batchWorkers   int
+	a11yDefault    bool
+	speechLang     string
+	// compression 为缓存值的压缩编码，为空表示不压缩
+	compression    string
+	compressionMin int
+
+	// flights 合并本进程内相同缓存键的并发渲染
+	flights flightGroup
+
+	// recent 与 seenVersion 用于渲染库升级后在后台重新渲染最近的公式，未启用时 recent 为空
+	recent      *recentSet
+	versionMu   sync.Mutex
+	seenVersion string
+	refreshing  atomic.Bool
+
+	// revalidating 记录正在后台刷新的过期缓存键，避免同一键重复刷新
+	revalidating sync.Map
 }
 
 // NewRenderHandler 构建渲染处理器实例
-func NewRenderHandler(cache *cache.Manager, renderer renderer.Renderer, logger *zap.Logger, timeout time.Duration) *RenderHandler {
-	return &RenderHandler{
+func NewRenderHandler(cache *cache.Manager, renderer renderer.Renderer, logger *zap.Logger, cfg config.Server, renderCfg config.Render) *RenderHandler {
+	compression := strings.ToLower(strings.TrimSpace(renderCfg.Compression))
+	switch compression {
+	case encodingGzip, encodingBrotli:
+	case "", encodingNone:
+		compression = ""
+	default:
+		logger.Warn("不支持的缓存压缩编码，按不压缩处理", zap.String("compression", renderCfg.Compression))
+		compression = ""
+	}
+
+	h := &RenderHandler{
 		cache:          cache,
 		renderer:       renderer,
 		logger:         logger,
-		requestTimeout: timeout,
+		requestTimeout: cfg.RequestTimeout,
+		maxBodyBytes:   cfg.MaxRequestBodyMB * 1024 * 1024,
+		batchMaxItems:  cfg.BatchMaxItems,
+		batchWorkers:   cfg.BatchWorkers,
+		a11yDefault:    renderCfg.Accessibility,
+		speechLang:     renderCfg.SpeechLang,
+		compression:    compression,
+		compressionMin: renderCfg.CompressionMinBytes,
+	}
+	if renderCfg.RefreshOnUpgrade && renderCfg.RefreshRecent > 0 {
+		h.recent = newRecentSet(renderCfg.RefreshRecent)
 	}
+	return h
 }
 
 // Register 将渲染接口挂载到指定的 Fiber 路由组
 func (h *RenderHandler) Register(router fiber.Router) {
 	router.Get("/render", h.handleRender)
+	router.Post("/render", h.handleRenderPost)
+	router.Post("/render/batch", h.handleBatch)
 }
 
 // handleRender 为 GET /render 提供具体业务处理逻辑
 func (h *RenderHandler) handleRender(c *fiber.Ctx) error {
+	opts, err := optionsFromQuery(c)
+	if err == nil {
+		opts, err = h.withDefaults(negotiateFormat(c, opts)).normalize()
+	}
+	if err != nil {
+		return h.rejectInput(c, err, c.Query("tex"), opts.Format)
+	}
+	return h.render(c, c.Query("tex"), opts)
+}
+
+// handleRenderPost 为 POST /render 解析 JSON 请求体，适合超长公式与携带选项的场景
+func (h *RenderHandler) handleRenderPost(c *fiber.Ctx) error {
+	body := c.Body()
+	if h.maxBodyBytes > 0 && len(body) > h.maxBodyBytes {
+		return h.rejectInput(c, ErrBodyTooLarge, "", "")
+	}
+
+	var req renderRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return h.rejectInput(c, ErrInvalidBody, "", "")
+	}
+
+	opts, err := h.withDefaults(negotiateFormat(c, req.options())).normalize()
+	if err != nil {
+		return h.rejectInput(c, err, req.Tex, opts.Format)
+	}
+	return h.render(c, req.Tex, opts)
+}
+
+// withDefaults 用配置补齐请求中未指定的选项
+func (h *RenderHandler) withDefaults(opts renderOptions) renderOptions {
+	if opts.A11y == nil {
+		enabled := h.a11yDefault
+		opts.A11y = &enabled
+	}
+	if strings.TrimSpace(opts.Lang) == "" {
+		opts.Lang = h.speechLang
+	}
+	return opts
+}
+
+// render 串联校验、缓存与渲染流程，GET 与 POST 共用
+func (h *RenderHandler) render(c *fiber.Ctx, tex string, opts renderOptions) error {
 	start := time.Now()
-	tex := c.Query("tex")
 	requestID := requestIDFromCtx(c)
 	log := h.logger.With(zap.String("request_id", requestID))
 
 	normalized, err := validateFormula(tex)
 	if err != nil {
-		status := classifyInputError(err)
-		log.Warn("公式输入不合法", zap.Error(err))
-		c.Set("Content-Type", responseContentType)
-		return c.Status(status).SendString(errorSVG)
+		return h.rejectInput(c, err, tex, opts.Format)
 	}
 
-	// 先生成缓存键，避免重复渲染
-	cacheKey := hashFormula(normalized)
+	// 先生成缓存键，避免重复渲染；选项或渲染库版本不同的结果各自缓存
+	cacheKey, version := h.cacheKey(normalized, opts)
 	reqCtx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
 	defer cancel()
 
+	// JSON 需要把 SVG 嵌入响应体，只有直接返回内容时才能沿用缓存中的压缩结果
+	acceptEncoding := c.Get(fiber.HeaderAcceptEncoding)
+	if opts.Format == formatJSON {
+		acceptEncoding = ""
+	}
+
 	// 一级缓存 → 二级缓存 → 缓存未命中时渲染
-	svg, hitLevel := h.cache.Get(reqCtx, cacheKey)
+	entry, hitLevel := h.lookup(reqCtx, cacheKey, version, acceptEncoding)
+	if hitLevel.Stale() {
+		// 先返回旧值，后台重新渲染后写回缓存
+		h.revalidate(cacheKey, version, normalized, opts)
+	}
 	var renderDuration time.Duration
+	var shared flightResult
+	var coalesced int
 	if hitLevel == cache.HitNone {
-		renderStart := time.Now()
-		output, err := h.renderer.Render(normalized)
-		renderDuration = time.Since(renderStart)
+		shared, coalesced = h.renderShared(reqCtx, cacheKey, version, normalized, opts)
+		entry, renderDuration, err = shared.entry, shared.renderDuration, shared.err
 		if err != nil {
-			// 若渲染失败，返回预置错误 SVG，避免前端渲染空白
-			log.Error("渲染失败", zap.Error(err))
-			c.Set("Content-Type", responseContentType)
-			return c.Status(fiber.StatusUnprocessableEntity).SendString(errorSVG)
+			// 若渲染失败，返回带错误信息的 SVG 或结构化 JSON，避免前端渲染空白
+			resp := describeError(err, normalized, requestID)
+			if resp.status >= fiber.StatusInternalServerError {
+				log.Error("渲染失败", zap.String("code", resp.Code), zap.Error(err))
+			} else {
+				log.Warn("渲染失败", zap.String("code", resp.Code), zap.Error(err))
+			}
+			return sendError(c, resp, opts.Format)
 		}
-		svg = output
-		h.cache.Set(reqCtx, cacheKey, svg)
 	}
 
+	h.remember(cacheKey, version, normalized, opts)
+
 	// 将关键指标写入结构化日志
 	totalDuration := time.Since(start)
 	log.Info("公式渲染完成",
@@ -85,27 +201,219 @@ func (h *RenderHandler) handleRender(c *fiber.Ctx) error {
 		zap.Float64("render_duration_ms", float64(renderDuration.Microseconds())/1000.0),
 		zap.String("cache_hit_level", string(hitLevel)),
 		zap.Int("formula_length", len([]rune(normalized))),
+		zap.String("display", opts.Display),
+		zap.String("format", opts.Format),
+		zap.Int("coalesced", coalesced),
+		zap.Bool("peer_rendered", shared.peer),
 	)
 
-	c.Set("Content-Type", responseContentType)
-	return c.SendString(svg)
+	if opts.Format == formatJSON {
+		return c.JSON(metricsResponse{
+			SVG:           entry.Body,
+			Metrics:       h.metricsOf(entry, opts),
+			CacheHitLevel: hitLevel,
+			RenderMS:      float64(renderDuration.Microseconds()) / 1000.0,
+			RequestID:     requestID,
+		})
+	}
+
+	c.Set("Content-Type", opts.contentType())
+	if entry.Encoding != "" {
+		c.Vary(fiber.HeaderAcceptEncoding)
+		if acceptsEncoding(acceptEncoding, entry.Encoding) {
+			// 设置 Content-Encoding 后压缩中间件不会再次压缩
+			c.Set(fiber.HeaderContentEncoding, entry.Encoding)
+			return c.SendString(entry.Encoded)
+		}
+	}
+	return c.SendString(entry.Body)
 }
 
-// hashFormula 将公式内容转换为缓存键，减少重复计算
-func hashFormula(tex string) string {
-	sum := sha256.Sum256([]byte(tex))
-	return hex.EncodeToString(sum[:])
+// lookup 查询缓存并解析缓存值；其他渲染库版本生成的结果按未命中处理。
+// 压缩内容仅在 acceptEncoding 不接受其编码时解压，无法解压的缓存值会被删除并按未命中处理
+func (h *RenderHandler) lookup(ctx context.Context, cacheKey, version, acceptEncoding string) (renderEntry, cache.HitLevel) {
+	raw, hitLevel := h.cache.Get(ctx, cacheKey)
+	if hitLevel == cache.HitNone {
+		return renderEntry{}, hitLevel
+	}
+	entry, ok := decodeEntry(raw)
+	if !ok {
+		h.logger.Debug("缓存值格式无法识别，重新渲染")
+		return renderEntry{}, cache.HitNone
+	}
+	if entry.RendererVersion != version {
+		h.logger.Debug("缓存结果来自其他渲染库版本，重新渲染",
+			zap.String("cached_version", entry.RendererVersion),
+			zap.String("version", version),
+		)
+		return renderEntry{}, cache.HitNone
+	}
+	if entry.Encoding != "" && acceptsEncoding(acceptEncoding, entry.Encoding) {
+		return entry, hitLevel
+	}
+	if err := entry.inflate(); err != nil {
+		h.logger.Warn("缓存值解压失败，重新渲染", zap.String("encoding", entry.Encoding), zap.Error(err))
+		_ = h.cache.Delete(ctx, cacheKey)
+		return renderEntry{}, cache.HitNone
+	}
+	return entry, hitLevel
 }
 
-func classifyInputError(err error) int {
-	switch err {
-	case ErrFormulaTooLarge:
-		return fiber.StatusRequestEntityTooLarge
-	case ErrInvalidCharacters:
-		return fiber.StatusBadRequest
-	case ErrEmptyFormula:
-		return fiber.StatusBadRequest
-	default:
-		return fiber.StatusBadRequest
+// renderAndStore 在缓存未命中时生成目标格式的结果并写回缓存
+func (h *RenderHandler) renderAndStore(ctx context.Context, cacheKey, version, normalized string, opts renderOptions) (renderEntry, time.Duration, error) {
+	renderStart := time.Now()
+	entry, err := h.produce(ctx, normalized, opts)
+	renderDuration := time.Since(renderStart)
+	if err != nil {
+		return renderEntry{}, renderDuration, err
+	}
+
+	entry.RendererVersion = version
+	entry.Options = opts.canonical()
+	entry.CreatedAt = renderStart
+	entry.RenderDuration = renderDuration
+	entry = h.compress(entry, opts)
+	h.cache.Set(ctx, cacheKey, encodeEntry(entry))
+	return entry, renderDuration, nil
+}
+
+// compress 按配置压缩文本结果，PNG 本身已压缩、过短的内容收益有限，均保持原样
+func (h *RenderHandler) compress(entry renderEntry, opts renderOptions) renderEntry {
+	if h.compression == "" || opts.Format == formatPNG || len(entry.Body) < h.compressionMin {
+		return entry
+	}
+	encoded, err := compressBody(entry.Body, h.compression)
+	if err != nil {
+		h.logger.Warn("缓存值压缩失败，按原样存储", zap.Error(err))
+		return entry
+	}
+	entry.Encoded, entry.Encoding = encoded, h.compression
+	return entry
+}
+
+// produce 调用渲染器得到 SVG，应用样式后按需转换为其他格式；SVG 的度量信息只在此处计算一次
+func (h *RenderHandler) produce(ctx context.Context, normalized string, opts renderOptions) (renderEntry, error) {
+	if opts.Format == formatMathML {
+		mathml, err := latex.ToMathML(normalized, latex.MathMLOptions{
+			Display:  opts.Display == displayBlock,
+			Color:    opts.Color,
+			FontSize: opts.FontSize,
+		})
+		if err != nil {
+			return renderEntry{}, err
+		}
+		return renderEntry{Body: mathml}, nil
+	}
+
+	svg, err := h.renderer.Render(ctx, opts.rendererInput(normalized))
+	if err != nil {
+		return renderEntry{}, opts.rendererError(err)
+	}
+	svg, err = svgutil.ApplyStyle(svg, opts.style())
+	if err != nil {
+		return renderEntry{}, err
+	}
+	if opts.accessible() {
+		svg, err = svgutil.InjectAccessibility(svg, h.accessibility(normalized, opts.Lang))
+		if err != nil {
+			return renderEntry{}, err
+		}
+	}
+
+	if opts.Format == formatPNG {
+		// PNG 以二进制形式存入缓存，string 可以无损承载任意字节
+		data, err := svgutil.RasterizePNG(svg, opts.rasterOptions())
+		if err != nil {
+			return renderEntry{}, err
+		}
+		return renderEntry{Body: string(data)}, nil
+	}
+
+	entry := renderEntry{Body: svg}
+	if metrics, err := svgutil.Measure(svg, opts.FontSize); err == nil {
+		entry.Metrics = metrics
+		entry.HasMetrics = true
+	}
+	return entry, nil
+}
+
+// accessibility 生成朗读文本；解析器不支持的公式退化为朗读原始 TeX
+func (h *RenderHandler) accessibility(normalized, lang string) svgutil.Accessibility {
+	other := latex.LangChinese
+	if lang == latex.LangChinese {
+		other = latex.LangEnglish
+	}
+
+	label, err := latex.Speak(normalized, lang)
+	if err != nil {
+		h.logger.Debug("朗读文本生成失败，使用原始公式", zap.Error(err))
+		return svgutil.Accessibility{Label: normalized, Desc: "LaTeX: " + normalized}
+	}
+	desc := "LaTeX: " + normalized
+	if alternative, err := latex.Speak(normalized, other); err == nil {
+		desc += "\n" + alternative
+	}
+	return svgutil.Accessibility{Label: label, Desc: desc}
+}
+
+// metricsOf 返回缓存中的度量信息，缺少时现场解析
+func (h *RenderHandler) metricsOf(entry renderEntry, opts renderOptions) svgutil.Metrics {
+	if entry.HasMetrics {
+		return entry.Metrics
+	}
+	metrics, err := svgutil.Measure(entry.Body, opts.FontSize)
+	if err != nil {
+		h.logger.Warn("SVG 尺寸解析失败", zap.Error(err))
+	}
+	return metrics
+}
+
+// rejectInput 统一处理输入校验失败，按内容协商返回错误 SVG 或 JSON
+func (h *RenderHandler) rejectInput(c *fiber.Ctx, err error, tex, format string) error {
+	resp := describeError(err, tex, requestIDFromCtx(c))
+	h.logger.Warn("公式输入不合法",
+		zap.String("request_id", resp.RequestID),
+		zap.String("code", resp.Code),
+		zap.Error(err),
+	)
+	return sendError(c, resp, format)
+}
+
+// cacheKey 在公式哈希前加上渲染库版本作为命名空间，升级渲染库后自然不再命中旧结果；
+// MathML 由服务自身生成，与渲染库版本无关
+func (h *RenderHandler) cacheKey(normalized string, opts renderOptions) (key, version string) {
+	key = hashFormula(normalized, opts)
+	if opts.Format == formatMathML {
+		return key, ""
+	}
+	if v, ok := h.renderer.(renderer.Versioned); ok {
+		version = v.Version()
+	}
+	if version == "" {
+		return key, ""
+	}
+	return version + ":" + key, version
+}
+
+// SnapshotFilter 返回恢复本地缓存快照时的筛选函数：格式无法识别或由其他渲染库版本生成的结果不再恢复
+func (h *RenderHandler) SnapshotFilter() func(key string, value []byte) bool {
+	var version string
+	if v, ok := h.renderer.(renderer.Versioned); ok {
+		version = v.Version()
+	}
+	return func(_ string, value []byte) bool {
+		entry, ok := decodeEntry(string(value))
+		return ok && (entry.RendererVersion == "" || entry.RendererVersion == version)
+	}
+}
+
+// hashFormula 将公式内容与渲染选项转换为缓存键，减少重复计算
+func hashFormula(tex string, opts renderOptions) string {
+	// 默认选项沿用纯公式哈希；公式不允许出现 \x00，可安全用作分隔符
+	payload := tex
+	if suffix := opts.canonical(); suffix != "" {
+		payload = tex + "\x00" + suffix
 	}
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mathsvg/internal/cache"
+	"mathsvg/internal/renderer"
+	"mathsvg/internal/svgutil"
+)
+
+func postRender(t *testing.T, app *fiber.App, body string) (int, string, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodPost, "/api/v1/render", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get("Content-Type"), raw
+}
+
+func TestHandleRenderPost_SVGOptions(t *testing.T) {
+	app := newTestApp(t)
+	status, contentType, raw := postRender(t, app, `{"tex":"E=mc^2","display":"block","color":"#1d4ed8"}`)
+	if status != fiber.StatusOK || contentType != responseContentType {
+		t.Fatalf("状态码或类型不符合预期: %d %s", status, contentType)
+	}
+	if !strings.Contains(string(raw), `fill="#1d4ed8"`) || !strings.Contains(string(raw), `\displaystyle`) {
+		t.Fatalf("渲染选项未生效: %s", raw)
+	}
+}
+
+func TestHandleRenderPost_InvalidBody(t *testing.T) {
+	app := newTestApp(t)
+	if status, _, _ := postRender(t, app, `{"tex":`); status != fiber.StatusBadRequest {
+		t.Fatalf("非法 JSON 应返回 400，实际: %d", status)
+	}
+}
+
+func TestHandleRenderPost_PNG(t *testing.T) {
+	app := newTestApp(t)
+	status, contentType, raw := postRender(t, app, `{"tex":"x","format":"png","dpi":192,"background":"#ffffff"}`)
+	if status != fiber.StatusOK || contentType != pngContentType {
+		t.Fatalf("状态码或类型不符合预期: %d %s", status, contentType)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("响应不是合法 PNG: %v", err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a == 0 {
+		t.Fatal("指定背景色后不应透明")
+	}
+}
+
+func TestHandleRender_JSONMetrics(t *testing.T) {
+	app := newTestApp(t)
+	req := httptest.NewRequest(fiber.MethodGet, "/api/v1/render?tex=x%2By", nil)
+	req.Header.Set("Accept", "application/json")
+
+	for _, wantHit := range []string{"miss", cache.TierMemory} {
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		var out metricsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("响应无法解析: %v", err)
+		}
+		if string(out.CacheHitLevel) != wantHit {
+			t.Fatalf("命中层级应为 %s，实际 %s", wantHit, out.CacheHitLevel)
+		}
+		if out.SVG == "" || out.ViewBox == "" || out.WidthEm <= 0 || out.HeightEm <= 0 {
+			t.Fatalf("度量信息缺失: %+v", out)
+		}
+	}
+}
+
+func TestEntry_RoundTrip(t *testing.T) {
+	entry := renderEntry{
+		Body:       "<svg>\n</svg>",
+		Metrics:    svgutil.Metrics{WidthEm: 1.5, HeightEm: 1, DepthEm: 0.25, ViewBox: "0 -750 1500 1000"},
+		HasMetrics: true,
+	}
+	if got, ok := decodeEntry(encodeEntry(entry)); !ok || got != entry {
+		t.Fatalf("编解码结果不一致: %+v", got)
+	}
+	withMeta := entry
+	withMeta.RendererVersion = "3f2a9c1b7d4e"
+	withMeta.Options = "display=block"
+	withMeta.CreatedAt = time.UnixMilli(1700000000123)
+	withMeta.RenderDuration = 1500 * time.Microsecond
+	if got, ok := decodeEntry(encodeEntry(withMeta)); !ok || got != withMeta {
+		t.Fatalf("编解码结果不一致: %+v", got)
+	}
+	for _, raw := range []string{"<svg/>", entryPrefix + "{broken\n<svg/>", entryPrefix + "{}"} {
+		if _, ok := decodeEntry(raw); ok {
+			t.Fatalf("无法识别的缓存值应解析失败: %q", raw)
+		}
+	}
+
+	encoded, err := compressBody(entry.Body, encodingBrotli)
+	if err != nil {
+		t.Fatalf("压缩失败: %v", err)
+	}
+	compressed := entry
+	compressed.Encoded, compressed.Encoding = encoded, encodingBrotli
+	got, _ := decodeEntry(encodeEntry(compressed))
+	if got.Body != "" || got.Encoded != encoded || got.Metrics != entry.Metrics {
+		t.Fatalf("压缩内容应原样保留: %+v", got)
+	}
+	if err := got.inflate(); err != nil || got.Body != entry.Body {
+		t.Fatalf("解压结果不一致: %q %v", got.Body, err)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	cases := map[string]bool{
+		"":                       false,
+		"gzip, deflate, br":      true,
+		"deflate":                false,
+		"GZIP;q=0.5":             true,
+		"gzip;q=0, *":            false,
+		"*;q=0.1":                true,
+		"br, *;q=0":              false,
+		"identity, gzip ; q=1.0": true,
+	}
+	for header, want := range cases {
+		if got := acceptsEncoding(header, encodingGzip); got != want {
+			t.Fatalf("Accept-Encoding %q 判断错误，期望 %v", header, want)
+		}
+	}
+}
+
+func TestHandleRender_CompressedCacheHit(t *testing.T) {
+	app := newTestApp(t)
+	target := "/api/v1/render?tex=" + url.QueryEscape(`\frac{a+b}{c+d}+\sqrt{x^2+y^2}`)
+
+	var plain string
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(fiber.MethodGet, target, nil)
+		req.Header.Set(fiber.HeaderAcceptEncoding, "gzip")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		if resp.Header.Get(fiber.HeaderContentEncoding) != encodingGzip {
+			t.Fatalf("第 %d 次请求应直接返回 gzip 内容", i+1)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		body, err := decompressBody(string(raw), encodingGzip)
+		if err != nil || !strings.Contains(body, "<svg") {
+			t.Fatalf("gzip 内容无法还原为 SVG: %v", err)
+		}
+		plain = body
+	}
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get(fiber.HeaderContentEncoding) != "" || string(raw) != plain {
+		t.Fatalf("不接受 gzip 的客户端应收到解压后的 SVG")
+	}
+	if !strings.Contains(resp.Header.Get(fiber.HeaderVary), fiber.HeaderAcceptEncoding) {
+		t.Fatalf("响应应携带 Vary: Accept-Encoding")
+	}
+}
+
+func TestHandleRenderPost_MathML(t *testing.T) {
+	app := newTestApp(t)
+	status, contentType, raw := postRender(t, app, `{"tex":"\\frac{a}{b}","format":"mathml","display":"block"}`)
+	if status != fiber.StatusOK || contentType != mathmlContentType {
+		t.Fatalf("状态码或类型不符合预期: %d %s", status, contentType)
+	}
+	if !strings.Contains(string(raw), `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`) {
+		t.Fatalf("MathML 输出不符合预期: %s", raw)
+	}
+
+	status, _, raw = postRender(t, app, `{"tex":"a+\\foo","format":"mathml"}`)
+	var out errorResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("错误响应无法解析: %v", err)
+	}
+	if status != fiber.StatusUnprocessableEntity || out.Code != "unsupported_command" || out.Position == nil || out.Position.Column != 3 {
+		t.Fatalf("不支持的命令应返回结构化错误: %d %s", status, raw)
+	}
+}
+
+func TestHandleRenderPost_Accessibility(t *testing.T) {
+	app := newTestApp(t)
+	_, _, raw := postRender(t, app, `{"tex":"E=mc^2","a11y":true,"lang":"zh"}`)
+	for _, want := range []string{`role="img"`, `aria-label="E 等于 m c 的平方"`, `<title>E 等于 m c 的平方</title>`, `LaTeX: E=mc^2`} {
+		if !strings.Contains(string(raw), want) {
+			t.Fatalf("无障碍信息缺少 %s: %s", want, raw)
+		}
+	}
+
+	_, _, raw = postRender(t, app, `{"tex":"E=mc^2"}`)
+	if strings.Contains(string(raw), `role="img"`) {
+		t.Fatalf("配置默认关闭时不应注入: %s", raw)
+	}
+}
+
+func TestHandleRender_ErrorNegotiation(t *testing.T) {
+	app := newTestApp(t)
+	req := httptest.NewRequest(fiber.MethodGet, "/api/v1/render?tex=a%0Ab%01", nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	var out errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("错误响应无法解析: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest || out.Code != CodeInvalidCharacters {
+		t.Fatalf("非法字符应返回结构化错误: %d %+v", resp.StatusCode, out)
+	}
+	if out.Position == nil || out.Position.Line != 2 || out.Position.Column != 2 {
+		t.Fatalf("错误位置不符合预期: %+v", out.Position)
+	}
+
+	status, contentType, raw := postRender(t, app, `{"tex":"fail"}`)
+	if status != fiber.StatusUnprocessableEntity || contentType != responseContentType {
+		t.Fatalf("未声明 JSON 时应返回错误 SVG: %d %s", status, contentType)
+	}
+	if !strings.Contains(string(raw), "渲染失败") || strings.Contains(string(raw), "请检查输入") {
+		t.Fatalf("错误 SVG 应包含实际错误信息: %s", raw)
+	}
+}
+
+func TestHandleRenderPost_RendererErrorPosition(t *testing.T) {
+	app := newTestApp(t)
+	status, _, raw := postRender(t, app, `{"tex":"a+\\bad","display":"block","format":"json"}`)
+	var out errorResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("错误响应无法解析: %v", err)
+	}
+	if status != fiber.StatusUnprocessableEntity || out.Code != CodeRenderFailed || out.Message != `undefined control sequence \bad` {
+		t.Fatalf("渲染器错误信息应透传: %d %s", status, raw)
+	}
+	// 块级公式的 \displaystyle 前缀不应计入列号
+	if out.Position == nil || out.Position.Column != 3 || out.Position.Offset != 2 {
+		t.Fatalf("错误位置不符合预期: %s", raw)
+	}
+
+	_, _, raw = postRender(t, app, `{"tex":"a+\\bad"}`)
+	if !strings.Contains(string(raw), `undefined control sequence \bad（第 1 行第 3 列）`) {
+		t.Fatalf("错误 SVG 应包含渲染器信息与位置: %s", raw)
+	}
+}
+
+func TestHandleRenderPost_Timeout(t *testing.T) {
+	app := newTestApp(t)
+	status, _, raw := postRender(t, app, `{"tex":"hang","format":"json"}`)
+	var out errorResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("错误响应无法解析: %v", err)
+	}
+	if status != fiber.StatusGatewayTimeout || out.Code != CodeRenderTimeout {
+		t.Fatalf("渲染超时应返回 504 与 render_timeout: %d %s", status, raw)
+	}
+}
+
+func TestSendError_RetryAfter(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		err := &renderer.OverloadError{Reason: renderer.ErrOverloaded, RetryAfter: 1500 * time.Millisecond}
+		return sendError(c, describeError(err, "", ""), formatJSON)
+	})
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable || resp.Header.Get(fiber.HeaderRetryAfter) != "2" {
+		t.Fatalf("过载应返回 503 与 Retry-After: %d %q", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
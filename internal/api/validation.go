@@ -13,6 +13,19 @@ var (
 	ErrEmptyFormula      = errors.New("公式内容为空")
 	ErrFormulaTooLarge   = errors.New("公式内容超过 5KB 限制")
 	ErrInvalidCharacters = errors.New("公式包含非法控制字符")
+	ErrInvalidBody       = errors.New("请求体不是合法的 JSON")
+	ErrBodyTooLarge      = errors.New("请求体超过大小限制")
+	ErrInvalidDisplay    = errors.New("display 仅支持 inline 或 block")
+	ErrInvalidFontSize   = errors.New("font_size 超出允许范围")
+	ErrInvalidColor      = errors.New("color 必须是十六进制色值或颜色名")
+	ErrInvalidScale      = errors.New("scale 超出允许范围")
+	ErrUnsupportedFormat = errors.New("不支持的输出格式")
+	ErrInvalidDPI        = errors.New("dpi 超出允许范围")
+	ErrInvalidBackground = errors.New("background 必须是 transparent、十六进制色值或颜色名")
+	ErrInvalidA11y       = errors.New("a11y 必须是布尔值")
+	ErrInvalidLang       = errors.New("lang 仅支持 en 或 zh")
+	ErrEmptyBatch        = errors.New("批量请求至少需要一个公式")
+	ErrTooManyItems      = errors.New("批量请求的公式数量超过限制")
 )
 
 func validateFormula(raw string) (string, error) {
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/cache"
+)
+
+// 预热任务状态
+const (
+	warmupRunning  = "running"
+	warmupDone     = "done"
+	warmupCanceled = "canceled"
+)
+
+const (
+	// warmupHistory 为保留的已结束任务数量，更早的任务无法再查询
+	warmupHistory = 20
+	// warmupMaxErrors 为每个任务记录的失败明细上限
+	warmupMaxErrors = 20
+)
+
+// warmupError 记录预热中单个公式的失败原因
+type warmupError struct {
+	Index   int    `json:"index"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// warmupProgress 是预热任务的进度快照
+type warmupProgress struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Total  int    `json:"total"`
+	// Processed 为已处理的公式数，Cached 为处理时已在缓存中的数量
+	Processed  int           `json:"processed"`
+	Cached     int           `json:"cached"`
+	Rendered   int           `json:"rendered"`
+	Failed     int           `json:"failed"`
+	Errors     []warmupError `json:"errors,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
+
+// warmupJob 是一次在后台执行的缓存预热
+type warmupJob struct {
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	progress warmupProgress
+}
+
+func (j *warmupJob) snapshot() warmupProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	progress := j.progress
+	progress.Errors = append([]warmupError(nil), j.progress.Errors...)
+	return progress
+}
+
+func (j *warmupJob) status() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress.Status
+}
+
+func (j *warmupJob) record(index int, cached bool, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress.Processed++
+	switch {
+	case err != nil:
+		j.progress.Failed++
+		if len(j.progress.Errors) < warmupMaxErrors {
+			resp := describeError(err, "", "")
+			j.progress.Errors = append(j.progress.Errors, warmupError{Index: index, Code: resp.Code, Message: resp.Message})
+		}
+	case cached:
+		j.progress.Cached++
+	default:
+		j.progress.Rendered++
+	}
+}
+
+func (j *warmupJob) finish(status string) warmupProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.progress.Status = status
+	j.progress.FinishedAt = &now
+	return j.progress
+}
+
+// warmupRegistry 管理预热任务，进行中的任务全部保留，已结束的只保留最近 warmupHistory 个
+type warmupRegistry struct {
+	render   *RenderHandler
+	maxItems int
+	workers  int
+	audit    *zap.Logger
+
+	mu    sync.Mutex
+	jobs  map[string]*warmupJob
+	order []string
+}
+
+func newWarmupRegistry(render *RenderHandler, maxItems, workers int, audit *zap.Logger) *warmupRegistry {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &warmupRegistry{
+		render:   render,
+		maxItems: maxItems,
+		workers:  workers,
+		audit:    audit,
+		jobs:     make(map[string]*warmupJob),
+	}
+}
+
+// start 创建任务并在后台渲染，立即返回任务 ID
+func (r *warmupRegistry) start(items []renderRequest) *warmupJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &warmupJob{
+		cancel: cancel,
+		progress: warmupProgress{
+			ID:        uuid.NewString(),
+			Status:    warmupRunning,
+			Total:     len(items),
+			StartedAt: time.Now(),
+		},
+	}
+
+	r.mu.Lock()
+	r.jobs[job.progress.ID] = job
+	r.order = append(r.order, job.progress.ID)
+	r.pruneLocked()
+	r.mu.Unlock()
+
+	go r.run(ctx, job, items)
+	return job
+}
+
+// run 以 workers 为上限并发预热；取消后不再处理剩余公式
+func (r *warmupRegistry) run(ctx context.Context, job *warmupJob, items []renderRequest) {
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < r.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				cached, err := r.warm(ctx, items[i])
+				job.record(i, cached, err)
+			}
+		}()
+	}
+
+	status := warmupDone
+feed:
+	for i := range items {
+		select {
+		case <-ctx.Done():
+			status = warmupCanceled
+			break feed
+		case queue <- i:
+		}
+	}
+	close(queue)
+	wg.Wait()
+	job.cancel()
+
+	progress := job.finish(status)
+	r.audit.Info("缓存预热结束",
+		zap.String("job_id", progress.ID),
+		zap.String("status", progress.Status),
+		zap.Int("total", progress.Total),
+		zap.Int("processed", progress.Processed),
+		zap.Int("cached", progress.Cached),
+		zap.Int("rendered", progress.Rendered),
+		zap.Int("failed", progress.Failed),
+		zap.Duration("duration", progress.FinishedAt.Sub(progress.StartedAt)),
+	)
+}
+
+// warm 渲染单个公式写入缓存，已有新鲜缓存时跳过；返回值表示是否已在缓存中
+func (r *warmupRegistry) warm(ctx context.Context, item renderRequest) (bool, error) {
+	h := r.render
+	opts, err := h.withDefaults(item.options()).normalize()
+	if err != nil {
+		return false, err
+	}
+	normalized, err := validateFormula(item.Tex)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+	defer cancel()
+	key, version := h.cacheKey(normalized, opts)
+	if _, hitLevel := h.lookup(ctx, key, version, ""); hitLevel != cache.HitNone && !hitLevel.Stale() {
+		return true, nil
+	}
+	res, _ := h.renderShared(ctx, key, version, normalized, opts)
+	return false, res.err
+}
+
+func (r *warmupRegistry) get(id string) (*warmupJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+// list 按创建顺序返回所有保留的任务进度
+func (r *warmupRegistry) list() []warmupProgress {
+	r.mu.Lock()
+	jobs := make([]*warmupJob, 0, len(r.order))
+	for _, id := range r.order {
+		jobs = append(jobs, r.jobs[id])
+	}
+	r.mu.Unlock()
+
+	progress := make([]warmupProgress, 0, len(jobs))
+	for _, job := range jobs {
+		progress = append(progress, job.snapshot())
+	}
+	return progress
+}
+
+func (r *warmupRegistry) cancelAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		job.cancel()
+	}
+}
+
+// pruneLocked 删除超出保留数量的已结束任务，调用方需持有 r.mu
+func (r *warmupRegistry) pruneLocked() {
+	finished := 0
+	for _, id := range r.order {
+		if r.jobs[id].status() != warmupRunning {
+			finished++
+		}
+	}
+	kept := r.order[:0]
+	for _, id := range r.order {
+		if finished > warmupHistory && r.jobs[id].status() != warmupRunning {
+			delete(r.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
+
+// handleWarmup 接收待预热的公式列表：JSON 格式同批量接口的 items，
+// 或以 text/plain 上传、每行一个公式并使用默认选项
+func (h *AdminHandler) handleWarmup(c *fiber.Ctx) error {
+	requestID := requestIDFromCtx(c)
+	body := c.Body()
+	if h.maxBodyBytes > 0 && len(body) > h.maxBodyBytes {
+		return h.reject(c, "warmup", ErrBodyTooLarge)
+	}
+
+	var items []renderRequest
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMETextPlain) {
+		scanner := bufio.NewScanner(strings.NewReader(string(body)))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxFormulaBytes*2)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				items = append(items, renderRequest{Tex: line})
+			}
+		}
+		if scanner.Err() != nil {
+			return h.reject(c, "warmup", ErrFormulaTooLarge)
+		}
+	} else {
+		var req batchRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return h.reject(c, "warmup", ErrInvalidBody)
+		}
+		items = req.Items
+	}
+	if len(items) == 0 {
+		return h.reject(c, "warmup", ErrEmptyBatch)
+	}
+	if h.warmups.maxItems > 0 && len(items) > h.warmups.maxItems {
+		return h.reject(c, "warmup", ErrTooManyItems)
+	}
+
+	progress := h.warmups.start(items).snapshot()
+	h.audit.Info("管理操作",
+		zap.String("action", "warmup"),
+		zap.String("request_id", requestID),
+		zap.String("ip", c.IP()),
+		zap.String("job_id", progress.ID),
+		zap.Int("items", len(items)),
+	)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"request_id": requestID, "job": progress})
+}
+
+func (h *AdminHandler) handleWarmupList(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"request_id": requestIDFromCtx(c), "jobs": h.warmups.list()})
+}
+
+func (h *AdminHandler) handleWarmupStatus(c *fiber.Ctx) error {
+	job, ok := h.warmups.get(c.Params("id"))
+	if !ok {
+		return h.reject(c, "warmup_status", ErrJobNotFound)
+	}
+	return c.JSON(fiber.Map{"request_id": requestIDFromCtx(c), "job": job.snapshot()})
+}
+
+// handleWarmupCancel 取消进行中的预热，已开始渲染的公式仍会完成
+func (h *AdminHandler) handleWarmupCancel(c *fiber.Ctx) error {
+	requestID := requestIDFromCtx(c)
+	job, ok := h.warmups.get(c.Params("id"))
+	if !ok {
+		return h.reject(c, "warmup_cancel", ErrJobNotFound)
+	}
+	job.cancel()
+	h.audit.Info("管理操作",
+		zap.String("action", "warmup_cancel"),
+		zap.String("request_id", requestID),
+		zap.String("ip", c.IP()),
+		zap.String("job_id", job.snapshot().ID),
+	)
+	return c.JSON(fiber.Map{"request_id": requestID, "job": job.snapshot()})
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	bigcache "github.com/allegro/bigcache/v3"
+
+	"mathsvg/internal/config"
+)
+
+// entryOverhead 是 BigCache 为每个条目附加的时间戳、哈希与长度头部，外加队列长度前缀的余量
+const entryOverhead = 18 + 8
+
+// BigCacheTier 是进程内的一级缓存。超出单个分片容量的条目写入大对象区，
+// 避免 BigCache 为容纳它清空整个分片后仍然写入失败
+type BigCacheTier struct {
+	cache *bigcache.BigCache
+	large *largeStore
+	// shardBytes 为单个分片的容量上限，0 表示不限制
+	shardBytes int
+	policy     TierPolicy
+
+	rejected atomic.Uint64
+}
+
+// NewBigCacheTier 按 cache.local_* 配置创建 BigCache；启用 stale-while-revalidate 时条目额外保留 local_stale_window
+func NewBigCacheTier(cfg config.Cache) (*BigCacheTier, error) {
+	policy := TierPolicy{
+		TTL:           cfg.LocalLifeWindow,
+		StaleWindow:   cfg.LocalStaleWindow,
+		TouchInterval: cfg.LocalTouchInterval,
+	}
+	local, err := bigcache.NewBigCache(bigcache.Config{
+		Shards:             cfg.LocalShards,
+		LifeWindow:         policy.retention(cfg.StaleWhileRevalidate),
+		CleanWindow:        cfg.LocalCleanWindow,
+		MaxEntriesInWindow: cfg.LocalMaxEntriesInWindow,
+		MaxEntrySize:       cfg.LocalMaxEntrySize,
+		Verbose:            false,
+		HardMaxCacheSize:   cfg.LocalHardMaxCacheMB,
+		StatsEnabled:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := &BigCacheTier{cache: local, policy: policy}
+	if cfg.LocalHardMaxCacheMB > 0 && cfg.LocalShards > 0 {
+		t.shardBytes = cfg.LocalHardMaxCacheMB << 20 / cfg.LocalShards
+	}
+	if cfg.LocalLargeMaxMB > 0 {
+		t.large = newLargeStore(int64(cfg.LocalLargeMaxMB)<<20, policy.retention(cfg.StaleWhileRevalidate))
+	}
+	return t, nil
+}
+
+func (t *BigCacheTier) Name() string { return string(HitLocal) }
+
+func (t *BigCacheTier) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := t.cache.Get(key)
+	if !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return data, err
+	}
+	if t.large != nil {
+		if value, ok := t.large.get(key); ok {
+			return value, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (t *BigCacheTier) Set(_ context.Context, key string, value []byte) error {
+	if !t.oversized(key, value) {
+		if t.large != nil {
+			t.large.delete(key)
+		}
+		return t.cache.Set(key, value)
+	}
+
+	// 同一键的旧值可能仍在 BigCache 中，先删除以免读到过期内容
+	_ = t.cache.Delete(key)
+	if t.large == nil || !t.large.set(key, value) {
+		t.rejected.Add(1)
+		return fmt.Errorf("%w: %d 字节", ErrEntryTooLarge, len(value))
+	}
+	return nil
+}
+
+func (t *BigCacheTier) Delete(_ context.Context, key string) error {
+	if t.large != nil {
+		t.large.delete(key)
+	}
+	if err := t.cache.Delete(key); err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Touch 在进程内重写条目：BigCache 没有单独续期的接口，只能重新写入
+func (t *BigCacheTier) Touch(_ context.Context, key string, header []byte) error {
+	if t.large != nil && t.large.touch(key, header) {
+		return nil
+	}
+	data, err := t.cache.Get(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	value, ok := restamp(data, header)
+	if !ok {
+		return ErrNotFound
+	}
+	return t.cache.Set(key, value)
+}
+
+func (t *BigCacheTier) Len() int {
+	n := t.cache.Len()
+	if t.large != nil {
+		entries, _ := t.large.stats()
+		n += entries
+	}
+	return n
+}
+
+func (t *BigCacheTier) Policy() TierPolicy { return t.policy }
+
+func (t *BigCacheTier) Stats() TierStats {
+	stats := t.cache.Stats()
+	tierStats := TierStats{
+		Name:     t.Name(),
+		Entries:  t.cache.Len(),
+		Hits:     uint64(stats.Hits),
+		Misses:   uint64(stats.Misses),
+		Rejected: t.rejected.Load(),
+		Alive:    true,
+	}
+	if t.large != nil {
+		tierStats.LargeEntries, tierStats.LargeBytes = t.large.stats()
+		tierStats.Entries += tierStats.LargeEntries
+	}
+	return tierStats
+}
+
+func (t *BigCacheTier) Close() error { return t.cache.Close() }
+
+// hottest 按命中次数从高到低返回至多 limit 个条目，BigCache 的逐键命中数依赖 StatsEnabled
+func (t *BigCacheTier) hottest(limit int) []hotEntry {
+	var entries []hotEntry
+	it := t.cache.Iterator()
+	for it.SetNext() {
+		info, err := it.Value()
+		if err != nil {
+			continue
+		}
+		key := info.Key()
+		entries = append(entries, hotEntry{key: key, value: info.Value(), hits: t.cache.KeyMetadata(key).RequestCount})
+	}
+	if t.large != nil {
+		entries = append(entries, t.large.hotEntries()...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].hits > entries[j].hits })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// oversized 判断条目连同头部是否超出单个分片容量
+func (t *BigCacheTier) oversized(key string, value []byte) bool {
+	return t.shardBytes > 0 && len(key)+len(value)+entryOverhead > t.shardBytes
+}
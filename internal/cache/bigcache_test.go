@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mathsvg/internal/config"
+)
+
+func newTestBigCacheTier(t *testing.T, largeMB int) *BigCacheTier {
+	t.Helper()
+	// 1MB / 256 分片，单个分片约 4KB
+	tier, err := NewBigCacheTier(config.Cache{
+		LocalShards:             256,
+		LocalLifeWindow:         time.Minute,
+		LocalCleanWindow:        time.Minute,
+		LocalMaxEntriesInWindow: 1000,
+		LocalMaxEntrySize:       512,
+		LocalHardMaxCacheMB:     1,
+		LocalLargeMaxMB:         largeMB,
+	})
+	if err != nil {
+		t.Fatalf("BigCache 初始化失败: %v", err)
+	}
+	t.Cleanup(func() { _ = tier.Close() })
+	return tier
+}
+
+func TestBigCacheTier_LargeEntry(t *testing.T) {
+	tier := newTestBigCacheTier(t, 1)
+	ctx := context.Background()
+	large := []byte("<svg>" + strings.Repeat("x", 8<<10) + "</svg>")
+
+	if err := tier.Set(ctx, "small", []byte("<svg/>")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := tier.Set(ctx, "large", large); err != nil {
+		t.Fatalf("超出分片容量的条目应写入大对象区: %v", err)
+	}
+	if value, err := tier.Get(ctx, "large"); err != nil || len(value) != len(large) {
+		t.Fatalf("大条目读取失败: %v", err)
+	}
+	// 同一键改为小条目后不应再读到大对象区中的旧值
+	_ = tier.Set(ctx, "large", []byte("<svg>new</svg>"))
+	if value, _ := tier.Get(ctx, "large"); string(value) != "<svg>new</svg>" {
+		t.Fatalf("应读到最新写入的值，实际长度: %d", len(value))
+	}
+
+	stats := tier.Stats()
+	if stats.Entries != 2 || stats.LargeEntries != 0 || stats.Rejected != 0 {
+		t.Fatalf("统计不符合预期: %+v", stats)
+	}
+}
+
+func TestBigCacheTier_RejectTooLarge(t *testing.T) {
+	tier := newTestBigCacheTier(t, 0)
+	err := tier.Set(context.Background(), "large", make([]byte, 8<<10))
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("未启用大对象区时应拒绝写入，实际: %v", err)
+	}
+	if tier.Stats().Rejected != 1 {
+		t.Fatalf("应记录一次拒绝")
+	}
+}
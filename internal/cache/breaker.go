@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// breaker 是简单的熔断器：连续失败达到阈值后断开，断开期间直接跳过远程调用，
+// 由后台探活成功后再闭合，避免抖动的远程缓存给每次未命中都叠加超时等待
+type breaker struct {
+	threshold int32
+	failures  atomic.Int32
+	open      atomic.Bool
+}
+
+func newBreaker(threshold int) *breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &breaker{threshold: int32(threshold)}
+}
+
+// allow 判断当前是否允许发起调用
+func (b *breaker) allow() bool { return !b.open.Load() }
+
+// success 清零连续失败次数
+func (b *breaker) success() { b.failures.Store(0) }
+
+// failure 记录一次失败，返回 true 表示本次失败使熔断器由闭合转为断开
+func (b *breaker) failure() bool {
+	if b.failures.Add(1) < b.threshold {
+		return false
+	}
+	return !b.open.Swap(true)
+}
+
+// trip 直接断开熔断器，返回 true 表示此前处于闭合状态
+func (b *breaker) trip() bool { return !b.open.Swap(true) }
+
+// reset 闭合熔断器
+func (b *breaker) reset() {
+	b.failures.Store(0)
+	b.open.Store(false)
+}
+
+// backoff 按指数退避计算第 attempt 次重试前的等待时间，不超过 max
+func backoff(attempt int, min, max time.Duration) time.Duration {
+	if min <= 0 {
+		min = time.Second
+	}
+	if max < min {
+		max = min
+	}
+	wait := min
+	for i := 0; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
@@ -0,0 +1,372 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+// tempPrefix 标记尚未完成的写入，启动时清理崩溃遗留的临时文件
+const tempPrefix = ".tmp-"
+
+// generationFile 位于缓存目录顶层，保存没有 Redis 时的命名空间代数，不计入条目索引
+const generationFile = "generation"
+
+// DiskTier 将渲染结果按缓存键落盘，重启后仍可命中。
+// 文件按键的前两位分片存放，总大小超过上限时按最近最少使用淘汰。
+// prefork 子进程共用同一目录：读到其他进程写入的文件时补入索引，并定期重新扫描目录，
+// 使每个进程都按目录中的全部文件统计大小
+type DiskTier struct {
+	dir      string
+	maxBytes int64
+	policy   TierPolicy
+	logger   *zap.Logger
+
+	// lru 头部为最近访问的条目，index 以文件名索引链表节点
+	mu    sync.Mutex
+	lru   *list.List
+	index map[string]*list.Element
+	size  int64
+
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+	rejected atomic.Uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type diskEntry struct {
+	name string
+	size int64
+}
+
+// NewDiskTier 按 cache.disk_* 配置打开磁盘缓存，并扫描已有文件重建索引
+func NewDiskTier(cfg config.Cache, logger *zap.Logger) (*DiskTier, error) {
+	t, err := newDiskTier(cfg.DiskDir, int64(cfg.DiskMaxSizeMB)<<20, logger)
+	if err != nil {
+		return nil, err
+	}
+	t.policy = TierPolicy{
+		TTL:           cfg.DiskTTL,
+		StaleWindow:   cfg.DiskStaleWindow,
+		TouchInterval: cfg.DiskTouchInterval,
+	}
+	t.startRescan(cfg.DiskRescanInterval)
+	return t, nil
+}
+
+func newDiskTier(dir string, maxBytes int64, logger *zap.Logger) (*DiskTier, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("未配置 cache.disk_dir")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	t := &DiskTier{
+		dir:      dir,
+		maxBytes: maxBytes,
+		logger:   logger,
+		lru:      list.New(),
+		index:    make(map[string]*list.Element),
+		done:     make(chan struct{}),
+	}
+	// 启动时清理崩溃遗留的临时文件；此后的扫描跳过临时文件，以免删除其他进程正在写入的文件
+	if err := t.rescan(true); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// rescan 扫描缓存目录重建索引，按修改时间恢复访问顺序（命中时会更新修改时间），随后按总大小淘汰
+func (t *DiskTier) rescan(cleanTemp bool) error {
+	type found struct {
+		diskEntry
+		modTime time.Time
+	}
+	var entries []found
+	err := filepath.WalkDir(t.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 文件或分片目录可能刚被其他进程删除
+			if errors.Is(err, fs.ErrNotExist) && path != t.dir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), tempPrefix) {
+			if cleanTemp {
+				_ = os.Remove(path)
+			}
+			return nil
+		}
+		if filepath.Dir(path) == t.dir {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, found{diskEntry{name: d.Name(), size: info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lru.Init()
+	t.index = make(map[string]*list.Element, len(entries))
+	t.size = 0
+	for _, e := range entries {
+		entry := e.diskEntry
+		t.index[entry.name] = t.lru.PushFront(&entry)
+		t.size += entry.size
+	}
+	t.evict()
+	return nil
+}
+
+// startRescan 按 interval 在后台重新扫描目录，interval 为 0 时不启动
+func (t *DiskTier) startRescan(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+			}
+			if err := t.rescan(false); err != nil {
+				t.logger.Warn("重新扫描磁盘缓存目录失败", zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (t *DiskTier) Name() string { return string(HitDisk) }
+
+func (t *DiskTier) Get(_ context.Context, key string) ([]byte, error) {
+	name := diskName(key)
+	path := t.path(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.misses.Add(1)
+		if errors.Is(err, fs.ErrNotExist) {
+			// 可能已被其他 prefork 子进程淘汰
+			t.mu.Lock()
+			t.remove(name)
+			t.mu.Unlock()
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	t.hits.Add(1)
+
+	t.mu.Lock()
+	if elem, ok := t.index[name]; ok {
+		t.lru.MoveToFront(elem)
+	} else {
+		// 其他 prefork 子进程写入的文件，补入索引后才会计入大小并参与淘汰
+		t.index[name] = t.lru.PushFront(&diskEntry{name: name, size: int64(len(data))})
+		t.size += int64(len(data))
+		t.evict()
+	}
+	t.mu.Unlock()
+	// 更新修改时间，重启后按此恢复访问顺序
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, nil
+}
+
+// Set 先写临时文件并 fsync，再原子重命名，进程崩溃不会留下半截条目
+func (t *DiskTier) Set(_ context.Context, key string, value []byte) error {
+	size := int64(len(value))
+	if size > t.maxBytes {
+		t.rejected.Add(1)
+		return fmt.Errorf("%w: %d 字节", ErrEntryTooLarge, size)
+	}
+
+	name := diskName(key)
+	if err := writeAtomic(t.path(name), value); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remove(name)
+	entry := &diskEntry{name: name, size: size}
+	t.index[name] = t.lru.PushFront(entry)
+	t.size += size
+	t.evict()
+	return nil
+}
+
+func (t *DiskTier) Delete(_ context.Context, key string) error {
+	name := diskName(key)
+	t.mu.Lock()
+	t.remove(name)
+	t.mu.Unlock()
+	if err := os.Remove(t.path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Touch 只改写文件开头的新鲜截止时间标记，不重写内容
+func (t *DiskTier) Touch(_ context.Context, key string, header []byte) error {
+	f, err := os.OpenFile(t.path(diskName(key)), os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, len(stampMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != stampMagic {
+		_ = f.Close()
+		return ErrNotFound
+	}
+	_, err = f.WriteAt(header, 0)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (t *DiskTier) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.index)
+}
+
+func (t *DiskTier) Policy() TierPolicy { return t.policy }
+
+func (t *DiskTier) Stats() TierStats {
+	t.mu.Lock()
+	entries, size := len(t.index), t.size
+	t.mu.Unlock()
+	return TierStats{
+		Name:     t.Name(),
+		Entries:  entries,
+		Bytes:    size,
+		Hits:     t.hits.Load(),
+		Misses:   t.misses.Load(),
+		Rejected: t.rejected.Load(),
+		Alive:    true,
+	}
+}
+
+// Close 停止后台扫描，文件保留供下次启动使用
+func (t *DiskTier) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return nil
+}
+
+// evict 从最久未访问的条目开始删除，直到总大小不超过上限，需持有 mu
+func (t *DiskTier) evict() {
+	for t.size > t.maxBytes {
+		elem := t.lru.Back()
+		if elem == nil {
+			return
+		}
+		entry := elem.Value.(*diskEntry)
+		t.remove(entry.name)
+		if err := os.Remove(t.path(entry.name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			t.logger.Warn("磁盘缓存淘汰失败", zap.String("file", entry.name), zap.Error(err))
+		}
+	}
+}
+
+// remove 仅从索引中移除条目，需持有 mu
+func (t *DiskTier) remove(name string) {
+	elem, ok := t.index[name]
+	if !ok {
+		return
+	}
+	t.size -= elem.Value.(*diskEntry).size
+	t.lru.Remove(elem)
+	delete(t.index, name)
+}
+
+// loadGeneration 读取保存的命名空间代数，文件不存在时为 0
+func (t *DiskTier) loadGeneration() (uint64, error) {
+	raw, err := os.ReadFile(filepath.Join(t.dir, generationFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+// saveGeneration 持久化命名空间代数，重启后清空全部缓存的效果依然有效
+func (t *DiskTier) saveGeneration(generation uint64) error {
+	return writeAtomic(filepath.Join(t.dir, generationFile), []byte(strconv.FormatUint(generation, 10)))
+}
+
+// writeAtomic 先写同目录的临时文件并 fsync，再原子重命名
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// path 按文件名前两位分片，避免单个目录文件过多
+func (t *DiskTier) path(name string) string {
+	return filepath.Join(t.dir, name[:2], name)
+}
+
+// diskName 缓存键本身是 SHA-256 十六进制串时直接作为文件名，否则取其哈希
+func diskName(key string) string {
+	if len(key) == sha256.Size*2 {
+		if _, err := hex.DecodeString(key); err == nil {
+			return strings.ToLower(key)
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
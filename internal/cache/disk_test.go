@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+func TestDiskTier_EvictLeastRecentlyUsed(t *testing.T) {
+	tier, err := newDiskTier(t.TempDir(), 10, zap.NewNop())
+	if err != nil {
+		t.Fatalf("磁盘缓存初始化失败: %v", err)
+	}
+	ctx := context.Background()
+
+	_ = tier.Set(ctx, "a", []byte("1111"))
+	_ = tier.Set(ctx, "b", []byte("2222"))
+	if _, err := tier.Get(ctx, "a"); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	// 超出上限时应淘汰最久未访问的 b
+	_ = tier.Set(ctx, "c", []byte("3333"))
+
+	if _, err := tier.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("b 应被淘汰，实际: %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := tier.Get(ctx, key); err != nil {
+			t.Fatalf("%s 不应被淘汰: %v", key, err)
+		}
+	}
+	if stats := tier.Stats(); stats.Entries != 2 || stats.Bytes != 8 {
+		t.Fatalf("统计不符合预期: %+v", stats)
+	}
+	if err := tier.Set(ctx, "big", []byte(strings.Repeat("x", 11))); err == nil {
+		t.Fatalf("超过上限的条目应拒绝写入")
+	}
+}
+
+func TestDiskTier_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	key := strings.Repeat("ab", 32)
+
+	tier, err := newDiskTier(dir, 1<<20, zap.NewNop())
+	if err != nil {
+		t.Fatalf("磁盘缓存初始化失败: %v", err)
+	}
+	if err := tier.Set(ctx, key, []byte("<svg/>")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ab", key)); err != nil {
+		t.Fatalf("缓存键应直接作为分片目录下的文件名: %v", err)
+	}
+	// 模拟写入过程中崩溃遗留的临时文件
+	leftover := filepath.Join(dir, "ab", tempPrefix+"123")
+	_ = os.WriteFile(leftover, []byte("<sv"), 0o644)
+
+	reopened, err := newDiskTier(dir, 1<<20, zap.NewNop())
+	if err != nil {
+		t.Fatalf("重新打开失败: %v", err)
+	}
+	value, err := reopened.Get(ctx, key)
+	if err != nil || string(value) != "<svg/>" {
+		t.Fatalf("重启后应能命中: %q %v", value, err)
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("临时文件不应计入索引，条目数: %d", reopened.Len())
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("启动时应清理遗留的临时文件")
+	}
+}
+
+func TestDiskTier_TouchRewritesStampOnly(t *testing.T) {
+	tier, err := newDiskTier(t.TempDir(), 1<<20, zap.NewNop())
+	if err != nil {
+		t.Fatalf("磁盘缓存初始化失败: %v", err)
+	}
+	ctx := context.Background()
+	until := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	_ = tier.Set(ctx, "a", stamp([]byte("<svg/>"), time.Now().Add(time.Minute)))
+
+	if err := tier.Touch(ctx, "a", stamp(nil, until)); err != nil {
+		t.Fatalf("续期失败: %v", err)
+	}
+	raw, err := tier.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if value, got := unstamp(raw); string(value) != "<svg/>" || !got.Equal(until) {
+		t.Fatalf("续期后内容或截止时间不符: %q %v", value, got)
+	}
+
+	_ = tier.Set(ctx, "plain", []byte("<svg/>"))
+	for _, key := range []string{"missing", "plain"} {
+		if err := tier.Touch(ctx, key, stamp(nil, until)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s 应返回 ErrNotFound，实际: %v", key, err)
+		}
+	}
+}
+
+func TestManager_PurgeAllSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	open := func() *Manager {
+		disk, err := newDiskTier(dir, 1<<20, zap.NewNop())
+		if err != nil {
+			t.Fatalf("磁盘缓存初始化失败: %v", err)
+		}
+		m := NewManagerWithTiers(config.Cache{}, []Tier{NewMemoryTier(TierMemory, TierPolicy{}), disk}, zap.NewNop())
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	}
+
+	m := open()
+	m.Set(ctx, "k", "<svg/>")
+	waitFor(t, "写入磁盘", func() bool { return m.tiers[1].Len() == 1 })
+	if generation, err := m.PurgeAll(ctx); err != nil || generation != 1 {
+		t.Fatalf("清空全部缓存失败: %d %v", generation, err)
+	}
+	_ = m.Close()
+
+	// 重启后代数应从磁盘恢复，磁盘中旧代数下的条目不再命中
+	restarted := open()
+	if restarted.Generation() != 1 {
+		t.Fatalf("重启后应恢复命名空间代数，实际: %d", restarted.Generation())
+	}
+	if _, level := restarted.Get(ctx, "k"); level != HitNone {
+		t.Fatalf("已清空的条目在重启后不应重新命中，实际: %s", level)
+	}
+	if restarted.tiers[1].Len() != 1 {
+		t.Fatalf("代数文件不应计入磁盘条目: %d", restarted.tiers[1].Len())
+	}
+}
+
+func TestDiskTier_SharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	// 模拟两个 prefork 子进程共用同一目录，各自只写入不超过上限的内容
+	a, err := newDiskTier(dir, 10, zap.NewNop())
+	if err != nil {
+		t.Fatalf("磁盘缓存初始化失败: %v", err)
+	}
+	b, err := newDiskTier(dir, 10, zap.NewNop())
+	if err != nil {
+		t.Fatalf("磁盘缓存初始化失败: %v", err)
+	}
+	_ = a.Set(ctx, "a1", []byte("1111"))
+	_ = a.Set(ctx, "a2", []byte("2222"))
+	_ = b.Set(ctx, "b1", []byte("3333"))
+
+	// 读到对方写入的文件时补入索引并计入大小
+	if _, err := a.Get(ctx, "b1"); err != nil {
+		t.Fatalf("应能读到其他进程写入的文件: %v", err)
+	}
+	if stats := a.Stats(); stats.Entries != 2 || stats.Bytes != 8 {
+		t.Fatalf("补入索引后应按上限淘汰: %+v", stats)
+	}
+
+	_ = b.Set(ctx, "b2", []byte("4444"))
+	_ = a.Set(ctx, "a3", []byte("5555"))
+	if err := b.rescan(false); err != nil {
+		t.Fatalf("重新扫描失败: %v", err)
+	}
+	var total int64
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			info, _ := d.Info()
+			total += info.Size()
+		}
+		return nil
+	})
+	if total > 10 {
+		t.Fatalf("重新扫描后目录总大小应不超过上限，实际: %d", total)
+	}
+	if stats := b.Stats(); stats.Bytes != total {
+		t.Fatalf("重新扫描后应按目录中的全部文件统计: %+v %d", stats, total)
+	}
+}
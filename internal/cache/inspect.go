@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// EntryInfo 描述缓存键在某一层中的状态，供管理接口排查
+type EntryInfo struct {
+	Tier    string `json:"tier"`
+	Present bool   `json:"present"`
+	Bytes   int    `json:"bytes,omitempty"`
+	// FreshUntil 为空表示永不过期；Expired 表示已过新鲜期，Stale 表示仍可作为旧值返回
+	FreshUntil *time.Time `json:"fresh_until,omitempty"`
+	Expired    bool       `json:"expired,omitempty"`
+	Stale      bool       `json:"stale,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Value 为去掉新鲜截止时间后的缓存值，由调用方自行解析
+	Value []byte `json:"-"`
+}
+
+// Inspect 逐层读取缓存键，不回填、不续期也不删除过期条目；返回实际查询的带命名空间代数的键。
+// 读取仍会计入各层的命中统计
+func (m *Manager) Inspect(ctx context.Context, key string) (string, []EntryInfo) {
+	key = m.namespaced(key)
+	now := time.Now()
+	infos := make([]EntryInfo, len(m.tiers))
+	for i, tier := range m.tiers {
+		info := EntryInfo{Tier: tier.Name()}
+		raw, err := tier.Get(ctx, key)
+		switch {
+		case err == nil:
+			value, until := unstamp(raw)
+			info.Present, info.Bytes, info.Value = true, len(value), value
+			if !until.IsZero() {
+				info.FreshUntil = &until
+				info.Expired = now.After(until)
+				info.Stale = info.Expired && m.swr && now.Before(until.Add(m.policies[i].StaleWindow))
+			}
+		case errors.Is(err, ErrNotFound):
+		default:
+			info.Error = err.Error()
+		}
+		infos[i] = info
+	}
+	return key, infos
+}
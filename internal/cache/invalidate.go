@@ -0,0 +1,330 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// generationKey 记录缓存命名空间的当前代数，清空全部缓存时自增
+const generationKey = "cache-generation"
+
+// invalidationPing 为订阅连接空闲时的探活间隔，及时发现静默断开的连接
+const invalidationPing = 30 * time.Second
+
+// invalidationMessage 是失效频道中的消息：Keys 为需要逐出的完整缓存键，
+// Generation 非 0 表示命名空间代数已提升，旧代数下的条目全部作废
+type invalidationMessage struct {
+	Origin     string   `json:"origin"`
+	Keys       []string `json:"keys,omitempty"`
+	Generation uint64   `json:"generation,omitempty"`
+}
+
+// InvalidationStats 描述跨实例缓存失效的运行指标
+type InvalidationStats struct {
+	Enabled    bool   `json:"enabled"`
+	Subscribed bool   `json:"subscribed"`
+	Generation uint64 `json:"generation"`
+	Published  uint64 `json:"published"`
+	Received   uint64 `json:"received"`
+	Evicted    uint64 `json:"evicted"`
+	// Resubscribes 为订阅断开后重新建立的次数
+	Resubscribes uint64 `json:"resubscribes"`
+}
+
+// invalidation 订阅 Redis 失效频道，收到其他实例或 prefork 子进程的清除消息后逐出本地各层的对应条目
+type invalidation struct {
+	channel string
+	// origin 标识本 Manager，忽略自己发布的消息
+	origin   string
+	probeMin time.Duration
+	probeMax time.Duration
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	subscribed   atomic.Bool
+	published    atomic.Uint64
+	received     atomic.Uint64
+	evicted      atomic.Uint64
+	resubscribes atomic.Uint64
+}
+
+// startInvalidation 订阅失效频道，启动时的命名空间代数已由 NewManagerWithTiers 读取。
+// 订阅断开后由 go-redis 自动重连并重新订阅，每次重新订阅都会再次同步代数，补上断开期间的整体清空
+func (m *Manager) startInvalidation(channel string, probeMin, probeMax time.Duration) {
+	inv := &invalidation{
+		channel:  channel,
+		origin:   uuid.NewString(),
+		probeMin: probeMin,
+		probeMax: probeMax,
+		pubsub:   m.redis.client.Subscribe(context.Background()),
+		done:     make(chan struct{}),
+	}
+	m.invalidation = inv
+
+	inv.wg.Add(1)
+	go m.listenInvalidation(inv)
+}
+
+func (m *Manager) listenInvalidation(inv *invalidation) {
+	defer inv.wg.Done()
+	ctx := context.Background()
+	// 订阅失败时频道仍会被记录，后续 Receive 重连时自动重新订阅
+	_ = inv.pubsub.Subscribe(ctx, inv.channel)
+
+	established := false
+	for attempt := 0; ; {
+		select {
+		case <-inv.done:
+			return
+		default:
+		}
+
+		msg, err := inv.pubsub.ReceiveTimeout(ctx, invalidationPing)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// 空闲超时不代表断开，发送 PING 让 go-redis 在连接失效时重连
+				_ = inv.pubsub.Ping(ctx)
+				continue
+			}
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			if inv.subscribed.Swap(false) {
+				m.logger.Warn("缓存失效订阅断开，等待重连", zap.Error(err))
+			}
+			if !m.waitInvalidation(inv, attempt, err) {
+				return
+			}
+			attempt++
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			// 只订阅了一个频道，之后的每次确认都来自 go-redis 重连后的重新订阅
+			attempt = 0
+			if established {
+				inv.resubscribes.Add(1)
+			}
+			established = true
+			if !inv.subscribed.Swap(true) {
+				m.logger.Info("缓存失效订阅已建立", zap.String("channel", inv.channel))
+			}
+			syncCtx, cancel := context.WithTimeout(ctx, m.redis.dialTimeout+time.Second)
+			m.syncGeneration(syncCtx)
+			cancel()
+		case *redis.Message:
+			m.handleInvalidation(ctx, inv, msg.Payload)
+		}
+	}
+}
+
+// waitInvalidation 按指数退避等待下一次重连，返回 false 表示 Manager 已关闭
+func (m *Manager) waitInvalidation(inv *invalidation, attempt int, err error) bool {
+	m.logger.Debug("缓存失效订阅重连失败", zap.Int("attempt", attempt+1), zap.Error(err))
+	timer := time.NewTimer(backoff(attempt, inv.probeMin, inv.probeMax))
+	defer timer.Stop()
+	select {
+	case <-inv.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// handleInvalidation 逐出消息中的键，或采用更高的命名空间代数；Redis 层本身由发布方清除，这里跳过
+func (m *Manager) handleInvalidation(ctx context.Context, inv *invalidation, payload string) {
+	var msg invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		m.logger.Warn("无法解析缓存失效消息", zap.String("payload", payload), zap.Error(err))
+		return
+	}
+	if msg.Origin == inv.origin {
+		return
+	}
+	inv.received.Add(1)
+
+	if msg.Generation > 0 {
+		m.advanceGeneration(msg.Generation)
+	}
+	for _, key := range msg.Keys {
+		m.evictLocal(ctx, key)
+		inv.evicted.Add(1)
+	}
+}
+
+// evictLocal 从除 Redis 以外的各层删除缓存键
+func (m *Manager) evictLocal(ctx context.Context, key string) {
+	for i, tier := range m.tiers {
+		if i == m.redisLevel {
+			continue
+		}
+		if err := tier.Delete(ctx, key); err != nil {
+			m.logger.Warn("缓存失效逐出失败", zap.String("tier", tier.Name()), zap.Error(err))
+		}
+	}
+}
+
+// publishInvalidation 通知其他实例，未启用失效频道时直接返回
+func (m *Manager) publishInvalidation(ctx context.Context, msg invalidationMessage) error {
+	inv := m.invalidation
+	if inv == nil {
+		return nil
+	}
+	if !m.redis.available() {
+		return ErrUnavailable
+	}
+	msg.Origin = inv.origin
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	err = m.redis.client.Publish(ctx, inv.channel, payload).Err()
+	m.redis.record(ctx, err)
+	if err != nil {
+		return err
+	}
+	inv.published.Add(1)
+	return nil
+}
+
+// PurgeAll 提升命名空间代数使全部缓存条目作废，旧条目随各层过期或淘汰自然清除。
+// 有 Redis 层时代数保存在 Redis 中并广播给其他实例，Redis 不可用时返回 ErrUnavailable；
+// 否则代数保存在磁盘层目录中，保存失败时返回错误且代数不变
+func (m *Manager) PurgeAll(ctx context.Context) (uint64, error) {
+	if m.redis == nil {
+		return m.purgeLocal()
+	}
+	if !m.redis.available() {
+		return 0, ErrUnavailable
+	}
+	generation, err := m.redis.client.Incr(ctx, generationKey).Uint64()
+	m.redis.record(ctx, err)
+	if err != nil {
+		return 0, err
+	}
+	m.advanceGeneration(generation)
+	if err := m.publishInvalidation(ctx, invalidationMessage{Generation: generation}); err != nil {
+		m.logger.Warn("缓存失效消息发布失败，其他实例将在重新订阅时同步", zap.Error(err))
+	}
+	return generation, nil
+}
+
+// Generation 返回当前命名空间代数
+func (m *Manager) Generation() uint64 { return m.generation.Load() }
+
+// Propagates 表示清除与代数提升能否经失效频道同步到其他实例与 prefork 子进程
+func (m *Manager) Propagates() bool { return m.invalidation != nil }
+
+// purgeLocal 在没有 Redis 时提升代数，先落盘再生效，保证重启后不会回退
+func (m *Manager) purgeLocal() (uint64, error) {
+	m.generationMu.Lock()
+	defer m.generationMu.Unlock()
+	generation := m.generation.Load() + 1
+	if m.generationDisk != nil {
+		if err := m.generationDisk.saveGeneration(generation); err != nil {
+			return 0, fmt.Errorf("保存命名空间代数失败: %w", err)
+		}
+	}
+	m.advanceGeneration(generation)
+	return generation, nil
+}
+
+// loadLocalGeneration 从首个磁盘层读取上次保存的命名空间代数；只有内存层时代数随进程重置，
+// 内存层本身也随之清空，不会重新命中已清空的条目
+func (m *Manager) loadLocalGeneration() {
+	for _, tier := range m.tiers {
+		disk, ok := tier.(*DiskTier)
+		if !ok {
+			continue
+		}
+		m.generationDisk = disk
+		generation, err := disk.loadGeneration()
+		if err != nil {
+			m.logger.Warn("读取磁盘缓存中的命名空间代数失败", zap.Error(err))
+			return
+		}
+		m.advanceGeneration(generation)
+		return
+	}
+}
+
+// syncGeneration 从 Redis 读取命名空间代数，启动和每次重新订阅时调用
+func (m *Manager) syncGeneration(ctx context.Context) {
+	generation, err := m.redis.client.Get(ctx, generationKey).Uint64()
+	m.redis.record(ctx, err)
+	switch {
+	case err == nil:
+		m.advanceGeneration(generation)
+	case errors.Is(err, redis.Nil):
+	default:
+		m.logger.Warn("读取缓存命名空间代数失败", zap.Error(err))
+	}
+}
+
+// advanceGeneration 只允许代数增大，避免乱序消息回退到已作废的命名空间
+func (m *Manager) advanceGeneration(generation uint64) {
+	for {
+		current := m.generation.Load()
+		if generation <= current {
+			return
+		}
+		if m.generation.CompareAndSwap(current, generation) {
+			m.logger.Info("缓存命名空间代数提升，旧条目全部作废", zap.Uint64("generation", generation))
+			return
+		}
+	}
+}
+
+// namespaced 为缓存键加上命名空间代数，代数为 0 时保持原样以兼容已有条目
+func (m *Manager) namespaced(key string) string {
+	generation := m.generation.Load()
+	if generation == 0 {
+		return key
+	}
+	return "g" + strconv.FormatUint(generation, 10) + ":" + key
+}
+
+// invalidationStats 汇总失效订阅指标
+func (m *Manager) invalidationStats() InvalidationStats {
+	stats := InvalidationStats{Generation: m.Generation()}
+	inv := m.invalidation
+	if inv == nil {
+		return stats
+	}
+	stats.Enabled = true
+	stats.Subscribed = inv.subscribed.Load()
+	stats.Published = inv.published.Load()
+	stats.Received = inv.received.Load()
+	stats.Evicted = inv.evicted.Load()
+	stats.Resubscribes = inv.resubscribes.Load()
+	return stats
+}
+
+// stopInvalidation 关闭订阅并等待监听协程退出
+func (m *Manager) stopInvalidation() {
+	inv := m.invalidation
+	if inv == nil {
+		return
+	}
+	close(inv.done)
+	_ = inv.pubsub.Close()
+	inv.wg.Wait()
+}
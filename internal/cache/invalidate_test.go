@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+	"mathsvg/internal/pkg/redistest"
+)
+
+func TestManager_InvalidationAcrossInstances(t *testing.T) {
+	server := startRedis(t)
+	a, b := newInvalidationManager(t, server.Addr()), newInvalidationManager(t, server.Addr())
+	ctx := context.Background()
+	waitFor(t, "订阅建立", func() bool { return a.Stats().Invalidation.Subscribed && b.Stats().Invalidation.Subscribed })
+
+	a.Set(ctx, "k", "bad")
+	waitFor(t, "写入 Redis", func() bool { _, ok := server.Get("k"); return ok })
+	if value, level := b.Get(ctx, "k"); value != "bad" || level != HitRedis {
+		t.Fatalf("b 应从 Redis 读取并回填本地: %q %s", value, level)
+	}
+
+	if err := a.Delete(ctx, "k"); err != nil {
+		t.Fatalf("清除失败: %v", err)
+	}
+	waitFor(t, "b 逐出本地副本", func() bool { return b.Stats().Invalidation.Evicted == 1 })
+	if _, level := b.Get(ctx, "k"); level != HitNone {
+		t.Fatalf("清除后 b 不应再命中本地副本，实际: %s", level)
+	}
+	if stats := a.Stats().Invalidation; stats.Published != 1 || stats.Received != 0 {
+		t.Fatalf("发布方应忽略自己的消息: %+v", stats)
+	}
+
+	a.Set(ctx, "k", "v")
+	waitFor(t, "写入 Redis", func() bool { _, ok := server.Get("k"); return ok })
+	b.Get(ctx, "k")
+	generation, err := a.PurgeAll(ctx)
+	if err != nil || generation != 1 {
+		t.Fatalf("清空全部缓存失败: %d %v", generation, err)
+	}
+	waitFor(t, "b 同步命名空间代数", func() bool { return b.Generation() == 1 })
+	if _, level := b.Get(ctx, "k"); level != HitNone {
+		t.Fatalf("代数提升后旧条目应全部作废，实际: %s", level)
+	}
+}
+
+func TestManager_InvalidationSurvivesReconnect(t *testing.T) {
+	server := startRedis(t)
+	addr := server.Addr()
+	m := newInvalidationManager(t, addr)
+	ctx := context.Background()
+	waitFor(t, "订阅建立", func() bool { return m.Stats().Invalidation.Subscribed })
+
+	m.Set(ctx, "k", "v")
+	_ = server.Close()
+	waitFor(t, "发现断开", func() bool { return !m.Stats().Invalidation.Subscribed })
+
+	// 断开期间其他实例清空了全部缓存，重新订阅时应同步代数
+	restarted, err := redistest.Start(addr)
+	if err != nil {
+		t.Fatalf("重启 Redis 替身失败: %v", err)
+	}
+	t.Cleanup(func() { _ = restarted.Close() })
+	restarted.Set(generationKey, []byte("3"), 0)
+
+	waitFor(t, "重新订阅", func() bool {
+		stats := m.Stats().Invalidation
+		return stats.Subscribed && stats.Resubscribes == 1
+	})
+	waitFor(t, "同步命名空间代数", func() bool { return m.Generation() == 3 })
+	if _, level := m.Get(ctx, "k"); level != HitNone {
+		t.Fatalf("代数提升后旧条目应全部作废，实际: %s", level)
+	}
+
+	// 模拟其他实例的清除：先删除 Redis 中的条目，再广播
+	m.Set(ctx, "k", "v")
+	waitFor(t, "写入 Redis", func() bool { _, ok := restarted.Get("g3:k"); return ok })
+	restarted.Del("g3:k")
+	restarted.Publish("mathsvg:test", `{"origin":"peer","keys":["g3:k"]}`)
+	waitFor(t, "重连后继续接收消息", func() bool { return m.Stats().Invalidation.Received == 1 })
+	if _, level := m.Get(ctx, "k"); level != HitNone {
+		t.Fatalf("重连后收到的清除消息应逐出本地副本，实际: %s", level)
+	}
+}
+
+func TestManager_GenerationLoadedWithoutInvalidation(t *testing.T) {
+	server := startRedis(t)
+	server.Set(generationKey, []byte("4"), 0)
+
+	redisTier := newTestRedisTier(t, config.Cache{RedisAddress: server.Addr()})
+	m := NewManagerWithTiers(config.Cache{}, []Tier{NewMemoryTier(TierMemory, TierPolicy{}), redisTier}, zap.NewNop())
+	t.Cleanup(func() { _ = m.Close() })
+	if m.Generation() != 4 {
+		t.Fatalf("未开启失效广播时也应在启动时读取代数，实际: %d", m.Generation())
+	}
+
+	ctx := context.Background()
+	m.Set(ctx, "k", "v")
+	waitFor(t, "写入 Redis", func() bool { _, ok := server.Get("g4:k"); return ok })
+}
+
+// newInvalidationManager 创建由 memory 与 Redis 两层组成、订阅失效频道的 Manager
+func newInvalidationManager(t *testing.T, addr string) *Manager {
+	t.Helper()
+	cfg := config.Cache{
+		RedisAddress:             addr,
+		RedisDialTimeout:         200 * time.Millisecond,
+		RedisReadTimeout:         time.Second,
+		RedisWriteTimeout:        time.Second,
+		RedisBreakerThreshold:    3,
+		RedisReconnectMinBackoff: 10 * time.Millisecond,
+		RedisReconnectMaxBackoff: 50 * time.Millisecond,
+		InvalidationEnabled:      true,
+		InvalidationChannel:      "mathsvg:test",
+	}
+	redisTier, err := NewRedisTier(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("创建 Redis 缓存层失败: %v", err)
+	}
+	m := NewManagerWithTiers(cfg, []Tier{NewMemoryTier(TierMemory, TierPolicy{}), redisTier}, zap.NewNop())
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// largeStore 存放超出 BigCache 分片容量的条目，按总大小做 LRU 淘汰，存活时间与 BigCache 一致
+type largeStore struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	lru   *list.List
+	index map[string]*list.Element
+	size  int64
+}
+
+type largeEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	// hits 为命中次数，停机快照据此挑选热点
+	hits uint32
+}
+
+func newLargeStore(maxBytes int64, ttl time.Duration) *largeStore {
+	return &largeStore{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		lru:      list.New(),
+		index:    make(map[string]*list.Element),
+	}
+}
+
+func (s *largeStore) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*largeEntry)
+	if s.ttl > 0 && time.Now().After(entry.expires) {
+		s.removeElement(elem)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	entry.hits++
+	return entry.value, true
+}
+
+// set 写入条目并淘汰最久未访问的条目，单条超过总上限时返回 false
+func (s *largeStore) set(key string, value []byte) bool {
+	size := int64(len(value))
+	if size > s.maxBytes {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteLocked(key)
+	entry := &largeEntry{key: key, value: append([]byte(nil), value...), expires: time.Now().Add(s.ttl)}
+	s.index[key] = s.lru.PushFront(entry)
+	s.size += size
+	for s.size > s.maxBytes {
+		s.removeElement(s.lru.Back())
+	}
+	return true
+}
+
+// touch 替换条目的新鲜截止时间标记并重新计算存活时间；get 返回的切片可能仍在使用，不原地修改
+func (s *largeStore) touch(key string, header []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.index[key]
+	if !ok {
+		return false
+	}
+	entry := elem.Value.(*largeEntry)
+	if s.ttl > 0 && time.Now().After(entry.expires) {
+		return false
+	}
+	value, ok := restamp(entry.value, header)
+	if !ok {
+		return false
+	}
+	entry.value = value
+	entry.expires = time.Now().Add(s.ttl)
+	return true
+}
+
+func (s *largeStore) delete(key string) {
+	s.mu.Lock()
+	s.deleteLocked(key)
+	s.mu.Unlock()
+}
+
+func (s *largeStore) stats() (entries int, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index), s.size
+}
+
+// hotEntries 返回所有未过期条目及其命中次数
+func (s *largeStore) hotEntries() []hotEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	entries := make([]hotEntry, 0, len(s.index))
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*largeEntry)
+		if s.ttl > 0 && now.After(entry.expires) {
+			continue
+		}
+		entries = append(entries, hotEntry{key: entry.key, value: entry.value, hits: entry.hits})
+	}
+	return entries
+}
+
+func (s *largeStore) deleteLocked(key string) {
+	if elem, ok := s.index[key]; ok {
+		s.removeElement(elem)
+	}
+}
+
+func (s *largeStore) removeElement(elem *list.Element) {
+	entry := elem.Value.(*largeEntry)
+	s.size -= int64(len(entry.value))
+	s.lru.Remove(elem)
+	delete(s.index, entry.key)
+}
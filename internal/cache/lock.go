@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// lockPrefix 与缓存键区分，避免锁与缓存值冲突
+const lockPrefix = "render-lock:"
+
+// releaseScript 仅在锁仍属于自己时删除，防止误删其他实例在锁过期后获得的新锁
+var releaseScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+
+// TryLock 尝试获取跨实例渲染锁。未启用锁或 Redis 不可用时视为获取成功，调用方照常渲染；
+// 返回的 release 可安全重复调用
+func (m *Manager) TryLock(ctx context.Context, key string) (release func(), acquired bool) {
+	noop := func() {}
+	key = m.namespaced(key)
+	if !m.lockEnabled || m.redis == nil || !m.redis.available() {
+		return noop, true
+	}
+
+	token := uuid.NewString()
+	ok, err := m.redis.client.SetNX(ctx, lockPrefix+key, token, m.lockTTL).Result()
+	m.redis.record(ctx, err)
+	if err != nil {
+		m.logger.Warn("Redis 渲染锁获取失败，退化为本地渲染", zap.Error(err))
+		return noop, true
+	}
+	if !ok {
+		return noop, false
+	}
+
+	return func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := releaseScript.Run(releaseCtx, m.redis.client, []string{lockPrefix + key}, token).Err(); err != nil && !errors.Is(err, redis.Nil) {
+			m.logger.Warn("Redis 渲染锁释放失败", zap.Error(err))
+		}
+	}, true
+}
+
+// WaitForPeer 在其他实例持有渲染锁时轮询其结果，命中后回填本地缓存。
+// 锁已释放或过期但仍无结果（对方渲染失败）时返回 false，由调用方自行渲染
+func (m *Manager) WaitForPeer(ctx context.Context, key string) (string, bool) {
+	key = m.namespaced(key)
+	ticker := time.NewTicker(m.lockPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", false
+		case <-ticker.C:
+		}
+
+		if value, ok := m.peerValue(ctx, key); ok {
+			return value, true
+		}
+		if !m.redis.available() {
+			return "", false
+		}
+		exists, err := m.redis.client.Exists(ctx, lockPrefix+key).Result()
+		if err != nil || exists == 0 {
+			// 结果异步写入 Redis，锁释放时可能尚未落盘，最后再确认一次
+			return m.peerValue(ctx, key)
+		}
+	}
+}
+
+// peerValue 读取其他实例写入 Redis 的结果并回填上层缓存
+func (m *Manager) peerValue(ctx context.Context, key string) (string, bool) {
+	raw, err := m.redis.Get(ctx, key)
+	if err != nil {
+		return "", false
+	}
+	value, until := unstamp(raw)
+	m.backfill(ctx, key, value, until, m.redisLevel)
+	return string(value), true
+}
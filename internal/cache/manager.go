@@ -3,173 +3,293 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	bigcache "github.com/allegro/bigcache/v3"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	"mathsvg/internal/config"
 )
 
-// HitLevel 用中文记录缓存命中的层级，方便日志分析
+// HitLevel 记录命中的缓存层名称，方便日志分析
 type HitLevel string
 
 const (
 	HitNone  HitLevel = "miss"  // 未命中任何缓存
-	HitLocal HitLevel = "local" // 命中一级缓存
-	HitRedis HitLevel = "redis" // 命中二级缓存
+	HitLocal HitLevel = "local" // 命中 BigCache
+	HitDisk  HitLevel = "disk"  // 命中磁盘缓存
+	HitRedis HitLevel = "redis" // 命中 Redis
 )
 
-// Manager 统一管理一级 BigCache 与二级 Redis 的协同
+// 可在 cache.tiers 中配置的缓存层
+const (
+	TierLocal  = "local"
+	TierDisk   = "disk"
+	TierRedis  = "redis"
+	TierMemory = "memory"
+)
+
+// Manager 按顺序组合多层缓存：逐层查找，下层命中时回填上层。
+// 写入各层的值带有新鲜截止时间，过期判断、旧值返回与滑动续期都在这里统一处理
 type Manager struct {
-	local        *bigcache.BigCache
-	redis        *redis.Client
-	redisEnabled bool
-	redisTTL     time.Duration
-	logger       *zap.Logger
+	tiers    []Tier
+	policies []TierPolicy
+	// limiters 与 tiers 一一对应，未启用滑动过期的层为 nil
+	limiters []*touchLimiter
+	swr      bool
+	logger   *zap.Logger
 
-	hitsLocal atomic.Uint64
-	hitsRedis atomic.Uint64
-	misses    atomic.Uint64
+	// redis 为组合中的 Redis 层（若有），跨实例渲染锁依赖它
+	redis      *RedisTier
+	redisLevel int
 
-	redisAlive atomic.Bool
-}
+	lockEnabled bool
+	lockTTL     time.Duration
+	lockPoll    time.Duration
 
-// Stats 描述缓存的关键运行指标
-type Stats struct {
-	LocalEntries int
-	HitsLocal    uint64
-	HitsRedis    uint64
-	Misses       uint64
-	RedisEnabled bool
-	RedisAlive   bool
+	// generation 为缓存命名空间代数，清空全部缓存时提升；invalidation 未启用失效频道时为 nil
+	generation   atomic.Uint64
+	invalidation *invalidation
+	// generationDisk 在没有 Redis 时保存命名空间代数，避免重启后代数归零、已清空的条目重新命中
+	generationDisk *DiskTier
+	generationMu   sync.Mutex
+
+	misses      atomic.Uint64
+	staleServed atomic.Uint64
 }
 
-// NewManager 根据配置初始化缓存组件
+// NewManager 根据 cache.tiers 依次创建缓存层；磁盘、Redis 未启用时跳过该层
 func NewManager(cfg config.Cache, logger *zap.Logger) (*Manager, error) {
-	localCache, err := bigcache.NewBigCache(bigcache.Config{
-		Shards:             1024,
-		LifeWindow:         cfg.LocalLifeWindow,
-		CleanWindow:        cfg.LocalCleanWindow,
-		MaxEntriesInWindow: 100_000,
-		MaxEntrySize:       1024,
-		Verbose:            false,
-		HardMaxCacheSize:   cfg.LocalHardMaxCacheMB,
-		StatsEnabled:       true,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	manager := &Manager{
-		local:        localCache,
-		redisEnabled: cfg.RedisEnabled,
-		redisTTL:     cfg.RedisTTL,
-		logger:       logger,
-	}
-
-	if cfg.RedisEnabled {
-		manager.redis = redis.NewClient(&redis.Options{
-			Addr:            cfg.RedisAddress,
-			Password:        cfg.RedisPassword,
-			DB:              cfg.RedisDB,
-			DialTimeout:     cfg.RedisDialTimeout,
-			ReadTimeout:     cfg.RedisReadTimeout,
-			WriteTimeout:    cfg.RedisWriteTimeout,
-			MaxRetries:      cfg.RedisMaxRetries,
-			MinRetryBackoff: cfg.RedisMinRetryBackoff,
-			MaxRetryBackoff: cfg.RedisMaxRetryBackoff,
-		})
-
-		if err := manager.redis.Ping(context.Background()).Err(); err != nil {
-			// 这里容忍 Redis 不可用的情况，降级为单机缓存
-			logger.Warn("Redis 无法连接，降级为仅 BigCache", zap.Error(err))
-			_ = manager.redis.Close()
-			manager.redis = nil
-			manager.redisEnabled = false
-			manager.markRedisAlive(false)
-		} else {
-			manager.markRedisAlive(true)
+	tiers := make([]Tier, 0, len(cfg.Tiers))
+	for _, name := range cfg.Tiers {
+		tier, err := newTier(name, cfg, logger)
+		if err != nil {
+			closeTiers(tiers)
+			return nil, err
+		}
+		if tier != nil {
+			tiers = append(tiers, tier)
 		}
-	} else {
-		manager.markRedisAlive(false)
 	}
-
-	return manager, nil
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("cache.tiers 未配置任何可用的缓存层")
+	}
+	return NewManagerWithTiers(cfg, tiers, logger), nil
 }
 
-// Get 会先尝试从 BigCache 命中，再回源 Redis
-func (m *Manager) Get(ctx context.Context, key string) (string, HitLevel) {
-	if data, err := m.local.Get(key); err == nil {
-		m.hitsLocal.Add(1)
-		return string(data), HitLocal
-	} else if !errors.Is(err, bigcache.ErrEntryNotFound) {
-		m.logger.Warn("BigCache 读取失败", zap.Error(err))
+// NewManagerWithTiers 使用调用方提供的缓存层，适合测试或自定义组合。
+// 组合中有 Redis 层时先从 Redis 读取命名空间代数（否则从磁盘层读取），启用 cache.invalidation_enabled 时再订阅失效频道
+func NewManagerWithTiers(cfg config.Cache, tiers []Tier, logger *zap.Logger) *Manager {
+	m := &Manager{
+		tiers:       tiers,
+		policies:    make([]TierPolicy, len(tiers)),
+		limiters:    make([]*touchLimiter, len(tiers)),
+		swr:         cfg.StaleWhileRevalidate,
+		logger:      logger,
+		redisLevel:  -1,
+		lockEnabled: cfg.RenderLockEnabled,
+		lockTTL:     cfg.RenderLockTTL,
+		lockPoll:    cfg.RenderLockPoll,
 	}
-
-	if !m.redisEnabled || m.redis == nil {
-		m.misses.Add(1)
-		return "", HitNone
+	for i, tier := range tiers {
+		m.policies[i] = tier.Policy()
+		if p := m.policies[i]; p.TTL > 0 && p.TouchInterval > 0 {
+			m.limiters[i] = newTouchLimiter(p.TouchInterval)
+		}
+		if redisTier, ok := tier.(*RedisTier); ok && m.redis == nil {
+			m.redis, m.redisLevel = redisTier, i
+		}
+	}
+	if m.redis == nil {
+		m.loadLocalGeneration()
+	}
+	// 未开启失效广播时同样读取代数，否则其他实例清空全部缓存后本实例仍按旧代数读写
+	if m.redis != nil && m.redis.available() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.redis.dialTimeout+time.Second)
+		m.syncGeneration(ctx)
+		cancel()
+	}
+	if cfg.InvalidationEnabled && m.redis != nil {
+		m.startInvalidation(cfg.InvalidationChannel, cfg.RedisReconnectMinBackoff, cfg.RedisReconnectMaxBackoff)
 	}
+	return m
+}
 
-	value, err := m.redis.Get(ctx, key).Result()
-	switch {
-	case err == nil:
-		m.hitsRedis.Add(1)
-		m.markRedisAlive(true)
-		if setErr := m.local.Set(key, []byte(value)); setErr != nil {
-			m.logger.Warn("Redis 回填 BigCache 失败", zap.Error(setErr))
+func newTier(name string, cfg config.Cache, logger *zap.Logger) (Tier, error) {
+	switch name {
+	case TierLocal:
+		return NewBigCacheTier(cfg)
+	case TierDisk:
+		if !cfg.DiskEnabled {
+			return nil, nil
+		}
+		return NewDiskTier(cfg, logger)
+	case TierRedis:
+		if !cfg.RedisEnabled {
+			return nil, nil
 		}
-		return value, HitRedis
-	case errors.Is(err, redis.Nil):
-		m.misses.Add(1)
-		return "", HitNone
+		// 启动时 Redis 不可用也保留该层，恢复后自动启用
+		return NewRedisTier(cfg, logger)
+	case TierMemory:
+		return NewMemoryTier(TierMemory, TierPolicy{}), nil
 	default:
-		m.logger.Warn("Redis 读取失败", zap.Error(err))
-		m.markRedisAlive(false)
-		m.misses.Add(1)
-		return "", HitNone
+		return nil, fmt.Errorf("未知的缓存层: %s", name)
 	}
 }
 
-// Set 将数据写入 BigCache，并异步写 Redis
-func (m *Manager) Set(ctx context.Context, key string, value string) {
-	if err := m.local.Set(key, []byte(value)); err != nil {
-		m.logger.Warn("BigCache 写入失败", zap.Error(err))
+// Get 按顺序逐层查找新鲜的值，命中后回填之前的各层并按需续期。
+// 启用 stale-while-revalidate 时，若各层都只有窗口内的过期值，则返回最上层的旧值，
+// 命中层级带 :stale 后缀，由调用方在后台刷新
+func (m *Manager) Get(ctx context.Context, key string) (string, HitLevel) {
+	key = m.namespaced(key)
+	now := time.Now()
+	var stale []byte
+	staleLevel := -1
+	for i, tier := range m.tiers {
+		raw, err := tier.Get(ctx, key)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnavailable) {
+				m.logger.Warn("缓存读取失败", zap.String("tier", tier.Name()), zap.Error(err))
+			}
+			continue
+		}
+
+		value, until := unstamp(raw)
+		if !until.IsZero() && now.After(until) {
+			if m.swr && now.Before(until.Add(m.policies[i].StaleWindow)) {
+				if staleLevel < 0 {
+					stale, staleLevel = value, i
+				}
+			} else if err := tier.Delete(ctx, key); err != nil {
+				m.logger.Warn("过期缓存删除失败", zap.String("tier", tier.Name()), zap.Error(err))
+			}
+			continue
+		}
+
+		m.backfill(ctx, key, value, until, i)
+		m.touch(key, value, i, now)
+		return string(value), HitLevel(tier.Name())
 	}
 
-	if !m.redisEnabled || m.redis == nil {
+	if staleLevel >= 0 {
+		m.staleServed.Add(1)
+		return string(stale), HitLevel(m.tiers[staleLevel].Name() + staleSuffix)
+	}
+	m.misses.Add(1)
+	return "", HitNone
+}
+
+// Set 同步写入首层，其余各层在后台写入，避免远程缓存拖慢请求
+func (m *Manager) Set(ctx context.Context, key string, value string) {
+	key = m.namespaced(key)
+	data := []byte(value)
+	now := time.Now()
+	m.store(ctx, 0, key, data, freshUntil(now, m.policies[0], time.Time{}))
+	if len(m.tiers) == 1 {
 		return
 	}
 
 	go func() {
 		childCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
-		if err := m.redis.Set(childCtx, key, value, m.redisTTL).Err(); err != nil {
-			m.logger.Warn("Redis 写入失败", zap.Error(err))
-			m.markRedisAlive(false)
-			return
+		for i := 1; i < len(m.tiers); i++ {
+			m.store(childCtx, i, key, data, freshUntil(now, m.policies[i], time.Time{}))
 		}
-		m.markRedisAlive(true)
 	}()
 }
 
-// Close 主动释放底层资源，便于优雅停机
-func (m *Manager) Close() error {
-	if m.local != nil {
-		if err := m.local.Close(); err != nil {
-			m.logger.Warn("BigCache 关闭失败", zap.Error(err))
+// touch 对命中层及其下各层做滑动续期，同一键按各层的续期间隔限频，在后台执行。
+// 只改写新鲜截止时间，某层已没有该条目时才写入完整的值
+func (m *Manager) touch(key string, value []byte, level int, now time.Time) {
+	var levels []int
+	for i := level; i < len(m.tiers); i++ {
+		if m.limiters[i] != nil && m.limiters[i].allow(key, now) {
+			levels = append(levels, i)
 		}
 	}
+	if len(levels) == 0 {
+		return
+	}
 
-	if m.redisEnabled && m.redis != nil {
-		if err := m.redis.Close(); err != nil {
-			m.logger.Warn("Redis 关闭失败", zap.Error(err))
-			return err
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		for _, i := range levels {
+			until := freshUntil(now, m.policies[i], time.Time{})
+			tier := m.tiers[i]
+			err := tier.Touch(ctx, key, stamp(nil, until))
+			switch {
+			case err == nil, errors.Is(err, ErrUnavailable):
+			case errors.Is(err, ErrNotFound):
+				m.store(ctx, i, key, value, until)
+			default:
+				m.logger.Warn("缓存续期失败", zap.String("tier", tier.Name()), zap.Error(err))
+			}
 		}
+	}()
+}
+
+// store 写入单层缓存，超出容量上限或该层熔断中属于预期情况，不记为告警
+func (m *Manager) store(ctx context.Context, level int, key string, value []byte, until time.Time) {
+	tier := m.tiers[level]
+	err := tier.Set(ctx, key, stamp(value, until))
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrEntryTooLarge), errors.Is(err, ErrUnavailable):
+		m.logger.Debug("缓存层跳过写入", zap.String("tier", tier.Name()), zap.Error(err))
+	default:
+		m.logger.Warn("缓存写入失败", zap.String("tier", tier.Name()), zap.Error(err))
 	}
+}
 
-	return nil
+// Delete 从所有缓存层删除并通知其他实例逐出本地副本，返回遇到的第一个错误
+func (m *Manager) Delete(ctx context.Context, key string) error {
+	key = m.namespaced(key)
+	var first error
+	for _, tier := range m.tiers {
+		if err := tier.Delete(ctx, key); err != nil {
+			m.logger.Warn("缓存删除失败", zap.String("tier", tier.Name()), zap.Error(err))
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if err := m.publishInvalidation(ctx, invalidationMessage{Keys: []string{key}}); err != nil {
+		m.logger.Warn("缓存失效消息发布失败", zap.Error(err))
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// backfill 将下层命中的数据写回 level 之前的各层，新鲜截止时间不晚于来源值
+func (m *Manager) backfill(ctx context.Context, key string, value []byte, source time.Time, level int) {
+	now := time.Now()
+	for i := 0; i < level; i++ {
+		m.store(ctx, i, key, value, freshUntil(now, m.policies[i], source))
+	}
+}
+
+// Close 主动释放底层资源，便于优雅停机
+func (m *Manager) Close() error {
+	m.stopInvalidation()
+	var first error
+	for _, tier := range m.tiers {
+		if err := tier.Close(); err != nil {
+			m.logger.Warn("缓存关闭失败", zap.String("tier", tier.Name()), zap.Error(err))
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func closeTiers(tiers []Tier) {
+	for _, tier := range tiers {
+		_ = tier.Close()
+	}
 }
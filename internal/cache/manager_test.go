@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+func TestManager_BackfillUpperTiers(t *testing.T) {
+	upper, lower := NewMemoryTier("upper", TierPolicy{}), NewMemoryTier("lower", TierPolicy{})
+	m := NewManagerWithTiers(config.Cache{}, []Tier{upper, lower}, zap.NewNop())
+	ctx := context.Background()
+
+	if _, level := m.Get(ctx, "k"); level != HitNone {
+		t.Fatalf("空缓存应未命中，实际: %s", level)
+	}
+	_ = lower.Set(ctx, "k", []byte("v"))
+
+	value, level := m.Get(ctx, "k")
+	if value != "v" || level != "lower" {
+		t.Fatalf("应命中下层，实际: %q %s", value, level)
+	}
+	if _, level := m.Get(ctx, "k"); level != "upper" {
+		t.Fatalf("下层命中后应回填上层，实际: %s", level)
+	}
+
+	if err := m.Delete(ctx, "k"); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if upper.Len() != 0 || lower.Len() != 0 {
+		t.Fatalf("删除应作用于所有缓存层")
+	}
+
+	stats := m.Stats()
+	if stats.Misses != 1 || len(stats.Tiers) != 2 || stats.Tiers[1].Hits != 1 {
+		t.Fatalf("统计不符合预期: %+v", stats)
+	}
+}
+
+func TestNewManager_UnknownTier(t *testing.T) {
+	if _, err := NewManager(config.Cache{Tiers: []string{"memory", "nope"}}, zap.NewNop()); err == nil {
+		t.Fatalf("未知的缓存层应返回错误")
+	}
+}
+
+func TestManager_StaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	for _, swr := range []bool{true, false} {
+		tier := NewMemoryTier(TierMemory, TierPolicy{TTL: 10 * time.Millisecond, StaleWindow: time.Hour})
+		m := NewManagerWithTiers(config.Cache{StaleWhileRevalidate: swr}, []Tier{tier}, zap.NewNop())
+		m.Set(ctx, "k", "v")
+		time.Sleep(20 * time.Millisecond)
+
+		value, level := m.Get(ctx, "k")
+		if swr && (value != "v" || level != "memory:stale" || !level.Stale()) {
+			t.Fatalf("窗口内的过期值应作为旧值返回，实际: %q %s", value, level)
+		}
+		if !swr && level != HitNone {
+			t.Fatalf("未启用 stale-while-revalidate 时过期值应未命中，实际: %s", level)
+		}
+	}
+
+	// 超出旧值窗口后应视为未命中并删除
+	tier := NewMemoryTier(TierMemory, TierPolicy{TTL: 10 * time.Millisecond, StaleWindow: 10 * time.Millisecond})
+	m := NewManagerWithTiers(config.Cache{StaleWhileRevalidate: true}, []Tier{tier}, zap.NewNop())
+	m.Set(ctx, "k", "v")
+	time.Sleep(30 * time.Millisecond)
+	if _, level := m.Get(ctx, "k"); level != HitNone || tier.Len() != 0 {
+		t.Fatalf("超出窗口的过期值应删除，实际: %s", level)
+	}
+	if stats := m.Stats(); stats.StaleServed != 0 || stats.Misses != 1 {
+		t.Fatalf("统计不符合预期: %+v", stats)
+	}
+}
+
+func TestManager_SlidingTTL(t *testing.T) {
+	ctx := context.Background()
+	tier := NewMemoryTier(TierMemory, TierPolicy{TTL: time.Minute, TouchInterval: time.Millisecond})
+	m := NewManagerWithTiers(config.Cache{}, []Tier{tier}, zap.NewNop())
+	m.Set(ctx, "k", "v")
+
+	raw, _ := tier.Get(ctx, "k")
+	_, before := unstamp(raw)
+	time.Sleep(5 * time.Millisecond)
+	if _, level := m.Get(ctx, "k"); level != TierMemory {
+		t.Fatalf("应命中，实际: %s", level)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		raw, _ := tier.Get(ctx, "k")
+		if _, after := unstamp(raw); after.After(before) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("命中后应延长新鲜截止时间")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
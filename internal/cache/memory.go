@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// MemoryTier 是基于 map 的简单实现，不做淘汰，供测试或调试使用
+type MemoryTier struct {
+	name   string
+	policy TierPolicy
+
+	mu      sync.RWMutex
+	entries map[string][]byte
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewMemoryTier 创建内存缓存层，name 为空时使用 memory；条目不会被删除，过期仅按 policy 判断
+func NewMemoryTier(name string, policy TierPolicy) *MemoryTier {
+	if name == "" {
+		name = TierMemory
+	}
+	return &MemoryTier{name: name, policy: policy, entries: make(map[string][]byte)}
+}
+
+func (t *MemoryTier) Name() string { return t.name }
+
+func (t *MemoryTier) Get(_ context.Context, key string) ([]byte, error) {
+	t.mu.RLock()
+	value, ok := t.entries[key]
+	t.mu.RUnlock()
+	if !ok {
+		t.misses.Add(1)
+		return nil, ErrNotFound
+	}
+	t.hits.Add(1)
+	return value, nil
+}
+
+func (t *MemoryTier) Set(_ context.Context, key string, value []byte) error {
+	// 复制一份，避免调用方后续修改切片影响已缓存的内容
+	stored := append([]byte(nil), value...)
+	t.mu.Lock()
+	t.entries[key] = stored
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *MemoryTier) Delete(_ context.Context, key string) error {
+	t.mu.Lock()
+	delete(t.entries, key)
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *MemoryTier) Touch(_ context.Context, key string, header []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	value, ok := restamp(t.entries[key], header)
+	if !ok {
+		return ErrNotFound
+	}
+	t.entries[key] = value
+	return nil
+}
+
+func (t *MemoryTier) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.entries)
+}
+
+func (t *MemoryTier) Policy() TierPolicy { return t.policy }
+
+func (t *MemoryTier) Stats() TierStats {
+	return TierStats{
+		Name:    t.name,
+		Entries: t.Len(),
+		Hits:    t.hits.Load(),
+		Misses:  t.misses.Load(),
+		Alive:   true,
+	}
+}
+
+func (t *MemoryTier) Close() error { return nil }
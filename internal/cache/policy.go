@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TierPolicy 描述单层缓存的过期策略
+type TierPolicy struct {
+	// TTL 为条目保持新鲜的时间，0 表示永不过期
+	TTL time.Duration
+	// StaleWindow 为过期后仍可作为旧值返回的时间，仅在启用 stale-while-revalidate 时生效
+	StaleWindow time.Duration
+	// TouchInterval 大于 0 时启用滑动过期：命中后重新计算 TTL，同一键在该间隔内最多续期一次
+	TouchInterval time.Duration
+}
+
+// retention 返回该层需要保留条目的总时长，0 表示不限
+func (p TierPolicy) retention(swr bool) time.Duration {
+	if p.TTL <= 0 {
+		return 0
+	}
+	if swr {
+		return p.TTL + p.StaleWindow
+	}
+	return p.TTL
+}
+
+// staleSuffix 标记命中的是已过期但仍在窗口内的旧值
+const staleSuffix = ":stale"
+
+// Stale 判断本次命中是否返回了过期旧值
+func (l HitLevel) Stale() bool {
+	return strings.HasSuffix(string(l), staleSuffix)
+}
+
+// stampMagic 标记 Manager 写入的值：其后 8 字节为新鲜截止时间（Unix 毫秒，0 表示永不过期），再接原始内容
+const stampMagic = "\x00ts1"
+
+const stampSize = len(stampMagic) + 8
+
+func stamp(value []byte, freshUntil time.Time) []byte {
+	out := make([]byte, stampSize+len(value))
+	copy(out, stampMagic)
+	if !freshUntil.IsZero() {
+		binary.BigEndian.PutUint64(out[len(stampMagic):], uint64(freshUntil.UnixMilli()))
+	}
+	copy(out[stampSize:], value)
+	return out
+}
+
+// unstamp 拆出新鲜截止时间，缺少标记的旧值视为永不过期
+func unstamp(raw []byte) ([]byte, time.Time) {
+	if len(raw) < stampSize || string(raw[:len(stampMagic)]) != stampMagic {
+		return raw, time.Time{}
+	}
+	ms := binary.BigEndian.Uint64(raw[len(stampMagic):stampSize])
+	if ms == 0 {
+		return raw[stampSize:], time.Time{}
+	}
+	return raw[stampSize:], time.UnixMilli(int64(ms))
+}
+
+// restamp 返回将 raw 开头的新鲜截止时间标记替换为 header 的副本，raw 缺少标记时返回 false
+func restamp(raw, header []byte) ([]byte, bool) {
+	if len(raw) < stampSize || string(raw[:len(stampMagic)]) != stampMagic || len(header) != stampSize {
+		return nil, false
+	}
+	out := append([]byte(nil), raw...)
+	copy(out, header)
+	return out, true
+}
+
+// freshUntil 计算写入某层时的新鲜截止时间，不晚于来源值自身的截止时间
+func freshUntil(now time.Time, policy TierPolicy, source time.Time) time.Time {
+	var until time.Time
+	if policy.TTL > 0 {
+		until = now.Add(policy.TTL)
+	}
+	if !source.IsZero() && (until.IsZero() || source.Before(until)) {
+		until = source
+	}
+	return until
+}
+
+// touchLimiterMax 为续期记录的上限，超过后清理已过间隔的记录
+const touchLimiterMax = 100_000
+
+// touchLimiter 限制同一键的续期频率，避免热点公式每次命中都改写缓存
+type touchLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newTouchLimiter(interval time.Duration) *touchLimiter {
+	return &touchLimiter{interval: interval, last: make(map[string]time.Time)}
+}
+
+// allow 在距离上次续期已超过间隔时返回 true 并记录本次续期
+func (l *touchLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.last[key]; ok && now.Sub(last) < l.interval {
+		return false
+	}
+	if len(l.last) >= touchLimiterMax {
+		for k, t := range l.last {
+			if now.Sub(t) >= l.interval {
+				delete(l.last, k)
+			}
+		}
+		// 仍然全部处于间隔内时放弃本次续期，保证内存有界
+		if len(l.last) >= touchLimiterMax {
+			return false
+		}
+	}
+	l.last[key] = now
+	return true
+}
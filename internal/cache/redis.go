@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+// RedisTier 是多实例共享的二级缓存，条目按 cache.redis_ttl 过期，支持单机、Sentinel 与 Cluster 部署。
+// 连续失败后熔断，断开期间各操作直接返回 ErrUnavailable，由后台按指数退避探活恢复
+type RedisTier struct {
+	client redis.UniversalClient
+	policy TierPolicy
+	// ttl 为写入时设置的过期时间，启用 stale-while-revalidate 时包含旧值窗口
+	ttl    time.Duration
+	logger *zap.Logger
+
+	breaker     *breaker
+	probing     atomic.Bool
+	probeMin    time.Duration
+	probeMax    time.Duration
+	dialTimeout time.Duration
+	done        chan struct{}
+	closeOnce   sync.Once
+
+	// size 为后台定期刷新的键数量，尚未取得或不统计时为 -1
+	size         atomic.Int64
+	sizeInterval time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	alive  atomic.Bool
+}
+
+// NewRedisTier 创建 Redis 缓存层并探活；启动时无法连接不视为错误，
+// 该层先处于熔断状态，后台重连成功后自动启用。部署方式或 TLS 配置有误时返回错误
+func NewRedisTier(cfg config.Cache, logger *zap.Logger) (*RedisTier, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := TierPolicy{
+		TTL:           cfg.RedisTTL,
+		StaleWindow:   cfg.RedisStaleWindow,
+		TouchInterval: cfg.RedisTouchInterval,
+	}
+	t := &RedisTier{
+		client:      client,
+		policy:      policy,
+		ttl:         policy.retention(cfg.StaleWhileRevalidate),
+		logger:      logger,
+		breaker:     newBreaker(cfg.RedisBreakerThreshold),
+		probeMin:    cfg.RedisReconnectMinBackoff,
+		probeMax:    cfg.RedisReconnectMaxBackoff,
+		dialTimeout: cfg.RedisDialTimeout,
+		done:        make(chan struct{}),
+
+		sizeInterval: cfg.RedisSizeInterval,
+	}
+	t.size.Store(-1)
+	defer t.startSizeRefresh()
+
+	if err := t.ping(); err != nil {
+		logger.Warn("Redis 无法连接，后台重连成功前跳过 Redis 缓存层", zap.String("mode", cfg.RedisMode), zap.Error(err))
+		t.breaker.trip()
+		t.startProbe()
+		return t, nil
+	}
+	t.alive.Store(true)
+	return t, nil
+}
+
+func (t *RedisTier) Name() string { return string(HitRedis) }
+
+func (t *RedisTier) Get(ctx context.Context, key string) ([]byte, error) {
+	if !t.available() {
+		t.misses.Add(1)
+		return nil, ErrUnavailable
+	}
+	value, err := t.client.Get(ctx, key).Bytes()
+	t.record(ctx, err)
+	switch {
+	case err == nil:
+		t.hits.Add(1)
+		return value, nil
+	case errors.Is(err, redis.Nil):
+		t.misses.Add(1)
+		return nil, ErrNotFound
+	default:
+		t.misses.Add(1)
+		return nil, err
+	}
+}
+
+func (t *RedisTier) Set(ctx context.Context, key string, value []byte) error {
+	if !t.available() {
+		return ErrUnavailable
+	}
+	err := t.client.Set(ctx, key, value, t.ttl).Err()
+	t.record(ctx, err)
+	return err
+}
+
+func (t *RedisTier) Delete(ctx context.Context, key string) error {
+	if !t.available() {
+		return ErrUnavailable
+	}
+	err := t.client.Del(ctx, key).Err()
+	t.record(ctx, err)
+	return err
+}
+
+// touchSource 仅在条目带有新鲜截止时间标记时改写标记并重置过期时间，避免 SETRANGE 凭空创建键。
+// ARGV 依次为标记前缀、新的标记与过期毫秒数（0 表示不过期）
+const touchSource = `if redis.call("getrange", KEYS[1], 0, string.len(ARGV[1]) - 1) ~= ARGV[1] then return 0 end
+redis.call("setrange", KEYS[1], 0, ARGV[2])
+if tonumber(ARGV[3]) > 0 then redis.call("pexpire", KEYS[1], ARGV[3]) end
+return 1`
+
+var touchScript = redis.NewScript(touchSource)
+
+// Touch 在 Redis 端改写新鲜截止时间并续期，只传输十余字节的标记
+func (t *RedisTier) Touch(ctx context.Context, key string, header []byte) error {
+	if !t.available() {
+		return ErrUnavailable
+	}
+	n, err := touchScript.Run(ctx, t.client, []string{key}, stampMagic, header, t.ttl.Milliseconds()).Int()
+	t.record(ctx, err)
+	switch {
+	case err != nil:
+		return err
+	case n == 0:
+		return ErrNotFound
+	default:
+		return nil
+	}
+}
+
+// Len 返回后台最近一次统计的键数量（Cluster 模式下为各主节点之和），包含其他用途的键，仅供参考。
+// 熔断期间、尚未统计成功或未开启统计时返回 -1；调用本身不访问 Redis
+func (t *RedisTier) Len() int {
+	if !t.available() {
+		return -1
+	}
+	return int(t.size.Load())
+}
+
+// startSizeRefresh 按 sizeInterval 在后台刷新键数量，避免每次 /health 都向 Redis 发起 DBSIZE
+func (t *RedisTier) startSizeRefresh() {
+	if t.sizeInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(t.sizeInterval)
+		defer ticker.Stop()
+		for {
+			t.refreshSize()
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (t *RedisTier) refreshSize() {
+	if !t.available() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.dialTimeout+time.Second)
+	defer cancel()
+	n, err := t.client.DBSize(ctx).Result()
+	if err != nil {
+		t.logger.Debug("统计 Redis 键数量失败", zap.Error(err))
+		t.size.Store(-1)
+		return
+	}
+	t.size.Store(n)
+}
+
+func (t *RedisTier) Policy() TierPolicy { return t.policy }
+
+func (t *RedisTier) Stats() TierStats {
+	return TierStats{
+		Name:        t.Name(),
+		Entries:     t.Len(),
+		Hits:        t.hits.Load(),
+		Misses:      t.misses.Load(),
+		Alive:       t.alive.Load(),
+		CircuitOpen: !t.available(),
+	}
+}
+
+func (t *RedisTier) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.client.Close()
+}
+
+// available 判断熔断器是否闭合，断开期间不向 Redis 发起请求
+func (t *RedisTier) available() bool { return t.breaker.allow() }
+
+// record 根据调用结果更新可用状态与熔断器；调用方主动取消的请求不计入失败
+func (t *RedisTier) record(ctx context.Context, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		t.breaker.success()
+		t.markAlive(true)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	t.markAlive(false)
+	if t.breaker.failure() {
+		t.logger.Warn("Redis 连续失败，熔断并在后台重连", zap.Error(err))
+		t.startProbe()
+	}
+}
+
+func (t *RedisTier) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), t.dialTimeout+time.Second)
+	defer cancel()
+	return t.client.Ping(ctx).Err()
+}
+
+// startProbe 在熔断期间按指数退避探活，成功后闭合熔断器；同一时刻只运行一个探活协程。
+// 连接池会在探活时重新拨号，无需重建客户端
+func (t *RedisTier) startProbe() {
+	if t.probing.Swap(true) {
+		return
+	}
+	go func() {
+		defer t.probing.Store(false)
+		for attempt := 0; ; attempt++ {
+			timer := time.NewTimer(backoff(attempt, t.probeMin, t.probeMax))
+			select {
+			case <-t.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if err := t.ping(); err != nil {
+				t.logger.Debug("Redis 重连失败", zap.Int("attempt", attempt+1), zap.Error(err))
+				continue
+			}
+			t.breaker.reset()
+			t.markAlive(true)
+			return
+		}
+	}()
+}
+
+// markAlive 记录 Redis 可用状态，仅在状态变化时打印日志
+func (t *RedisTier) markAlive(alive bool) {
+	if t.alive.Swap(alive) == alive {
+		return
+	}
+	if alive {
+		t.logger.Info("Redis 恢复可用")
+	} else {
+		t.logger.Warn("Redis 不可用，暂时跳过 Redis 缓存层")
+	}
+}
@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+	"mathsvg/internal/pkg/redistest"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker(2)
+	if b.failure() || !b.allow() {
+		t.Fatalf("未达到阈值时不应熔断")
+	}
+	b.success()
+	if b.failure() || !b.allow() {
+		t.Fatalf("成功后应清零连续失败次数")
+	}
+	if !b.failure() || b.allow() {
+		t.Fatalf("连续失败达到阈值应熔断")
+	}
+	if b.failure() {
+		t.Fatalf("已熔断时不应重复报告断开")
+	}
+	b.reset()
+	if !b.allow() {
+		t.Fatalf("reset 后应闭合")
+	}
+
+	if backoff(0, time.Second, 30*time.Second) != time.Second || backoff(3, time.Second, 30*time.Second) != 8*time.Second ||
+		backoff(10, time.Second, 30*time.Second) != 30*time.Second {
+		t.Fatalf("指数退避计算错误")
+	}
+}
+
+func TestRedisTier_UnreachableAtStartup(t *testing.T) {
+	// 占用一个端口后立即释放，得到一个大概率无人监听的地址
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	tier, err := NewRedisTier(config.Cache{
+		RedisAddress:             addr,
+		RedisDialTimeout:         100 * time.Millisecond,
+		RedisReadTimeout:         time.Second,
+		RedisBreakerThreshold:    3,
+		RedisReconnectMinBackoff: time.Hour,
+		RedisReconnectMaxBackoff: time.Hour,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("创建 Redis 缓存层失败: %v", err)
+	}
+	defer tier.Close()
+
+	start := time.Now()
+	if _, err := tier.Get(context.Background(), "k"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("熔断期间应直接返回 ErrUnavailable，实际: %v", err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatalf("熔断期间不应等待 Redis 超时")
+	}
+	if stats := tier.Stats(); stats.Alive || !stats.CircuitOpen || stats.Entries != -1 {
+		t.Fatalf("统计应反映熔断状态: %+v", stats)
+	}
+
+	m := NewManagerWithTiers(config.Cache{RenderLockEnabled: true}, []Tier{NewMemoryTier(TierMemory, TierPolicy{}), tier}, zap.NewNop())
+	m.Set(context.Background(), "k", "v")
+	if value, level := m.Get(context.Background(), "k"); value != "v" || level != TierMemory {
+		t.Fatalf("Redis 熔断时其余缓存层应照常工作: %q %s", value, level)
+	}
+	if _, acquired := m.TryLock(context.Background(), "k"); !acquired {
+		t.Fatalf("Redis 熔断时应退化为本地渲染")
+	}
+}
+
+func TestRedisTier_Modes(t *testing.T) {
+	master := startRedis(t)
+	master.RequireAuth("svc", "secret")
+	sentinel := startRedis(t)
+	sentinel.ServeSentinel("mymaster", master.Addr())
+	cluster := startRedis(t)
+	cluster.ServeCluster()
+
+	cases := []struct {
+		name   string
+		server *redistest.Server
+		cfg    config.Cache
+	}{
+		{"single", master, config.Cache{RedisMode: RedisModeSingle, RedisAddress: master.Addr(), RedisUsername: "svc", RedisPassword: "secret"}},
+		{"sentinel", master, config.Cache{RedisMode: RedisModeSentinel, RedisAddresses: []string{sentinel.Addr()}, RedisMasterName: "mymaster", RedisUsername: "svc", RedisPassword: "secret"}},
+		{"cluster", cluster, config.Cache{RedisMode: RedisModeCluster, RedisAddresses: []string{cluster.Addr()}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tier := newTestRedisTier(t, tc.cfg)
+			ctx := context.Background()
+			if !tier.Stats().Alive {
+				t.Fatalf("应连接成功")
+			}
+			if err := tier.Set(ctx, tc.name, []byte("v")); err != nil {
+				t.Fatalf("写入失败: %v", err)
+			}
+			if value, ok := tc.server.Get(tc.name); !ok || string(value) != "v" {
+				t.Fatalf("应写入对应的 Redis 节点")
+			}
+			if value, err := tier.Get(ctx, tc.name); err != nil || string(value) != "v" {
+				t.Fatalf("读取失败: %q %v", value, err)
+			}
+		})
+	}
+}
+
+func TestRedisTier_WrongCredentials(t *testing.T) {
+	server := startRedis(t)
+	server.RequireAuth("svc", "secret")
+
+	tier := newTestRedisTier(t, config.Cache{RedisAddress: server.Addr(), RedisUsername: "svc", RedisPassword: "wrong"})
+	if stats := tier.Stats(); stats.Alive || !stats.CircuitOpen {
+		t.Fatalf("认证失败时应熔断并退化到其余缓存层: %+v", stats)
+	}
+}
+
+func TestRedisTier_TLS(t *testing.T) {
+	dir := t.TempDir()
+	serverTLS, caFile := writeTestCertificate(t, dir)
+	server, err := redistest.StartTLS("", serverTLS)
+	if err != nil {
+		t.Fatalf("启动 Redis 替身失败: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	tier := newTestRedisTier(t, config.Cache{
+		RedisAddress:       server.Addr(),
+		RedisTLSEnabled:    true,
+		RedisTLSCAFile:     caFile,
+		RedisTLSServerName: "redistest",
+	})
+	if err := tier.Set(context.Background(), "k", []byte("v")); err != nil {
+		t.Fatalf("TLS 连接写入失败: %v", err)
+	}
+
+	plain := newTestRedisTier(t, config.Cache{RedisAddress: server.Addr()})
+	if plain.Stats().Alive {
+		t.Fatalf("未启用 TLS 时不应连接成功")
+	}
+}
+
+func TestNewRedisTier_InvalidConfig(t *testing.T) {
+	for _, cfg := range []config.Cache{
+		{RedisMode: "replicated"},
+		{RedisMode: RedisModeSentinel, RedisAddresses: []string{"127.0.0.1:26379"}},
+		{RedisMode: RedisModeCluster, RedisAddresses: []string{"127.0.0.1:7000"}, RedisDB: 1},
+		{RedisTLSEnabled: true, RedisTLSCAFile: "missing.pem"},
+	} {
+		if _, err := NewRedisTier(cfg, zap.NewNop()); err == nil {
+			t.Fatalf("配置有误时应返回错误: %+v", cfg)
+		}
+	}
+}
+
+func startRedis(t *testing.T) *redistest.Server {
+	t.Helper()
+	server, err := redistest.Start("")
+	if err != nil {
+		t.Fatalf("启动 Redis 替身失败: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return server
+}
+
+func TestManager_SlidingTTLTouchesRedis(t *testing.T) {
+	server := startRedis(t)
+	// 模拟 touchSource：带标记时改写开头的标记并重置过期时间
+	server.HandleScript(touchSource, func(s *redistest.Server, keys, args []string) any {
+		value, ok := s.Get(keys[0])
+		if !ok || !strings.HasPrefix(string(value), args[0]) {
+			return 0
+		}
+		ms, _ := strconv.ParseInt(args[2], 10, 64)
+		s.Set(keys[0], append([]byte(args[1]), value[len(args[1]):]...), time.Duration(ms)*time.Millisecond)
+		return 1
+	})
+
+	redisTier := newTestRedisTier(t, config.Cache{
+		RedisAddress:       server.Addr(),
+		RedisTTL:           time.Hour,
+		RedisTouchInterval: time.Millisecond,
+	})
+	ctx := context.Background()
+	m := NewManagerWithTiers(config.Cache{}, []Tier{NewMemoryTier(TierMemory, TierPolicy{}), redisTier}, zap.NewNop())
+	m.Set(ctx, "k", "<svg/>")
+	waitFor(t, "写入 Redis", func() bool { _, ok := server.Get("k"); return ok })
+	raw, _ := server.Get("k")
+	_, before := unstamp(raw)
+	sets := server.Commands("set")
+
+	time.Sleep(5 * time.Millisecond)
+	m.Get(ctx, "k")
+	waitFor(t, "Redis 续期", func() bool {
+		raw, _ := server.Get("k")
+		value, after := unstamp(raw)
+		return after.After(before) && string(value) == "<svg/>"
+	})
+	if server.Commands("set") != sets {
+		t.Fatal("续期不应重新写入完整的值")
+	}
+	if ttl := server.TTL("k"); ttl < 59*time.Minute {
+		t.Fatalf("续期应重置过期时间，实际: %v", ttl)
+	}
+
+	if err := redisTier.Touch(ctx, "missing", stamp(nil, time.Now())); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("条目不存在时应返回 ErrNotFound，实际: %v", err)
+	}
+	if _, ok := server.Get("missing"); ok {
+		t.Fatal("续期不应创建不存在的键")
+	}
+}
+
+func TestRedisTier_SizeRefreshedInBackground(t *testing.T) {
+	server := startRedis(t)
+	server.Set("a", []byte("1"), 0)
+	server.Set("b", []byte("2"), 0)
+
+	tier := newTestRedisTier(t, config.Cache{RedisAddress: server.Addr(), RedisSizeInterval: 20 * time.Millisecond})
+	waitFor(t, "统计键数量", func() bool { return tier.Stats().Entries == 2 })
+
+	// 查看统计只读取缓存的数量，不逐次向 Redis 发起 DBSIZE
+	refreshed := server.Commands("dbsize")
+	for i := 0; i < 50; i++ {
+		tier.Stats()
+	}
+	if got := server.Commands("dbsize"); got > refreshed+2 {
+		t.Fatalf("查看统计不应触发 DBSIZE: %d -> %d", refreshed, got)
+	}
+
+	server.Set("c", []byte("3"), 0)
+	waitFor(t, "刷新键数量", func() bool { return tier.Len() == 3 })
+
+	disabled := newTestRedisTier(t, config.Cache{RedisAddress: server.Addr()})
+	if n := disabled.Len(); n != -1 {
+		t.Fatalf("未开启统计时应返回 -1，实际: %d", n)
+	}
+}
+
+// newTestRedisTier 使用较短的超时创建 Redis 缓存层，后台重连间隔足够长，不干扰断言
+func newTestRedisTier(t *testing.T, cfg config.Cache) *RedisTier {
+	t.Helper()
+	cfg.RedisDialTimeout = 200 * time.Millisecond
+	cfg.RedisReadTimeout = time.Second
+	cfg.RedisWriteTimeout = time.Second
+	cfg.RedisBreakerThreshold = 3
+	cfg.RedisReconnectMinBackoff = time.Hour
+	cfg.RedisReconnectMaxBackoff = time.Hour
+	tier, err := NewRedisTier(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("创建 Redis 缓存层失败: %v", err)
+	}
+	t.Cleanup(func() { _ = tier.Close() })
+	return tier
+}
+
+// writeTestCertificate 生成自签名证书，返回服务端 TLS 配置与写入 dir 的 CA 文件路径
+func writeTestCertificate(t *testing.T, dir string) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成私钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redistest"},
+		DNSNames:              []string{"redistest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("写入证书失败: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, caFile
+}
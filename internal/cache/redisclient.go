@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+
+	"mathsvg/internal/config"
+)
+
+// cache.redis_mode 可选的 Redis 部署方式
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
+// newRedisClient 按 cache.redis_mode 创建客户端。三种模式统一为 redis.UniversalClient，
+// 缓存层与渲染锁无需区分部署方式；配置错误在启动时直接返回
+func newRedisClient(cfg config.Cache) (redis.UniversalClient, error) {
+	tlsConfig, err := redisTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.RedisAddresses,
+		MasterName:       cfg.RedisMasterName,
+		Username:         cfg.RedisUsername,
+		Password:         cfg.RedisPassword,
+		SentinelUsername: cfg.RedisSentinelUsername,
+		SentinelPassword: cfg.RedisSentinelPassword,
+		DB:               cfg.RedisDB,
+		DialTimeout:      cfg.RedisDialTimeout,
+		ReadTimeout:      cfg.RedisReadTimeout,
+		WriteTimeout:     cfg.RedisWriteTimeout,
+		MaxRetries:       cfg.RedisMaxRetries,
+		MinRetryBackoff:  cfg.RedisMinRetryBackoff,
+		MaxRetryBackoff:  cfg.RedisMaxRetryBackoff,
+		TLSConfig:        tlsConfig,
+	}
+
+	// 不交给 NewUniversalClient 按地址数量推断，单个种子节点的 Cluster 也能正确识别
+	switch cfg.RedisMode {
+	case "", RedisModeSingle:
+		opts.Addrs = []string{cfg.RedisAddress}
+		return redis.NewClient(opts.Simple()), nil
+	case RedisModeSentinel:
+		if cfg.RedisMasterName == "" || len(cfg.RedisAddresses) == 0 {
+			return nil, fmt.Errorf("sentinel 模式需要配置 cache.redis_master_name 与 cache.redis_addresses")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisModeCluster:
+		if len(cfg.RedisAddresses) == 0 {
+			return nil, fmt.Errorf("cluster 模式需要配置 cache.redis_addresses")
+		}
+		if cfg.RedisDB != 0 {
+			return nil, fmt.Errorf("cluster 模式不支持 cache.redis_db=%d", cfg.RedisDB)
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("未知的 Redis 部署方式: %s", cfg.RedisMode)
+	}
+}
+
+// redisTLSConfig 根据 cache.redis_tls_* 构建 TLS 配置，未启用时返回 nil
+func redisTLSConfig(cfg config.Cache) (*tls.Config, error) {
+	if !cfg.RedisTLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.RedisTLSServerName,
+		InsecureSkipVerify: cfg.RedisTLSSkipVerify,
+	}
+	if cfg.RedisTLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.RedisTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Redis CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Redis CA 证书 %s 中没有有效的证书", cfg.RedisTLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.RedisTLSCertFile != "" || cfg.RedisTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RedisTLSCertFile, cfg.RedisTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 Redis 客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrSnapshotVersion = errors.New("快照格式版本不受支持")
+	ErrSnapshotCorrupt = errors.New("快照文件已损坏")
+)
+
+// 快照文件格式：8 字节魔数、2 字节版本、创建时间（Unix 毫秒）、命名空间代数、条目数，
+// 随后逐条写入键长度与键、值长度与值、新鲜截止时间（Unix 毫秒，0 表示永不过期）、命中次数，
+// 末尾 32 字节为之前全部内容的 SHA-256。整数均为大端序
+const (
+	snapshotMagic   = "MSVSNAP\x00"
+	snapshotVersion = 1
+
+	snapshotHeaderSize = len(snapshotMagic) + 2 + 8 + 8 + 4
+	// snapshotMaxKey 为单个键长度的上限，超出视为文件损坏
+	snapshotMaxKey = 4096
+)
+
+// hotEntry 是本地缓存层中的一个条目及其命中次数，value 带有新鲜截止时间标记
+type hotEntry struct {
+	key   string
+	value []byte
+	hits  uint32
+}
+
+// hotLister 由能按命中次数列出热点条目的本地缓存层实现
+type hotLister interface {
+	hottest(limit int) []hotEntry
+}
+
+// SnapshotEntry 是快照中的一个缓存条目，Key 带命名空间代数，Value 不含新鲜截止时间标记
+type SnapshotEntry struct {
+	Key        string
+	Value      []byte
+	FreshUntil time.Time
+	Hits       uint32
+}
+
+// Snapshot 是停机时保存的本地热点缓存，Generation 不同的快照在恢复时整体作废
+type Snapshot struct {
+	CreatedAt  time.Time
+	Generation uint64
+	Entries    []SnapshotEntry
+}
+
+// WriteSnapshot 先写入同目录的临时文件再重命名，避免停机中断留下半个文件
+func WriteSnapshot(path string, snap Snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(encodeSnapshot(snap))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// ReadSnapshot 读取并校验快照文件，版本不符返回 ErrSnapshotVersion，校验和或结构不符返回 ErrSnapshotCorrupt
+func ReadSnapshot(path string) (Snapshot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return decodeSnapshot(raw)
+}
+
+func encodeSnapshot(snap Snapshot) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(snapshotVersion))
+	_ = binary.Write(&buf, binary.BigEndian, snap.CreatedAt.UnixMilli())
+	_ = binary.Write(&buf, binary.BigEndian, snap.Generation)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(snap.Entries)))
+	for _, entry := range snap.Entries {
+		var until int64
+		if !entry.FreshUntil.IsZero() {
+			until = entry.FreshUntil.UnixMilli()
+		}
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(entry.Key)))
+		buf.WriteString(entry.Key)
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(entry.Value)))
+		buf.Write(entry.Value)
+		_ = binary.Write(&buf, binary.BigEndian, until)
+		_ = binary.Write(&buf, binary.BigEndian, entry.Hits)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+func decodeSnapshot(raw []byte) (Snapshot, error) {
+	if len(raw) < snapshotHeaderSize+sha256.Size || string(raw[:len(snapshotMagic)]) != snapshotMagic {
+		return Snapshot{}, ErrSnapshotCorrupt
+	}
+	if version := binary.BigEndian.Uint16(raw[len(snapshotMagic):]); version != snapshotVersion {
+		return Snapshot{}, fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	body, sum := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if expected := sha256.Sum256(body); !bytes.Equal(expected[:], sum) {
+		return Snapshot{}, ErrSnapshotCorrupt
+	}
+
+	r := bytes.NewReader(body[len(snapshotMagic)+2:])
+	var header struct {
+		CreatedAtMS int64
+		Generation  uint64
+		Count       uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return Snapshot{}, ErrSnapshotCorrupt
+	}
+	snap := Snapshot{CreatedAt: time.UnixMilli(header.CreatedAtMS), Generation: header.Generation}
+	for i := uint32(0); i < header.Count; i++ {
+		entry, err := readSnapshotEntry(r)
+		if err != nil {
+			return Snapshot{}, ErrSnapshotCorrupt
+		}
+		snap.Entries = append(snap.Entries, entry)
+	}
+	if r.Len() != 0 {
+		return Snapshot{}, ErrSnapshotCorrupt
+	}
+	return snap, nil
+}
+
+// readSnapshotEntry 读取单个条目，长度字段超出剩余内容时返回错误，避免按损坏的长度分配内存
+func readSnapshotEntry(r *bytes.Reader) (SnapshotEntry, error) {
+	var keyLen uint16
+	if err := binary.Read(r, binary.BigEndian, &keyLen); err != nil {
+		return SnapshotEntry{}, err
+	}
+	if int(keyLen) > snapshotMaxKey || int(keyLen) > r.Len() {
+		return SnapshotEntry{}, io.ErrUnexpectedEOF
+	}
+	key := make([]byte, keyLen)
+	_, _ = r.Read(key)
+
+	var valueLen uint32
+	if err := binary.Read(r, binary.BigEndian, &valueLen); err != nil {
+		return SnapshotEntry{}, err
+	}
+	if int64(valueLen) > int64(r.Len()) {
+		return SnapshotEntry{}, io.ErrUnexpectedEOF
+	}
+	value := make([]byte, valueLen)
+	_, _ = r.Read(value)
+
+	var tail struct {
+		FreshUntilMS int64
+		Hits         uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &tail); err != nil {
+		return SnapshotEntry{}, err
+	}
+	entry := SnapshotEntry{Key: string(key), Value: value, Hits: tail.Hits}
+	if tail.FreshUntilMS != 0 {
+		entry.FreshUntil = time.UnixMilli(tail.FreshUntilMS)
+	}
+	return entry, nil
+}
+
+// SaveSnapshot 将首个支持热点列举的缓存层中命中次数最高的条目写入快照文件：
+// 至多 limit 条、值的总大小不超过 maxBytes（0 表示不限），已过新鲜期的条目不保存。返回写入的条目数
+func (m *Manager) SaveSnapshot(path string, limit int, maxBytes int64) (int, error) {
+	level := m.hotLevel()
+	if level < 0 {
+		return 0, fmt.Errorf("缓存组合中没有可生成快照的本地缓存层")
+	}
+
+	now := time.Now()
+	snap := Snapshot{CreatedAt: now, Generation: m.Generation()}
+	var size int64
+	for _, hot := range m.tiers[level].(hotLister).hottest(limit) {
+		value, until := unstamp(hot.value)
+		if !until.IsZero() && now.After(until) {
+			continue
+		}
+		if maxBytes > 0 && size+int64(len(value)) > maxBytes {
+			break
+		}
+		size += int64(len(value))
+		snap.Entries = append(snap.Entries, SnapshotEntry{Key: hot.key, Value: value, FreshUntil: until, Hits: hot.hits})
+	}
+	if err := WriteSnapshot(path, snap); err != nil {
+		return 0, err
+	}
+	return len(snap.Entries), nil
+}
+
+// RestoreSnapshot 读取快照写回首个支持热点列举的缓存层，新鲜截止时间不晚于快照中记录的时间。
+// 命名空间代数与当前不同的快照整体跳过，已过期或被 keep 拒绝的条目逐条跳过；keep 为 nil 时全部保留
+func (m *Manager) RestoreSnapshot(ctx context.Context, path string, keep func(key string, value []byte) bool) (restored, skipped int, err error) {
+	snap, err := ReadSnapshot(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	level := m.hotLevel()
+	if level < 0 || snap.Generation != m.Generation() {
+		return 0, len(snap.Entries), nil
+	}
+
+	now := time.Now()
+	for _, entry := range snap.Entries {
+		if ctx.Err() != nil {
+			return restored, skipped, ctx.Err()
+		}
+		if (!entry.FreshUntil.IsZero() && now.After(entry.FreshUntil)) || (keep != nil && !keep(entry.Key, entry.Value)) {
+			skipped++
+			continue
+		}
+		m.store(ctx, level, entry.Key, entry.Value, freshUntil(now, m.policies[level], entry.FreshUntil))
+		restored++
+	}
+	return restored, skipped, nil
+}
+
+// hotLevel 返回首个支持热点列举的缓存层下标，没有时返回 -1
+func (m *Manager) hotLevel() int {
+	for i, tier := range m.tiers {
+		if _, ok := tier.(hotLister); ok {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+	until := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	snap := Snapshot{
+		CreatedAt:  time.Now().Truncate(time.Millisecond),
+		Generation: 2,
+		Entries: []SnapshotEntry{
+			{Key: "g2:a", Value: []byte("<svg/>"), FreshUntil: until, Hits: 7},
+			{Key: "g2:b", Value: []byte{}},
+		},
+	}
+	if err := WriteSnapshot(path, snap); err != nil {
+		t.Fatalf("写入快照失败: %v", err)
+	}
+	got, err := ReadSnapshot(path)
+	if err != nil {
+		t.Fatalf("读取快照失败: %v", err)
+	}
+	if got.Generation != 2 || !got.CreatedAt.Equal(snap.CreatedAt) || len(got.Entries) != 2 {
+		t.Fatalf("快照头部不一致: %+v", got)
+	}
+	if e := got.Entries[0]; e.Key != "g2:a" || string(e.Value) != "<svg/>" || !e.FreshUntil.Equal(until) || e.Hits != 7 {
+		t.Fatalf("快照条目不一致: %+v", e)
+	}
+	if e := got.Entries[1]; !e.FreshUntil.IsZero() || len(e.Value) != 0 {
+		t.Fatalf("永不过期的空条目应原样恢复: %+v", e)
+	}
+
+	raw, _ := os.ReadFile(path)
+	corrupted := append([]byte(nil), raw...)
+	corrupted[len(snapshotMagic)+20] ^= 0xff
+	_ = os.WriteFile(path, corrupted, 0o644)
+	if _, err := ReadSnapshot(path); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Fatalf("内容被改动应校验失败，实际: %v", err)
+	}
+
+	future := append([]byte(nil), raw...)
+	future[len(snapshotMagic)+1] = snapshotVersion + 1
+	_ = os.WriteFile(path, future, 0o644)
+	if _, err := ReadSnapshot(path); !errors.Is(err, ErrSnapshotVersion) {
+		t.Fatalf("未知版本应被拒绝，实际: %v", err)
+	}
+}
+
+func TestManager_SnapshotSaveRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+	large := "<svg>" + strings.Repeat("x", 8<<10) + "</svg>"
+
+	m := NewManagerWithTiers(config.Cache{}, []Tier{newTestBigCacheTier(t, 1)}, zap.NewNop())
+	m.Set(ctx, "cold", "<svg>cold</svg>")
+	m.Set(ctx, "hot", "<svg>hot</svg>")
+	m.Set(ctx, "large", large)
+	for i := 0; i < 3; i++ {
+		m.Get(ctx, "hot")
+	}
+	for i := 0; i < 2; i++ {
+		m.Get(ctx, "large")
+	}
+
+	saved, err := m.SaveSnapshot(path, 2, 0)
+	if err != nil || saved != 2 {
+		t.Fatalf("应保存命中最多的 2 个条目: %d %v", saved, err)
+	}
+
+	restoredTo := NewManagerWithTiers(config.Cache{}, []Tier{newTestBigCacheTier(t, 1)}, zap.NewNop())
+	restored, skipped, err := restoredTo.RestoreSnapshot(ctx, path, func(_ string, value []byte) bool {
+		return string(value) != large
+	})
+	if err != nil || restored != 1 || skipped != 1 {
+		t.Fatalf("筛选后应恢复 1 条、跳过 1 条: %d %d %v", restored, skipped, err)
+	}
+	if value, level := restoredTo.Get(ctx, "hot"); value != "<svg>hot</svg>" || level != HitLocal {
+		t.Fatalf("恢复后应命中本地缓存: %q %s", value, level)
+	}
+	for _, key := range []string{"cold", "large"} {
+		if _, level := restoredTo.Get(ctx, key); level != HitNone {
+			t.Fatalf("%s 不应被恢复，实际: %s", key, level)
+		}
+	}
+
+	// 清空全部缓存后代数提升，旧快照整体作废
+	purged := NewManagerWithTiers(config.Cache{}, []Tier{newTestBigCacheTier(t, 1)}, zap.NewNop())
+	if _, err := purged.PurgeAll(ctx); err != nil {
+		t.Fatalf("清空全部缓存失败: %v", err)
+	}
+	if restored, skipped, err := purged.RestoreSnapshot(ctx, path, nil); err != nil || restored != 0 || skipped != 2 {
+		t.Fatalf("代数不同的快照应整体跳过: %d %d %v", restored, skipped, err)
+	}
+}
+
+func TestManager_SnapshotRequiresLocalTier(t *testing.T) {
+	m := NewManagerWithTiers(config.Cache{}, []Tier{NewMemoryTier(TierMemory, TierPolicy{})}, zap.NewNop())
+	if _, err := m.SaveSnapshot(filepath.Join(t.TempDir(), "snapshot.bin"), 10, 0); err == nil {
+		t.Fatal("没有本地缓存层时应拒绝保存快照")
+	}
+}
@@ -1,34 +1,38 @@
 package cache
 
+// Stats 描述缓存的关键运行指标
+type Stats struct {
+	LocalEntries int
+	HitsLocal    uint64
+	HitsRedis    uint64
+	Misses       uint64
+	StaleServed  uint64
+	RedisEnabled bool
+	RedisAlive   bool
+	Tiers        []TierStats
+	Invalidation InvalidationStats
+}
+
 // Stats 返回缓存当前关键指标，用于健康检查等场景
 func (m *Manager) Stats() Stats {
-	return Stats{
-		LocalEntries: m.local.Len(),
-		HitsLocal:    m.hitsLocal.Load(),
-		HitsRedis:    m.hitsRedis.Load(),
+	stats := Stats{
 		Misses:       m.misses.Load(),
-		RedisEnabled: m.redisEnabled,
-		RedisAlive:   m.redisAlive.Load(),
-	}
-}
-
-func (m *Manager) markRedisAlive(alive bool) {
-	if !m.redisEnabled {
-		m.redisAlive.Store(false)
-		return
+		StaleServed:  m.staleServed.Load(),
+		Tiers:        make([]TierStats, 0, len(m.tiers)),
+		Invalidation: m.invalidationStats(),
 	}
-
-	prev := m.redisAlive.Load()
-	if alive {
-		m.redisAlive.Store(true)
-		if !prev {
-			m.logger.Info("Redis 恢复可用")
+	for _, tier := range m.tiers {
+		tierStats := tier.Stats()
+		stats.Tiers = append(stats.Tiers, tierStats)
+		switch tier.(type) {
+		case *BigCacheTier:
+			stats.LocalEntries = tierStats.Entries
+			stats.HitsLocal = tierStats.Hits
+		case *RedisTier:
+			stats.HitsRedis = tierStats.Hits
+			stats.RedisEnabled = true
+			stats.RedisAlive = tierStats.Alive
 		}
-		return
-	}
-
-	m.redisAlive.Store(false)
-	if prev {
-		m.logger.Warn("Redis 不可用，降级为仅 BigCache")
 	}
+	return stats
 }
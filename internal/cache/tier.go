@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound 表示某一层缓存中不存在该键，不视为故障
+var ErrNotFound = errors.New("缓存未命中")
+
+// ErrEntryTooLarge 表示条目超出该层的容量上限被拒绝写入，属于预期情况
+var ErrEntryTooLarge = errors.New("条目超出缓存容量上限")
+
+// ErrUnavailable 表示该层暂时不可用（如 Redis 熔断中），调用被直接跳过
+var ErrUnavailable = errors.New("缓存层暂不可用")
+
+// Tier 是缓存的一层存储，Manager 按配置顺序逐层查找，下层命中时回填上层
+type Tier interface {
+	// Name 作为命中层级写入日志与响应，如 local、redis
+	Name() string
+	// Get 未命中时返回 ErrNotFound，其他错误视为该层暂不可用
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	// Touch 将已有条目开头的新鲜截止时间标记替换为 header，并按该层策略重新计算存活时间，
+	// 远程层不重新传输内容；条目不存在或缺少标记时返回 ErrNotFound
+	Touch(ctx context.Context, key string, header []byte) error
+	// Len 返回当前条目数，无法统计时返回 -1
+	Len() int
+	// Policy 返回该层的过期策略，由 Manager 统一判断新鲜度与续期
+	Policy() TierPolicy
+	Stats() TierStats
+	Close() error
+}
+
+// TierStats 描述单层缓存的运行指标
+type TierStats struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	// Rejected 为因超出容量上限而未能写入的次数
+	Rejected     uint64 `json:"rejected_too_large"`
+	LargeEntries int    `json:"large_entries,omitempty"`
+	LargeBytes   int64  `json:"large_bytes,omitempty"`
+	Alive        bool   `json:"alive"`
+	// CircuitOpen 表示该层处于熔断状态，请求被直接跳过
+	CircuitOpen bool `json:"circuit_open,omitempty"`
+}
@@ -20,6 +20,8 @@ type Server struct {
 	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`
 	MaxRequestBodyMB  int           `mapstructure:"max_request_body_mb"`
 	EnableCompression bool          `mapstructure:"enable_compression"`
+	BatchMaxItems     int           `mapstructure:"batch_max_items"`
+	BatchWorkers      int           `mapstructure:"batch_workers"`
 }
 
 // Log 用于描述日志文件的滚动策略
@@ -34,27 +36,113 @@ type Log struct {
 
 // Cache 用于配置一级与二级缓存策略
 type Cache struct {
-	LocalLifeWindow      time.Duration `mapstructure:"local_life_window"`
-	LocalCleanWindow     time.Duration `mapstructure:"local_clean_window"`
-	LocalHardMaxCacheMB  int           `mapstructure:"local_hard_max_cache_mb"`
-	RedisEnabled         bool          `mapstructure:"redis_enabled"`
-	RedisAddress         string        `mapstructure:"redis_address"`
-	RedisPassword        string        `mapstructure:"redis_password"`
-	RedisDB              int           `mapstructure:"redis_db"`
-	RedisDialTimeout     time.Duration `mapstructure:"redis_dial_timeout"`
-	RedisReadTimeout     time.Duration `mapstructure:"redis_read_timeout"`
-	RedisWriteTimeout    time.Duration `mapstructure:"redis_write_timeout"`
-	RedisTTL             time.Duration `mapstructure:"redis_ttl"`
-	RedisMaxRetries      int           `mapstructure:"redis_max_retries"`
-	RedisMinRetryBackoff time.Duration `mapstructure:"redis_min_retry_backoff"`
-	RedisMaxRetryBackoff time.Duration `mapstructure:"redis_max_retry_backoff"`
+	Tiers                   []string      `mapstructure:"tiers"`
+	LocalLifeWindow         time.Duration `mapstructure:"local_life_window"`
+	LocalCleanWindow        time.Duration `mapstructure:"local_clean_window"`
+	LocalHardMaxCacheMB     int           `mapstructure:"local_hard_max_cache_mb"`
+	LocalShards             int           `mapstructure:"local_shards"`
+	LocalMaxEntriesInWindow int           `mapstructure:"local_max_entries_in_window"`
+	LocalMaxEntrySize       int           `mapstructure:"local_max_entry_size"`
+	LocalLargeMaxMB         int           `mapstructure:"local_large_max_mb"`
+	LocalStaleWindow        time.Duration `mapstructure:"local_stale_window"`
+	LocalTouchInterval      time.Duration `mapstructure:"local_touch_interval"`
+	StaleWhileRevalidate    bool          `mapstructure:"stale_while_revalidate"`
+	DiskEnabled             bool          `mapstructure:"disk_enabled"`
+	DiskDir                 string        `mapstructure:"disk_dir"`
+	DiskMaxSizeMB           int           `mapstructure:"disk_max_size_mb"`
+	DiskTTL                 time.Duration `mapstructure:"disk_ttl"`
+	DiskStaleWindow         time.Duration `mapstructure:"disk_stale_window"`
+	DiskTouchInterval       time.Duration `mapstructure:"disk_touch_interval"`
+	// DiskRescanInterval 为重新扫描磁盘缓存目录、按全部文件统计大小并淘汰的间隔，0 表示只在启动时扫描
+	DiskRescanInterval time.Duration `mapstructure:"disk_rescan_interval"`
+	RedisEnabled       bool          `mapstructure:"redis_enabled"`
+	// RedisMode 为部署方式：single、sentinel 或 cluster
+	RedisMode    string `mapstructure:"redis_mode"`
+	RedisAddress string `mapstructure:"redis_address"`
+	// RedisAddresses 为 Sentinel 地址或 Cluster 种子节点，single 模式下忽略
+	RedisAddresses        []string      `mapstructure:"redis_addresses"`
+	RedisMasterName       string        `mapstructure:"redis_master_name"`
+	RedisUsername         string        `mapstructure:"redis_username"`
+	RedisPassword         string        `mapstructure:"redis_password"`
+	RedisSentinelUsername string        `mapstructure:"redis_sentinel_username"`
+	RedisSentinelPassword string        `mapstructure:"redis_sentinel_password"`
+	RedisDB               int           `mapstructure:"redis_db"`
+	RedisTLSEnabled       bool          `mapstructure:"redis_tls_enabled"`
+	RedisTLSCAFile        string        `mapstructure:"redis_tls_ca_file"`
+	RedisTLSCertFile      string        `mapstructure:"redis_tls_cert_file"`
+	RedisTLSKeyFile       string        `mapstructure:"redis_tls_key_file"`
+	RedisTLSServerName    string        `mapstructure:"redis_tls_server_name"`
+	RedisTLSSkipVerify    bool          `mapstructure:"redis_tls_skip_verify"`
+	RedisDialTimeout      time.Duration `mapstructure:"redis_dial_timeout"`
+	RedisReadTimeout      time.Duration `mapstructure:"redis_read_timeout"`
+	RedisWriteTimeout     time.Duration `mapstructure:"redis_write_timeout"`
+	RedisTTL              time.Duration `mapstructure:"redis_ttl"`
+	RedisStaleWindow      time.Duration `mapstructure:"redis_stale_window"`
+	RedisTouchInterval    time.Duration `mapstructure:"redis_touch_interval"`
+	RedisMaxRetries       int           `mapstructure:"redis_max_retries"`
+	RedisMinRetryBackoff  time.Duration `mapstructure:"redis_min_retry_backoff"`
+	RedisMaxRetryBackoff  time.Duration `mapstructure:"redis_max_retry_backoff"`
+	// RedisBreakerThreshold 为连续失败多少次后熔断 Redis 缓存层
+	RedisBreakerThreshold    int           `mapstructure:"redis_breaker_threshold"`
+	RedisReconnectMinBackoff time.Duration `mapstructure:"redis_reconnect_min_backoff"`
+	RedisReconnectMaxBackoff time.Duration `mapstructure:"redis_reconnect_max_backoff"`
+	// RedisSizeInterval 为后台刷新 Redis 键数量（/health 展示）的间隔，0 表示不统计
+	RedisSizeInterval time.Duration `mapstructure:"redis_size_interval"`
+	RenderLockEnabled bool          `mapstructure:"render_lock_enabled"`
+	RenderLockTTL     time.Duration `mapstructure:"render_lock_ttl"`
+	RenderLockPoll    time.Duration `mapstructure:"render_lock_poll"`
+	// InvalidationEnabled 开启后通过 Redis 频道广播清除操作，各实例逐出本地副本
+	InvalidationEnabled bool   `mapstructure:"invalidation_enabled"`
+	InvalidationChannel string `mapstructure:"invalidation_channel"`
+	// SnapshotPath 非空时，停机前将本地缓存中命中最多的条目写入该文件，下次启动时恢复
+	SnapshotPath       string `mapstructure:"snapshot_path"`
+	SnapshotMaxEntries int    `mapstructure:"snapshot_max_entries"`
+	SnapshotMaxMB      int    `mapstructure:"snapshot_max_mb"`
+}
+
+// Render 用于描述渲染结果的后处理策略
+type Render struct {
+	Accessibility bool   `mapstructure:"accessibility"`
+	SpeechLang    string `mapstructure:"speech_lang"`
+	// Compression 为缓存中 SVG/MathML 的压缩编码：gzip、br 或 none
+	Compression         string `mapstructure:"compression"`
+	CompressionMinBytes int    `mapstructure:"compression_min_bytes"`
+	// RefreshOnUpgrade 开启后，渲染库版本变化时在后台重新渲染最近 RefreshRecent 条公式
+	RefreshOnUpgrade bool `mapstructure:"refresh_on_upgrade"`
+	RefreshRecent    int  `mapstructure:"refresh_recent"`
+}
+
+// Renderer 用于描述 Rust 渲染共享库的加载与热更新方式
+type Renderer struct {
+	LibraryPath    string        `mapstructure:"library_path"`
+	Mode           string        `mapstructure:"mode"`
+	Workers        int           `mapstructure:"workers"`
+	WatchLibrary   bool          `mapstructure:"watch_library"`
+	WatchDebounce  time.Duration `mapstructure:"watch_debounce"`
+	CanaryFormulas []string      `mapstructure:"canary_formulas"`
+	MaxConcurrency int           `mapstructure:"max_concurrency"`
+	MaxQueue       int           `mapstructure:"max_queue"`
+	QueueTimeout   time.Duration `mapstructure:"queue_timeout"`
+	RetryAfter     time.Duration `mapstructure:"retry_after"`
+}
+
+// Admin 用于描述缓存管理接口的独立监听与鉴权
+type Admin struct {
+	Address          string `mapstructure:"address"`
+	Token            string `mapstructure:"token"`
+	MaxRequestBodyMB int    `mapstructure:"max_request_body_mb"`
+	WarmupMaxItems   int    `mapstructure:"warmup_max_items"`
+	WarmupWorkers    int    `mapstructure:"warmup_workers"`
 }
 
 // Config 汇总服务启动所需的所有配置模块
 type Config struct {
-	Server Server `mapstructure:"server"`
-	Log    Log    `mapstructure:"log"`
-	Cache  Cache  `mapstructure:"cache"`
+	Server   Server   `mapstructure:"server"`
+	Log      Log      `mapstructure:"log"`
+	Cache    Cache    `mapstructure:"cache"`
+	Render   Render   `mapstructure:"render"`
+	Renderer Renderer `mapstructure:"renderer"`
+	Admin    Admin    `mapstructure:"admin"`
 }
 
 // Load 负责读取配置文件与环境变量，返回结构化配置
@@ -98,6 +186,8 @@ func setDefaults() {
 	viper.SetDefault("server.shutdown_timeout", "5s")
 	viper.SetDefault("server.max_request_body_mb", 5)
 	viper.SetDefault("server.enable_compression", true)
+	viper.SetDefault("server.batch_max_items", 500)
+	viper.SetDefault("server.batch_workers", 8)
 
 	viper.SetDefault("log.filename", "logs/server.log")
 	viper.SetDefault("log.max_size_mb", 50)
@@ -106,20 +196,106 @@ func setDefaults() {
 	viper.SetDefault("log.compress", true)
 	viper.SetDefault("log.level", "info")
 
+	// 按顺序查找，下层命中时回填上层；disk、redis 层仅在对应开关启用时生效
+	viper.SetDefault("cache.tiers", []string{"local", "disk", "redis"})
 	viper.SetDefault("cache.local_life_window", "10m")
 	viper.SetDefault("cache.local_clean_window", "1m")
 	viper.SetDefault("cache.local_hard_max_cache_mb", 256)
+	// 分片数须为 2 的幂，单个分片容量为 local_hard_max_cache_mb / local_shards
+	viper.SetDefault("cache.local_shards", 1024)
+	viper.SetDefault("cache.local_max_entries_in_window", 100_000)
+	// 仅用于预分配，SVG 平均约 1.2KB
+	viper.SetDefault("cache.local_max_entry_size", 2048)
+	// 超出分片容量的条目存入大对象区，0 表示直接丢弃
+	viper.SetDefault("cache.local_large_max_mb", 64)
+	// 过期后在窗口内仍先返回旧值并在后台刷新，默认关闭
+	viper.SetDefault("cache.stale_while_revalidate", false)
+	// 各层的旧值窗口与滑动过期续期间隔，续期间隔为 0 表示不续期
+	viper.SetDefault("cache.local_stale_window", "10m")
+	viper.SetDefault("cache.local_touch_interval", "1m")
+	viper.SetDefault("cache.disk_enabled", false)
+	viper.SetDefault("cache.disk_dir", "data/cache")
+	viper.SetDefault("cache.disk_max_size_mb", 1024)
+	// 磁盘缓存默认只按容量淘汰，不设过期时间
+	viper.SetDefault("cache.disk_ttl", "0s")
+	viper.SetDefault("cache.disk_stale_window", "0s")
+	viper.SetDefault("cache.disk_touch_interval", "0s")
+	// prefork 子进程共用缓存目录，定期重新扫描才能按全部子进程写入的文件控制总大小
+	viper.SetDefault("cache.disk_rescan_interval", "1m")
 	viper.SetDefault("cache.redis_enabled", false)
+	// sentinel 模式需配置 redis_master_name 与 redis_addresses，cluster 模式需配置 redis_addresses
+	viper.SetDefault("cache.redis_mode", "single")
 	viper.SetDefault("cache.redis_address", "localhost:6379")
+	viper.SetDefault("cache.redis_addresses", []string{})
+	viper.SetDefault("cache.redis_master_name", "")
+	// 用户名为空时按 Redis 6 之前的方式仅用密码认证
+	viper.SetDefault("cache.redis_username", "")
 	viper.SetDefault("cache.redis_password", "")
+	viper.SetDefault("cache.redis_sentinel_username", "")
+	viper.SetDefault("cache.redis_sentinel_password", "")
+	// cluster 模式只能使用 0 号库
 	viper.SetDefault("cache.redis_db", 0)
+	// CA 文件为空时使用系统根证书；证书与私钥文件同时配置时启用双向认证
+	viper.SetDefault("cache.redis_tls_enabled", false)
+	viper.SetDefault("cache.redis_tls_ca_file", "")
+	viper.SetDefault("cache.redis_tls_cert_file", "")
+	viper.SetDefault("cache.redis_tls_key_file", "")
+	viper.SetDefault("cache.redis_tls_server_name", "")
+	viper.SetDefault("cache.redis_tls_skip_verify", false)
 	viper.SetDefault("cache.redis_dial_timeout", "500ms")
 	viper.SetDefault("cache.redis_read_timeout", "2s")
 	viper.SetDefault("cache.redis_write_timeout", "2s")
 	viper.SetDefault("cache.redis_ttl", "168h")
+	viper.SetDefault("cache.redis_stale_window", "24h")
+	viper.SetDefault("cache.redis_touch_interval", "10m")
 	viper.SetDefault("cache.redis_max_retries", 2)
 	viper.SetDefault("cache.redis_min_retry_backoff", "100ms")
 	viper.SetDefault("cache.redis_max_retry_backoff", "500ms")
+	viper.SetDefault("cache.redis_breaker_threshold", 3)
+	viper.SetDefault("cache.redis_reconnect_min_backoff", "1s")
+	viper.SetDefault("cache.redis_reconnect_max_backoff", "30s")
+	viper.SetDefault("cache.redis_size_interval", "1m")
+	// 跨实例渲染锁依赖 Redis，默认关闭
+	viper.SetDefault("cache.render_lock_enabled", false)
+	viper.SetDefault("cache.render_lock_ttl", "5s")
+	viper.SetDefault("cache.render_lock_poll", "50ms")
+	// 跨实例缓存失效同样依赖 Redis，未启用 Redis 时不生效
+	viper.SetDefault("cache.invalidation_enabled", true)
+	viper.SetDefault("cache.invalidation_channel", "mathsvg:cache-invalidate")
+	// 本地缓存快照默认关闭；prefork 模式下子进程无法优雅停机，不生效
+	viper.SetDefault("cache.snapshot_path", "")
+	viper.SetDefault("cache.snapshot_max_entries", 50_000)
+	viper.SetDefault("cache.snapshot_max_mb", 64)
+
+	viper.SetDefault("render.accessibility", true)
+	viper.SetDefault("render.speech_lang", "en")
+	// 浏览器仅在 HTTPS 下声明支持 br，默认 gzip 以便命中后直接返回
+	viper.SetDefault("render.compression", "gzip")
+	viper.SetDefault("render.compression_min_bytes", 256)
+	viper.SetDefault("render.refresh_on_upgrade", false)
+	viper.SetDefault("render.refresh_recent", 1000)
+
+	// 留空时交由 dlopen 按 LD_LIBRARY_PATH 等系统规则查找 libformula
+	viper.SetDefault("renderer.library_path", "")
+	// isolated 模式下共享库运行在子进程中，崩溃不会拖垮服务
+	viper.SetDefault("renderer.mode", "inprocess")
+	viper.SetDefault("renderer.workers", 4)
+	viper.SetDefault("renderer.watch_library", true)
+	viper.SetDefault("renderer.watch_debounce", "1s")
+	viper.SetDefault("renderer.canary_formulas", []string{`E=mc^2`, `\frac{a}{b}`, `\sqrt{x^2+y^2}`, `\sum_{i=1}^{n} i^2`})
+	// 0 表示按 CPU 核数限制同时进行的渲染
+	viper.SetDefault("renderer.max_concurrency", 0)
+	viper.SetDefault("renderer.max_queue", 256)
+	viper.SetDefault("renderer.queue_timeout", "1s")
+	viper.SetDefault("renderer.retry_after", "1s")
+
+	// 令牌为空时不启动管理接口；默认只监听本机，避免暴露到公网
+	viper.SetDefault("admin.address", "127.0.0.1:9090")
+	viper.SetDefault("admin.token", "")
+	viper.SetDefault("admin.max_request_body_mb", 20)
+	viper.SetDefault("admin.warmup_max_items", 100_000)
+	// 预热与线上请求共用渲染调度器，并发保持较低以免挤占正常流量
+	viper.SetDefault("admin.warmup_workers", 2)
 }
 
 // ensureLogDir 在加载配置时提前确保日志目录存在
@@ -0,0 +1,138 @@
+package latex
+
+// Node 是 LaTeX 语法树中的节点，MathML 与朗读文本都基于它生成
+type Node interface {
+	isNode()
+}
+
+// Row 表示按顺序排列的一组节点，对应 MathML 的 mrow
+type Row struct {
+	Children []Node
+}
+
+// Ident 表示变量、函数名等标识符
+type Ident struct {
+	Text     string
+	Name     string // 来源命令名，例如 alpha、sin；普通字母为空
+	Variant  string // mathvariant，例如 normal、bold
+	Function bool   // 是否为函数名，输出时补充函数应用符
+}
+
+// Number 表示数字常量
+type Number struct {
+	Text string
+}
+
+// Operator 表示运算符、关系符、分隔符以及大型运算符
+type Operator struct {
+	Text     string
+	Name     string // 来源命令名，普通字符为空
+	LargeOp  bool   // 求和、积分等大型运算符
+	Movable  bool   // 行内时上下限移到右侧，如 \sum、\lim
+	Limits   int    // 1 表示 \limits，-1 表示 \nolimits，0 表示默认
+	Function bool   // \lim、\max 等函数型运算符
+	Fence    bool   // 由 \left、\right、\middle 产生的可伸缩分隔符
+	Form     string // prefix、infix、postfix，仅分隔符使用
+	Size     string // \big 系列命令指定的尺寸
+}
+
+// Text 表示 \text 等命令中的普通文本
+type Text struct {
+	Text    string
+	Variant string
+}
+
+// Space 表示显式间距或换行
+type Space struct {
+	Width   string
+	Newline bool
+}
+
+// Frac 表示分数与二项式系数
+type Frac struct {
+	Num    Node
+	Den    Node
+	NoLine bool   // 二项式系数不画分数线
+	Style  string // display、text 或空（沿用上下文）
+}
+
+// Sqrt 表示平方根与 n 次根
+type Sqrt struct {
+	Radicand Node
+	Index    Node
+}
+
+// Scripts 表示上下标，是否放在正上/正下方由底数决定
+type Scripts struct {
+	Base Node
+	Sub  Node
+	Sup  Node
+}
+
+// Accent 表示 \hat、\overline、\underbrace 等附加在底数上的记号
+type Accent struct {
+	Base     Node
+	Mark     string
+	Name     string
+	Under    bool
+	Stretchy bool
+	Limits   bool // 花括号类记号的上下标放在正上/正下方
+}
+
+// UnderOver 表示 \overset、\underset 等显式的上下堆叠
+type UnderOver struct {
+	Base  Node
+	Under Node
+	Over  Node
+}
+
+// Fenced 表示 \left ... \right 包围的内容
+type Fenced struct {
+	Open  string
+	Close string
+	Body  Node
+}
+
+// Style 表示字体、显示样式与颜色等作用于一段内容的样式
+type Style struct {
+	Body    Node
+	Variant string
+	Display string // "true"、"false" 或空
+	Level   string // scriptlevel
+	Color   string
+}
+
+// Table 表示矩阵、cases 与对齐环境
+type Table struct {
+	Env   string
+	Rows  [][]Node
+	Align []string
+}
+
+// Enclose 表示 \boxed 等带边框的内容
+type Enclose struct {
+	Notation string
+	Body     Node
+}
+
+// Phantom 表示占位但不显示的内容
+type Phantom struct {
+	Body Node
+}
+
+func (*Row) isNode()       {}
+func (*Ident) isNode()     {}
+func (*Number) isNode()    {}
+func (*Operator) isNode()  {}
+func (*Text) isNode()      {}
+func (*Space) isNode()     {}
+func (*Frac) isNode()      {}
+func (*Sqrt) isNode()      {}
+func (*Scripts) isNode()   {}
+func (*Accent) isNode()    {}
+func (*UnderOver) isNode() {}
+func (*Fenced) isNode()    {}
+func (*Style) isNode()     {}
+func (*Table) isNode()     {}
+func (*Enclose) isNode()   {}
+func (*Phantom) isNode()   {}
@@ -0,0 +1,53 @@
+package latex
+
+import "fmt"
+
+// 解析错误的稳定代码，供 API 返回给调用方
+const (
+	CodeUnsupportedCommand     = "unsupported_command"
+	CodeUnknownEnvironment     = "unknown_environment"
+	CodeUnbalancedBraces       = "unbalanced_braces"
+	CodeUnbalancedDelimiters   = "unbalanced_delimiters"
+	CodeMismatchedEnvironment  = "mismatched_environment"
+	CodeMissingArgument        = "missing_argument"
+	CodeInvalidDelimiter       = "invalid_delimiter"
+	CodeDoubleScript           = "double_script"
+	CodeUnexpectedToken        = "unexpected_token"
+	CodeTrailingBackslash      = "trailing_backslash"
+	CodeMisplacedAlignmentChar = "misplaced_alignment"
+)
+
+// Error 描述 LaTeX 解析失败的原因与位置，行列均从 1 开始
+type Error struct {
+	Code    string
+	Message string
+	Command string
+	Offset  int
+	Line    int
+	Column  int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s（第 %d 行第 %d 列）", e.Message, e.Line, e.Column)
+}
+
+// errorAt 根据字符偏移量补全行列信息
+func (p *parser) errorAt(offset int, code, command, message string) *Error {
+	line, column := 1, 1
+	for i := 0; i < offset && i < len(p.src); i++ {
+		if p.src[i] == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+	return &Error{
+		Code:    code,
+		Message: message,
+		Command: command,
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+	}
+}
@@ -0,0 +1,396 @@
+package latex
+
+import (
+	"strconv"
+	"strings"
+)
+
+// functionApplication 是 MathML 中表示函数作用的不可见运算符
+const functionApplication = "⁡"
+
+// MathMLOptions 描述 MathML 输出的外层属性
+type MathMLOptions struct {
+	Display  bool    // 块级公式
+	Color    string  // mathcolor，空字符串表示不设置
+	FontSize float64 // mathsize 像素值，0 表示不设置
+}
+
+// ToMathML 将 LaTeX 转换为 Presentation MathML，原始公式作为 annotation 保留
+func ToMathML(tex string, opts MathMLOptions) (string, error) {
+	root, err := Parse(tex)
+	if err != nil {
+		return "", err
+	}
+
+	w := &mathmlWriter{}
+	w.b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if opts.Display {
+		w.b.WriteString(` display="block"`)
+	}
+	w.b.WriteString(`><semantics>`)
+
+	var styleAttrs []string
+	if opts.Color != "" {
+		styleAttrs = append(styleAttrs, "mathcolor", opts.Color)
+	}
+	if opts.FontSize > 0 {
+		styleAttrs = append(styleAttrs, "mathsize", formatPixels(opts.FontSize))
+	}
+	if len(styleAttrs) > 0 {
+		w.open("mstyle", styleAttrs...)
+		w.arg(root)
+		w.close("mstyle")
+	} else {
+		w.arg(root)
+	}
+
+	w.b.WriteString(`<annotation encoding="application/x-tex">`)
+	w.b.WriteString(escapeXML(tex))
+	w.b.WriteString(`</annotation></semantics></math>`)
+	return w.b.String(), nil
+}
+
+type mathmlWriter struct {
+	b       strings.Builder
+	variant string // 由 \mathbf 等命令下推到记号元素的字体
+}
+
+// open 写入起始标签，attrs 按名称、值成对出现
+func (w *mathmlWriter) open(tag string, attrs ...string) {
+	w.b.WriteByte('<')
+	w.b.WriteString(tag)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		w.b.WriteByte(' ')
+		w.b.WriteString(attrs[i])
+		w.b.WriteString(`="`)
+		w.b.WriteString(escapeXML(attrs[i+1]))
+		w.b.WriteByte('"')
+	}
+	w.b.WriteByte('>')
+}
+
+func (w *mathmlWriter) close(tag string) {
+	w.b.WriteString("</")
+	w.b.WriteString(tag)
+	w.b.WriteByte('>')
+}
+
+func (w *mathmlWriter) token(tag, text string, attrs ...string) {
+	w.open(tag, attrs...)
+	w.b.WriteString(escapeXML(text))
+	w.close(tag)
+}
+
+// arg 保证输出恰好一个元素，供 mfrac、msup 等要求固定子元素个数的场景使用
+func (w *mathmlWriter) arg(n Node) {
+	if row, ok := n.(*Row); ok {
+		if len(row.Children) == 1 {
+			w.arg(row.Children[0])
+			return
+		}
+		w.open("mrow")
+		w.seq(row.Children)
+		w.close("mrow")
+		return
+	}
+	w.node(n)
+}
+
+// seq 依次输出节点，函数名之后补充函数应用符
+func (w *mathmlWriter) seq(nodes []Node) {
+	for _, n := range nodes {
+		if row, ok := n.(*Row); ok {
+			w.seq(row.Children)
+			continue
+		}
+		w.node(n)
+		if needsFunctionApplication(n) {
+			w.token("mo", functionApplication)
+		}
+	}
+}
+
+func (w *mathmlWriter) node(n Node) {
+	switch n := n.(type) {
+	case *Row:
+		w.arg(n)
+	case *Ident:
+		w.ident(n)
+	case *Number:
+		w.token("mn", n.Text, w.variantAttrs("")...)
+	case *Text:
+		variant := n.Variant
+		if variant == "" && w.variant != "" && w.variant != "italic" {
+			variant = w.variant
+		}
+		var attrs []string
+		if variant != "" {
+			attrs = []string{"mathvariant", variant}
+		}
+		w.token("mtext", n.Text, attrs...)
+	case *Operator:
+		w.operator(n)
+	case *Space:
+		if n.Newline {
+			w.b.WriteString(`<mspace linebreak="newline"/>`)
+			return
+		}
+		w.b.WriteString(`<mspace width="` + n.Width + `"/>`)
+	case *Frac:
+		w.frac(n)
+	case *Sqrt:
+		if n.Index != nil {
+			w.open("mroot")
+			w.arg(n.Radicand)
+			w.arg(n.Index)
+			w.close("mroot")
+			return
+		}
+		w.open("msqrt")
+		w.seq([]Node{n.Radicand})
+		w.close("msqrt")
+	case *Scripts:
+		w.scripts(n)
+	case *Accent:
+		tag, flag := "mover", "accent"
+		if n.Under {
+			tag, flag = "munder", "accentunder"
+		}
+		w.open(tag, flag, "true")
+		w.arg(n.Base)
+		stretchy := "false"
+		if n.Stretchy {
+			stretchy = "true"
+		}
+		w.token("mo", n.Mark, "stretchy", stretchy)
+		w.close(tag)
+	case *UnderOver:
+		w.underOver(n.Base, n.Under, n.Over)
+	case *Fenced:
+		w.fenced(n)
+	case *Style:
+		w.style(n)
+	case *Table:
+		w.table(n)
+	case *Enclose:
+		w.open("menclose", "notation", n.Notation)
+		w.seq([]Node{n.Body})
+		w.close("menclose")
+	case *Phantom:
+		w.open("mphantom")
+		w.seq([]Node{n.Body})
+		w.close("mphantom")
+	}
+}
+
+func (w *mathmlWriter) ident(n *Ident) {
+	variant := w.variant
+	if variant == "" && n.Name != "" && !n.Function && isUpperGreek(n.Text) {
+		// 大写希腊字母在 TeX 中默认为正体
+		variant = "normal"
+	}
+	w.token("mi", n.Text, w.variantAttrs(variant)...)
+}
+
+func (w *mathmlWriter) variantAttrs(variant string) []string {
+	if variant == "" {
+		variant = w.variant
+	}
+	if variant == "" {
+		return nil
+	}
+	return []string{"mathvariant", variant}
+}
+
+func (w *mathmlWriter) operator(n *Operator) {
+	var attrs []string
+	if n.Fence {
+		attrs = append(attrs, "fence", "true", "stretchy", "true", "form", n.Form)
+	}
+	if n.Size != "" {
+		attrs = append(attrs, "minsize", n.Size, "maxsize", n.Size)
+	}
+	if n.LargeOp && !n.Movable && n.Limits == 1 {
+		attrs = append(attrs, "movablelimits", "false")
+	}
+	if n.Function {
+		attrs = append(attrs, "movablelimits", "true", "form", "prefix")
+	}
+	w.token("mo", n.Text, attrs...)
+}
+
+func (w *mathmlWriter) frac(n *Frac) {
+	switch n.Style {
+	case "display":
+		w.open("mstyle", "displaystyle", "true", "scriptlevel", "0")
+		defer w.close("mstyle")
+	case "text":
+		w.open("mstyle", "displaystyle", "false", "scriptlevel", "0")
+		defer w.close("mstyle")
+	}
+
+	if n.NoLine {
+		w.open("mfrac", "linethickness", "0")
+	} else {
+		w.open("mfrac")
+	}
+	w.arg(n.Num)
+	w.arg(n.Den)
+	w.close("mfrac")
+}
+
+func (w *mathmlWriter) scripts(n *Scripts) {
+	if usesLimits(n.Base) {
+		w.underOver(n.Base, n.Sub, n.Sup)
+		return
+	}
+	switch {
+	case n.Sub != nil && n.Sup != nil:
+		w.open("msubsup")
+		w.arg(n.Base)
+		w.arg(n.Sub)
+		w.arg(n.Sup)
+		w.close("msubsup")
+	case n.Sub != nil:
+		w.open("msub")
+		w.arg(n.Base)
+		w.arg(n.Sub)
+		w.close("msub")
+	default:
+		w.open("msup")
+		w.arg(n.Base)
+		w.arg(n.Sup)
+		w.close("msup")
+	}
+}
+
+func (w *mathmlWriter) underOver(base, under, over Node) {
+	switch {
+	case under != nil && over != nil:
+		w.open("munderover")
+		w.arg(base)
+		w.arg(under)
+		w.arg(over)
+		w.close("munderover")
+	case under != nil:
+		w.open("munder")
+		w.arg(base)
+		w.arg(under)
+		w.close("munder")
+	default:
+		w.open("mover")
+		w.arg(base)
+		w.arg(over)
+		w.close("mover")
+	}
+}
+
+func (w *mathmlWriter) fenced(n *Fenced) {
+	w.open("mrow")
+	if n.Open != "" {
+		w.token("mo", n.Open, "fence", "true", "stretchy", "true", "form", "prefix")
+	}
+	w.seq([]Node{n.Body})
+	if n.Close != "" {
+		w.token("mo", n.Close, "fence", "true", "stretchy", "true", "form", "postfix")
+	}
+	w.close("mrow")
+}
+
+func (w *mathmlWriter) style(n *Style) {
+	var attrs []string
+	if n.Display != "" {
+		attrs = append(attrs, "displaystyle", n.Display, "scriptlevel", n.Level)
+	}
+	if n.Color != "" {
+		attrs = append(attrs, "mathcolor", n.Color)
+	}
+
+	previous := w.variant
+	if n.Variant != "" {
+		w.variant = n.Variant
+	}
+	if len(attrs) > 0 {
+		w.open("mstyle", attrs...)
+		w.seq([]Node{n.Body})
+		w.close("mstyle")
+	} else {
+		w.arg(n.Body)
+	}
+	w.variant = previous
+}
+
+func (w *mathmlWriter) table(n *Table) {
+	var attrs []string
+	if len(n.Align) > 0 {
+		columns := 0
+		for _, row := range n.Rows {
+			if len(row) > columns {
+				columns = len(row)
+			}
+		}
+		// 对齐方式按列循环，aligned 等环境为右左交替
+		align := make([]string, columns)
+		for i := range align {
+			align[i] = n.Align[i%len(n.Align)]
+		}
+		attrs = append(attrs, "columnalign", strings.Join(align, " "))
+	}
+	if n.Env == "aligned" || n.Env == "align" || n.Env == "align*" || n.Env == "split" {
+		attrs = append(attrs, "columnspacing", "0em", "displaystyle", "true")
+	}
+
+	w.open("mtable", attrs...)
+	for _, row := range n.Rows {
+		w.open("mtr")
+		for _, cell := range row {
+			w.open("mtd")
+			w.seq([]Node{cell})
+			w.close("mtd")
+		}
+		w.close("mtr")
+	}
+	w.close("mtable")
+}
+
+// usesLimits 判断上下标应放在正上/正下方（munderover）还是右侧（msubsup）
+func usesLimits(base Node) bool {
+	switch b := base.(type) {
+	case *Operator:
+		if b.Limits != 0 {
+			return b.Limits > 0
+		}
+		return b.Movable
+	case *Accent:
+		return b.Limits
+	}
+	return false
+}
+
+// needsFunctionApplication 判断节点（或其上下标的底数）是否为函数名
+func needsFunctionApplication(n Node) bool {
+	switch v := n.(type) {
+	case *Ident:
+		return v.Function
+	case *Scripts:
+		if ident, ok := v.Base.(*Ident); ok {
+			return ident.Function
+		}
+	}
+	return false
+}
+
+func isUpperGreek(text string) bool {
+	r := []rune(text)
+	return len(r) == 1 && r[0] >= 'Α' && r[0] <= 'Ω'
+}
+
+func formatPixels(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + "px"
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func escapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
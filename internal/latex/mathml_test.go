@@ -0,0 +1,92 @@
+package latex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToMathML(t *testing.T) {
+	cases := map[string][]string{
+		`E=mc^2`: {
+			`<mi>E</mi><mo>=</mo><mi>m</mi><msup><mi>c</mi><mn>2</mn></msup>`,
+		},
+		`\frac{a+1}{\sqrt[3]{x}}`: {
+			`<mfrac><mrow><mi>a</mi><mo>+</mo><mn>1</mn></mrow><mroot><mi>x</mi><mn>3</mn></mroot></mfrac>`,
+		},
+		`\sum_{i=1}^{n} i^2`: {
+			`<munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover>`,
+		},
+		`\int_0^1 \sin x\,dx`: {
+			`<msubsup><mo>∫</mo><mn>0</mn><mn>1</mn></msubsup>`,
+			`<mi>sin</mi><mo>⁡</mo><mi>x</mi><mspace width="0.1667em"/>`,
+		},
+		`\left( \frac{1}{2} \right]`: {
+			`<mo fence="true" stretchy="true" form="prefix">(</mo>`,
+			`<mo fence="true" stretchy="true" form="postfix">]</mo>`,
+		},
+		`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`: {
+			`<mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable>`,
+		},
+		`\mathbf{v} \cdot \text{speed}`: {
+			`<mi mathvariant="bold">v</mi><mo>⋅</mo><mtext>speed</mtext>`,
+		},
+		`x_{10} < 3.14`: {
+			`<msub><mi>x</mi><mn>10</mn></msub><mo>&lt;</mo><mn>3.14</mn>`,
+		},
+	}
+
+	for tex, wants := range cases {
+		out, err := ToMathML(tex, MathMLOptions{})
+		if err != nil {
+			t.Fatalf("%s 转换失败: %v", tex, err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Fatalf("%s 的输出缺少 %s:\n%s", tex, want, out)
+			}
+		}
+		if !strings.Contains(out, `<annotation encoding="application/x-tex">`) {
+			t.Fatalf("输出应保留原始 TeX: %s", out)
+		}
+	}
+}
+
+func TestToMathML_Display(t *testing.T) {
+	out, err := ToMathML(`x`, MathMLOptions{Display: true, Color: "red"})
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+	if !strings.Contains(out, `display="block"`) || !strings.Contains(out, `<mstyle mathcolor="red">`) {
+		t.Fatalf("外层属性缺失: %s", out)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		tex     string
+		code    string
+		command string
+		line    int
+		column  int
+	}{
+		{`a + \foo{b}`, CodeUnsupportedCommand, "foo", 1, 5},
+		{"x\n+ {y", CodeUnbalancedBraces, "", 2, 3},
+		{`\left( x`, CodeUnbalancedDelimiters, "left", 1, 1},
+		{`x^2^3`, CodeDoubleScript, "", 1, 4},
+		{`\begin{foo} x \end{foo}`, CodeUnknownEnvironment, "foo", 1, 1},
+		{`\frac{1}`, CodeMissingArgument, "frac", 1, 1},
+		{`a & b`, CodeMisplacedAlignmentChar, "", 1, 3},
+	}
+
+	for _, tc := range cases {
+		_, err := Parse(tc.tex)
+		var parseErr *Error
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%q 应返回 *Error，实际: %v", tc.tex, err)
+		}
+		if parseErr.Code != tc.code || parseErr.Command != tc.command || parseErr.Line != tc.line || parseErr.Column != tc.column {
+			t.Fatalf("%q 的错误信息不符合预期: %+v", tc.tex, parseErr)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package latex
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokChar
+	tokCommand
+)
+
+// token 是扫描器产出的记号，命令不包含前导反斜杠
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+// parser 直接在源文本上扫描，便于 \text 等命令读取原始内容
+type parser struct {
+	src []rune
+	pos int
+}
+
+// stopFunc 判断当前列表是否应在该记号处结束
+type stopFunc func(token) bool
+
+// Parse 将 LaTeX 公式解析为语法树
+func Parse(tex string) (Node, error) {
+	p := &parser{src: []rune(tex)}
+	nodes, err := p.parseList(func(token) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+	return &Row{Children: nodes}, nil
+}
+
+// skipSpace 跳过空白与 % 注释
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if r == '%' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) next() token {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return token{kind: tokEOF, pos: p.pos}
+	}
+
+	start := p.pos
+	r := p.src[p.pos]
+	p.pos++
+	if r != '\\' {
+		return token{kind: tokChar, text: string(r), pos: start}
+	}
+
+	if p.pos >= len(p.src) {
+		return token{kind: tokCommand, pos: start}
+	}
+	if !isASCIILetter(p.src[p.pos]) {
+		name := string(p.src[p.pos])
+		p.pos++
+		return token{kind: tokCommand, text: name, pos: start}
+	}
+	nameStart := p.pos
+	for p.pos < len(p.src) && isASCIILetter(p.src[p.pos]) {
+		p.pos++
+	}
+	name := string(p.src[nameStart:p.pos])
+	return token{kind: tokCommand, text: name, pos: start}
+}
+
+func (p *parser) peek() token {
+	saved := p.pos
+	tok := p.next()
+	p.pos = saved
+	return tok
+}
+
+// parseList 解析一串原子直到 stop 返回 true 或输入结束
+func (p *parser) parseList(stop stopFunc) ([]Node, error) {
+	var nodes []Node
+	for {
+		tok := p.peek()
+		if tok.kind == tokEOF || stop(tok) {
+			return nodes, nil
+		}
+
+		if tok.kind == tokCommand {
+			// 样式切换与 \color 作用于当前分组的剩余部分
+			if spec, ok := styleSwitches[tok.text]; ok {
+				p.next()
+				rest, err := p.parseList(stop)
+				if err != nil {
+					return nil, err
+				}
+				return append(nodes, &Style{Body: &Row{Children: rest}, Display: spec[0], Level: spec[1]}), nil
+			}
+			if tok.text == "color" {
+				p.next()
+				color, err := p.readRawArgument(tok)
+				if err != nil {
+					return nil, err
+				}
+				rest, err := p.parseList(stop)
+				if err != nil {
+					return nil, err
+				}
+				return append(nodes, &Style{Body: &Row{Children: rest}, Color: strings.TrimSpace(color)}), nil
+			}
+		}
+
+		node, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// parseAtom 解析一个基本元素及其后的上下标
+func (p *parser) parseAtom() (Node, error) {
+	base, err := p.parsePrimary(false)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseScripts(base)
+}
+
+func (p *parser) parseScripts(base Node) (Node, error) {
+	var sub, sup Node
+	primes := 0
+	for {
+		tok := p.peek()
+		switch {
+		case tok.is(tokChar, "^"), tok.is(tokChar, "_"):
+			p.next()
+			arg, err := p.parseArgument(tok)
+			if err != nil {
+				return nil, err
+			}
+			if tok.text == "^" {
+				if sup != nil {
+					return nil, p.errorAt(tok.pos, CodeDoubleScript, "", "重复的上标，请用花括号分组")
+				}
+				sup = arg
+			} else {
+				if sub != nil {
+					return nil, p.errorAt(tok.pos, CodeDoubleScript, "", "重复的下标，请用花括号分组")
+				}
+				sub = arg
+			}
+		case tok.is(tokChar, "'"):
+			p.next()
+			primes++
+		case tok.is(tokCommand, "limits"), tok.is(tokCommand, "nolimits"):
+			p.next()
+			op, ok := base.(*Operator)
+			if !ok || !op.LargeOp && !op.Function {
+				return nil, p.errorAt(tok.pos, CodeUnexpectedToken, tok.text, fmt.Sprintf("\\%s 只能跟在大型运算符之后", tok.text))
+			}
+			if tok.text == "limits" {
+				op.Limits = 1
+			} else {
+				op.Limits = -1
+			}
+		default:
+			if primes > 0 {
+				mark := &Operator{Text: strings.Repeat("′", primes), Name: "prime"}
+				if sup == nil {
+					sup = mark
+				} else {
+					sup = &Row{Children: []Node{mark, sup}}
+				}
+			}
+			if sub == nil && sup == nil {
+				return base, nil
+			}
+			if base == nil {
+				base = &Row{}
+			}
+			return &Scripts{Base: base, Sub: sub, Sup: sup}, nil
+		}
+	}
+}
+
+// parseArgument 读取命令参数：花括号分组或单个记号（数字只取一位）
+func (p *parser) parseArgument(owner token) (Node, error) {
+	tok := p.peek()
+	if tok.kind == tokEOF || tok.is(tokChar, "}") || tok.is(tokChar, "&") || tok.is(tokChar, "^") || tok.is(tokChar, "_") {
+		return nil, p.missingArgument(owner)
+	}
+	node, err := p.parsePrimary(true)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, p.missingArgument(owner)
+	}
+	return node, nil
+}
+
+func (p *parser) missingArgument(owner token) *Error {
+	name := owner.text
+	if owner.kind == tokCommand {
+		name = `\` + name
+	}
+	return p.errorAt(owner.pos, CodeMissingArgument, owner.text, fmt.Sprintf("%s 缺少参数", name))
+}
+
+// parseGroup 解析 {...}，调用方需确保下一个记号是左花括号
+func (p *parser) parseGroup() (Node, error) {
+	open := p.next()
+	nodes, err := p.parseList(func(t token) bool { return t.is(tokChar, "}") })
+	if err != nil {
+		return nil, err
+	}
+	if !p.next().is(tokChar, "}") {
+		return nil, p.errorAt(open.pos, CodeUnbalancedBraces, "", "花括号未闭合")
+	}
+	return &Row{Children: nodes}, nil
+}
+
+// parsePrimary 解析单个基本元素；single 为 true 时数字只取一位，与 TeX 参数规则一致
+func (p *parser) parsePrimary(single bool) (Node, error) {
+	tok := p.peek()
+	if tok.kind == tokCommand {
+		p.next()
+		return p.parseCommand(tok)
+	}
+
+	switch tok.text {
+	case "{":
+		return p.parseGroup()
+	case "}":
+		p.next()
+		return nil, p.errorAt(tok.pos, CodeUnbalancedBraces, "", "多余的右花括号")
+	case "&":
+		p.next()
+		return nil, p.errorAt(tok.pos, CodeMisplacedAlignmentChar, "", "& 只能出现在矩阵或对齐环境中")
+	case "^", "_":
+		// 缺少底数的上下标，例如 ^2，以空底数处理
+		return &Row{}, nil
+	}
+
+	p.next()
+	r := []rune(tok.text)[0]
+	switch {
+	case unicode.IsDigit(r):
+		if single {
+			return &Number{Text: tok.text}, nil
+		}
+		return &Number{Text: p.readNumber(tok)}, nil
+	case unicode.IsLetter(r):
+		return &Ident{Text: tok.text}, nil
+	case r == '~':
+		return &Space{Width: "0.25em"}, nil
+	case r == '\'':
+		return &Operator{Text: "′", Name: "prime"}, nil
+	default:
+		return &Operator{Text: tok.text}, nil
+	}
+}
+
+// readNumber 将连续数字与小数点合并为一个数
+func (p *parser) readNumber(first token) string {
+	var b strings.Builder
+	b.WriteString(first.text)
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if unicode.IsDigit(r) {
+			b.WriteRune(r)
+			p.pos++
+			continue
+		}
+		if r == '.' && p.pos+1 < len(p.src) && unicode.IsDigit(p.src[p.pos+1]) {
+			b.WriteRune(r)
+			p.pos++
+			continue
+		}
+		break
+	}
+	return b.String()
+}
+
+// readRawArgument 读取花括号中的原始文本，用于 \text、\begin 等命令
+func (p *parser) readRawArgument(owner token) (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return "", p.missingArgument(owner)
+	}
+	if p.src[p.pos] != '{' {
+		r := p.src[p.pos]
+		p.pos++
+		return string(r), nil
+	}
+
+	open := p.pos
+	p.pos++
+	depth := 0
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '\\' && p.pos+1 < len(p.src):
+			escaped := p.src[p.pos+1]
+			p.pos += 2
+			if strings.ContainsRune("{}$%&#_ ", escaped) {
+				b.WriteRune(escaped)
+			} else {
+				b.WriteRune('\\')
+				b.WriteRune(escaped)
+			}
+			continue
+		case r == '{':
+			depth++
+		case r == '}':
+			if depth == 0 {
+				p.pos++
+				return b.String(), nil
+			}
+			depth--
+		}
+		b.WriteRune(r)
+		p.pos++
+	}
+	return "", p.errorAt(open, CodeUnbalancedBraces, owner.text, "花括号未闭合")
+}
+
+// readOptional 读取 [..] 形式的可选参数，不存在时返回 nil
+func (p *parser) readOptional() (Node, error) {
+	if !p.peek().is(tokChar, "[") {
+		return nil, nil
+	}
+	open := p.next()
+	nodes, err := p.parseList(func(t token) bool { return t.is(tokChar, "]") })
+	if err != nil {
+		return nil, err
+	}
+	if !p.next().is(tokChar, "]") {
+		return nil, p.errorAt(open.pos, CodeUnbalancedDelimiters, "", "方括号未闭合")
+	}
+	return &Row{Children: nodes}, nil
+}
+
+func (p *parser) parseCommand(tok token) (Node, error) {
+	name := tok.text
+	if name == "" {
+		return nil, p.errorAt(tok.pos, CodeTrailingBackslash, "", "公式末尾多余的反斜杠")
+	}
+
+	if sym, ok := symbols[name]; ok {
+		switch sym.kind {
+		case symIdent:
+			return &Ident{Text: sym.text, Name: name}, nil
+		case symLarge:
+			return &Operator{Text: sym.text, Name: name, LargeOp: true, Movable: true}, nil
+		case symIntegral:
+			return &Operator{Text: sym.text, Name: name, LargeOp: true}, nil
+		default:
+			return &Operator{Text: sym.text, Name: name}, nil
+		}
+	}
+	if functions[name] {
+		return &Ident{Text: name, Name: name, Function: true}, nil
+	}
+	if text, ok := limitFunctions[name]; ok {
+		return &Operator{Text: text, Name: name, Movable: true, Function: true}, nil
+	}
+	if width, ok := spaces[name]; ok {
+		return &Space{Width: width}, nil
+	}
+	if variant, ok := fontVariants[name]; ok {
+		body, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Style{Body: body, Variant: variant}, nil
+	}
+	if variant, ok := textVariants[name]; ok {
+		text, err := p.readRawArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Text{Text: text, Variant: variant}, nil
+	}
+	if spec, ok := accents[name]; ok {
+		body, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Accent{Base: body, Mark: spec.mark, Name: name, Under: spec.under, Stretchy: spec.stretchy, Limits: spec.limits}, nil
+	}
+	if size, ok := bigSizes[name]; ok {
+		delim, err := p.parseDelimiter(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Operator{Text: delim, Name: name, Size: size}, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac", "binom", "dbinom", "tbinom":
+		return p.parseFrac(tok)
+	case "sqrt":
+		index, err := p.readOptional()
+		if err != nil {
+			return nil, err
+		}
+		radicand, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Sqrt{Radicand: radicand, Index: index}, nil
+	case "operatorname":
+		text, err := p.readRawArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Ident{Text: strings.TrimSpace(text), Name: name, Function: true}, nil
+	case "left":
+		return p.parseFenced(tok)
+	case "middle":
+		delim, err := p.parseDelimiter(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Operator{Text: delim, Name: name, Fence: true, Form: "infix"}, nil
+	case "right":
+		return nil, p.errorAt(tok.pos, CodeUnbalancedDelimiters, name, `\right 缺少对应的 \left`)
+	case "begin":
+		return p.parseEnvironment(tok)
+	case "end":
+		return nil, p.errorAt(tok.pos, CodeMismatchedEnvironment, name, `\end 缺少对应的 \begin`)
+	case "\\", "newline", "cr":
+		return &Space{Newline: true}, nil
+	case "hline":
+		return nil, nil
+	case "not":
+		return p.parseNot(tok)
+	case "overset", "underset", "stackrel":
+		return p.parseStack(tok)
+	case "boxed", "fbox":
+		body, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Enclose{Notation: "box", Body: body}, nil
+	case "phantom":
+		body, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Phantom{Body: body}, nil
+	case "textcolor":
+		color, err := p.readRawArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Style{Body: body, Color: strings.TrimSpace(color)}, nil
+	case "mathop", "mathrel", "mathbin", "mathord", "mathopen", "mathclose", "mathpunct":
+		return p.parseArgument(tok)
+	case "bmod", "mod":
+		return &Operator{Text: "mod", Name: name}, nil
+	case "pmod":
+		body, err := p.parseArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Fenced{Open: "(", Close: ")", Body: &Row{Children: []Node{
+			&Operator{Text: "mod", Name: "bmod"}, &Space{Width: "0.3333em"}, body,
+		}}}, nil
+	}
+
+	return nil, p.errorAt(tok.pos, CodeUnsupportedCommand, name, fmt.Sprintf(`不支持的命令 \%s`, name))
+}
+
+func (p *parser) parseFrac(tok token) (Node, error) {
+	num, err := p.parseArgument(tok)
+	if err != nil {
+		return nil, err
+	}
+	den, err := p.parseArgument(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	frac := &Frac{Num: num, Den: den}
+	switch tok.text {
+	case "dfrac", "cfrac", "dbinom":
+		frac.Style = "display"
+	case "tfrac", "tbinom":
+		frac.Style = "text"
+	}
+	if strings.HasSuffix(tok.text, "binom") {
+		frac.NoLine = true
+		return &Fenced{Open: "(", Close: ")", Body: frac}, nil
+	}
+	return frac, nil
+}
+
+// parseDelimiter 读取 \left、\right、\big 等命令之后的分隔符，"." 表示空分隔符
+func (p *parser) parseDelimiter(owner token) (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokChar:
+		switch tok.text {
+		case ".":
+			return "", nil
+		case "(", ")", "[", "]", "|", "/", "<", ">":
+			if tok.text == "<" {
+				return "⟨", nil
+			}
+			if tok.text == ">" {
+				return "⟩", nil
+			}
+			return tok.text, nil
+		}
+	case tokCommand:
+		if delim, ok := delimiterCommands[tok.text]; ok {
+			return delim, nil
+		}
+	case tokEOF:
+		return "", p.missingArgument(owner)
+	}
+	return "", p.errorAt(tok.pos, CodeInvalidDelimiter, owner.text, fmt.Sprintf(`\%s 之后不是合法的分隔符`, owner.text))
+}
+
+func (p *parser) parseFenced(left token) (Node, error) {
+	open, err := p.parseDelimiter(left)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.parseList(func(t token) bool { return t.is(tokCommand, "right") })
+	if err != nil {
+		return nil, err
+	}
+	right := p.next()
+	if !right.is(tokCommand, "right") {
+		return nil, p.errorAt(left.pos, CodeUnbalancedDelimiters, "left", `\left 缺少对应的 \right`)
+	}
+	closing, err := p.parseDelimiter(right)
+	if err != nil {
+		return nil, err
+	}
+	return &Fenced{Open: open, Close: closing, Body: &Row{Children: body}}, nil
+}
+
+// parseNot 为紧随其后的关系符加上否定斜线
+func (p *parser) parseNot(tok token) (Node, error) {
+	next, err := p.parseArgument(tok)
+	if err != nil {
+		return nil, err
+	}
+	switch n := next.(type) {
+	case *Operator:
+		if n.Text == "=" {
+			return &Operator{Text: "≠", Name: "neq"}, nil
+		}
+		return &Operator{Text: n.Text + "̸", Name: "not" + n.Name}, nil
+	case *Ident:
+		return &Operator{Text: n.Text + "̸", Name: "not" + n.Name}, nil
+	default:
+		return nil, p.errorAt(tok.pos, CodeUnexpectedToken, "not", `\not 之后应为关系符`)
+	}
+}
+
+func (p *parser) parseStack(tok token) (Node, error) {
+	mark, err := p.parseArgument(tok)
+	if err != nil {
+		return nil, err
+	}
+	base, err := p.parseArgument(tok)
+	if err != nil {
+		return nil, err
+	}
+	if tok.text == "underset" {
+		return &UnderOver{Base: base, Under: mark}, nil
+	}
+	return &UnderOver{Base: base, Over: mark}, nil
+}
+
+// parseEnvironment 解析 \begin{env} ... \end{env}，按 & 与 \\ 切分单元格
+func (p *parser) parseEnvironment(begin token) (Node, error) {
+	name, err := p.readRawArgument(begin)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	spec, ok := environments[name]
+	if !ok {
+		return nil, p.errorAt(begin.pos, CodeUnknownEnvironment, name, fmt.Sprintf("不支持的环境 %s", name))
+	}
+
+	table := &Table{Env: name}
+	if spec.align != "" {
+		table.Align = strings.Fields(spec.align)
+	}
+	if name == "array" {
+		columns, err := p.readRawArgument(begin)
+		if err != nil {
+			return nil, err
+		}
+		table.Align = arrayAlignment(columns)
+	}
+
+	stop := func(t token) bool {
+		return t.is(tokChar, "&") || t.is(tokCommand, "\\") || t.is(tokCommand, "end")
+	}
+	var row []Node
+	for {
+		cell, err := p.parseList(stop)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, &Row{Children: cell})
+
+		tok := p.next()
+		switch {
+		case tok.is(tokChar, "&"):
+			continue
+		case tok.is(tokCommand, "\\"):
+			table.Rows = append(table.Rows, row)
+			row = nil
+			// 跳过 \\[2pt] 之类的行距参数
+			if p.peek().is(tokChar, "[") {
+				if _, err := p.readOptional(); err != nil {
+					return nil, err
+				}
+			}
+		case tok.is(tokCommand, "end"):
+			endName, err := p.readRawArgument(tok)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(endName) != name {
+				return nil, p.errorAt(tok.pos, CodeMismatchedEnvironment, endName,
+					fmt.Sprintf(`\begin{%s} 与 \end{%s} 不匹配`, name, strings.TrimSpace(endName)))
+			}
+			// 结尾多余的 \\ 会留下一个空行，这里丢弃
+			if !(len(row) == 1 && len(row[0].(*Row).Children) == 0 && len(table.Rows) > 0) {
+				table.Rows = append(table.Rows, row)
+			}
+			if spec.open == "" && spec.close == "" {
+				return table, nil
+			}
+			return &Fenced{Open: spec.open, Close: spec.close, Body: table}, nil
+		default:
+			return nil, p.errorAt(begin.pos, CodeMismatchedEnvironment, name, fmt.Sprintf(`\begin{%s} 缺少对应的 \end`, name))
+		}
+	}
+}
+
+// arrayAlignment 将 array 的列格式（如 {lcr|c}）转换为 columnalign 取值
+func arrayAlignment(spec string) []string {
+	var align []string
+	for _, r := range spec {
+		switch r {
+		case 'l':
+			align = append(align, "left")
+		case 'c':
+			align = append(align, "center")
+		case 'r':
+			align = append(align, "right")
+		}
+	}
+	return align
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
@@ -0,0 +1,376 @@
+package latex
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 朗读文本支持的语言
+const (
+	LangEnglish = "en"
+	LangChinese = "zh"
+)
+
+// phrase 保存同一个记号的英文与中文读法
+type phrase struct {
+	en string
+	zh string
+}
+
+func (p phrase) in(lang string) string {
+	if lang == LangChinese {
+		return p.zh
+	}
+	return p.en
+}
+
+// spokenSymbols 以输出字符为键，覆盖常见运算符与特殊标识符
+var spokenSymbols = map[string]phrase{
+	"=": {"equals", "等于"}, "+": {"plus", "加"}, "-": {"minus", "减"}, "−": {"minus", "减"},
+	"×": {"times", "乘"}, "⋅": {"times", "乘"}, "*": {"times", "乘"}, "∗": {"times", "乘"},
+	"÷": {"divided by", "除以"}, "/": {"over", "除以"}, "±": {"plus or minus", "正负"}, "∓": {"minus or plus", "负正"},
+	"<": {"is less than", "小于"}, ">": {"is greater than", "大于"},
+	"≤": {"is less than or equal to", "小于等于"}, "≥": {"is greater than or equal to", "大于等于"},
+	"≠": {"is not equal to", "不等于"}, "≈": {"is approximately equal to", "约等于"},
+	"≡": {"is equivalent to", "恒等于"}, "∼": {"is similar to", "相似于"}, "≅": {"is congruent to", "全等于"},
+	"∝": {"is proportional to", "正比于"}, "∈": {"in", "属于"}, "∉": {"not in", "不属于"},
+	"⊂": {"subset of", "真包含于"}, "⊆": {"subset of or equal to", "包含于"}, "⊃": {"superset of", "真包含"},
+	"⊇": {"superset of or equal to", "包含"}, "∪": {"union", "并"}, "∩": {"intersection", "交"},
+	"→": {"to", "趋于"}, "←": {"from", "来自"}, "⇒": {"implies", "推出"}, "⟹": {"implies", "推出"},
+	"⇔": {"if and only if", "当且仅当"}, "⟺": {"if and only if", "当且仅当"}, "↦": {"maps to", "映射到"},
+	"∀": {"for all", "对任意"}, "∃": {"there exists", "存在"}, "¬": {"not", "非"},
+	"∧": {"and", "且"}, "∨": {"or", "或"}, "∘": {"composed with", "复合"}, "!": {"factorial", "的阶乘"},
+	"…": {"dot dot dot", "省略号"}, "⋯": {"dot dot dot", "省略号"}, ",": {"comma", "逗号"},
+	"(": {"open paren", "左括号"}, ")": {"close paren", "右括号"}, "[": {"open bracket", "左方括号"},
+	"]": {"close bracket", "右方括号"}, "{": {"open brace", "左花括号"}, "}": {"close brace", "右花括号"},
+	"|": {"absolute value", "绝对值"}, "‖": {"norm", "范数"}, "⟨": {"open angle", "左尖括号"}, "⟩": {"close angle", "右尖括号"},
+	"′": {"prime", "撇"}, "∞": {"infinity", "无穷大"}, "∂": {"partial", "偏"}, "∇": {"nabla", "梯度"},
+	"∅": {"empty set", "空集"}, "ℏ": {"h bar", "约化普朗克常数"},
+}
+
+// spokenNames 以命令名为键，覆盖希腊字母与函数名
+var spokenNames = map[string]phrase{
+	"alpha": {"alpha", "阿尔法"}, "beta": {"beta", "贝塔"}, "gamma": {"gamma", "伽马"}, "delta": {"delta", "德尔塔"},
+	"epsilon": {"epsilon", "艾普西隆"}, "varepsilon": {"epsilon", "艾普西隆"}, "zeta": {"zeta", "泽塔"},
+	"eta": {"eta", "伊塔"}, "theta": {"theta", "西塔"}, "vartheta": {"theta", "西塔"}, "iota": {"iota", "约塔"},
+	"kappa": {"kappa", "卡帕"}, "lambda": {"lambda", "兰姆达"}, "mu": {"mu", "缪"}, "nu": {"nu", "纽"},
+	"xi": {"xi", "克西"}, "pi": {"pi", "派"}, "rho": {"rho", "柔"}, "sigma": {"sigma", "西格玛"},
+	"tau": {"tau", "陶"}, "upsilon": {"upsilon", "宇普西隆"}, "phi": {"phi", "斐"}, "varphi": {"phi", "斐"},
+	"chi": {"chi", "希"}, "psi": {"psi", "普西"}, "omega": {"omega", "欧米伽"},
+	"Gamma": {"capital gamma", "大写伽马"}, "Delta": {"capital delta", "大写德尔塔"}, "Theta": {"capital theta", "大写西塔"},
+	"Lambda": {"capital lambda", "大写兰姆达"}, "Pi": {"capital pi", "大写派"}, "Sigma": {"capital sigma", "大写西格玛"},
+	"Phi": {"capital phi", "大写斐"}, "Psi": {"capital psi", "大写普西"}, "Omega": {"capital omega", "大写欧米伽"},
+	"sin": {"sine", "正弦"}, "cos": {"cosine", "余弦"}, "tan": {"tangent", "正切"}, "cot": {"cotangent", "余切"},
+	"sec": {"secant", "正割"}, "csc": {"cosecant", "余割"}, "log": {"log", "对数"}, "ln": {"natural log", "自然对数"},
+	"exp": {"exponential", "指数"}, "det": {"determinant", "行列式"}, "max": {"maximum", "最大值"},
+	"min": {"minimum", "最小值"}, "sup": {"supremum", "上确界"}, "inf": {"infimum", "下确界"},
+	"gcd": {"greatest common divisor", "最大公约数"},
+}
+
+// spokenAccents 描述修饰记号的读法，均读在底数之后；vec 在 speaker 中单独处理为前缀
+var spokenAccents = map[string]phrase{
+	"hat": {"hat", "帽"}, "widehat": {"hat", "帽"}, "bar": {"bar", "拔"}, "overline": {"bar", "拔"},
+	"underline": {"underlined", "下划线"}, "dot": {"dot", "点"}, "ddot": {"double dot", "双点"},
+	"tilde": {"tilde", "波浪"}, "widetilde": {"tilde", "波浪"}, "check": {"check", "反帽"},
+	"overbrace": {"with overbrace", "上括"}, "underbrace": {"with underbrace", "下括"},
+}
+
+// Speak 为公式生成朗读文本，例如 E=mc^2 读作 "E equals m c squared"
+func Speak(tex string, lang string) (string, error) {
+	root, err := Parse(tex)
+	if err != nil {
+		return "", err
+	}
+	if lang != LangChinese {
+		lang = LangEnglish
+	}
+	s := &speaker{lang: lang}
+	s.node(root)
+	return strings.Join(s.words, " "), nil
+}
+
+type speaker struct {
+	lang  string
+	words []string
+}
+
+func (s *speaker) say(en, zh string) {
+	if s.lang == LangChinese {
+		s.words = append(s.words, zh)
+		return
+	}
+	s.words = append(s.words, en)
+}
+
+func (s *speaker) node(n Node) {
+	switch n := n.(type) {
+	case *Row:
+		for _, child := range n.Children {
+			s.node(child)
+		}
+	case *Ident:
+		s.ident(n)
+	case *Number:
+		s.words = append(s.words, n.Text)
+	case *Text:
+		if text := strings.TrimSpace(n.Text); text != "" {
+			s.words = append(s.words, text)
+		}
+	case *Operator:
+		s.operator(n)
+	case *Frac:
+		s.frac(n)
+	case *Sqrt:
+		s.sqrt(n)
+	case *Scripts:
+		s.scripts(n)
+	case *Accent:
+		if n.Name == "vec" || n.Name == "overrightarrow" {
+			s.say("vector", "向量")
+			s.node(n.Base)
+			return
+		}
+		s.node(n.Base)
+		if p, ok := spokenAccents[n.Name]; ok {
+			s.words = append(s.words, p.in(s.lang))
+		}
+	case *UnderOver:
+		s.node(n.Base)
+		if n.Under != nil {
+			s.say("under", "下方")
+			s.node(n.Under)
+		}
+		if n.Over != nil {
+			s.say("over", "上方")
+			s.node(n.Over)
+		}
+	case *Fenced:
+		s.fenced(n)
+	case *Style:
+		s.node(n.Body)
+	case *Table:
+		s.table(n)
+	case *Enclose:
+		s.node(n.Body)
+	}
+}
+
+func (s *speaker) ident(n *Ident) {
+	if p, ok := spokenNames[n.Name]; ok {
+		s.words = append(s.words, p.in(s.lang))
+		return
+	}
+	if p, ok := spokenSymbols[n.Text]; ok {
+		s.words = append(s.words, p.in(s.lang))
+		return
+	}
+	s.words = append(s.words, n.Text)
+}
+
+func (s *speaker) operator(n *Operator) {
+	if p, ok := spokenNames[n.Name]; ok {
+		s.words = append(s.words, p.in(s.lang))
+		return
+	}
+	if p, ok := spokenSymbols[n.Text]; ok {
+		s.words = append(s.words, p.in(s.lang))
+		return
+	}
+	switch n.Name {
+	case "lim":
+		s.say("limit", "极限")
+	case "sum":
+		s.say("sum", "求和")
+	case "prod":
+		s.say("product", "求积")
+	case "int", "iint", "iiint", "oint":
+		s.say("integral", "积分")
+	default:
+		if n.Text != "" {
+			s.words = append(s.words, n.Text)
+		}
+	}
+}
+
+func (s *speaker) frac(n *Frac) {
+	if n.NoLine {
+		s.node(n.Num)
+		s.say("choose", "选")
+		s.node(n.Den)
+		return
+	}
+	if s.lang == LangChinese {
+		s.node(n.Den)
+		s.words = append(s.words, "分之")
+		s.node(n.Num)
+		return
+	}
+	s.words = append(s.words, "fraction")
+	s.node(n.Num)
+	s.words = append(s.words, "over")
+	s.node(n.Den)
+	s.words = append(s.words, "end fraction")
+}
+
+func (s *speaker) sqrt(n *Sqrt) {
+	if n.Index == nil {
+		if s.lang == LangChinese {
+			s.node(n.Radicand)
+			s.words = append(s.words, "的平方根")
+			return
+		}
+		s.words = append(s.words, "square root of")
+		s.node(n.Radicand)
+		s.words = append(s.words, "end root")
+		return
+	}
+
+	index := spokenOrdinal(n.Index, s.lang)
+	if s.lang == LangChinese {
+		s.node(n.Radicand)
+		s.words = append(s.words, "的", index, "次方根")
+		return
+	}
+	s.words = append(s.words, index, "root of")
+	s.node(n.Radicand)
+	s.words = append(s.words, "end root")
+}
+
+func (s *speaker) scripts(n *Scripts) {
+	// 求和、积分、极限等带上下限的运算符读作“从…到…”
+	if op, ok := n.Base.(*Operator); ok && (op.LargeOp || op.Function) {
+		s.limits(op, n.Sub, n.Sup)
+		return
+	}
+
+	s.node(n.Base)
+	if n.Sub != nil {
+		s.say("sub", "下标")
+		s.node(n.Sub)
+	}
+	if n.Sup == nil {
+		return
+	}
+	if op, ok := n.Sup.(*Operator); ok && op.Name == "prime" {
+		s.node(op)
+		return
+	}
+	if num, ok := n.Sup.(*Number); ok {
+		switch num.Text {
+		case "2":
+			s.say("squared", "的平方")
+			return
+		case "3":
+			s.say("cubed", "的立方")
+			return
+		}
+	}
+	if s.lang == LangChinese {
+		s.words = append(s.words, "的")
+		s.node(n.Sup)
+		s.words = append(s.words, "次方")
+		return
+	}
+	s.words = append(s.words, "to the power of")
+	s.node(n.Sup)
+}
+
+func (s *speaker) limits(op *Operator, lower, upper Node) {
+	if op.Name == "lim" {
+		if s.lang == LangChinese {
+			s.words = append(s.words, "当")
+			s.node(lower)
+			s.words = append(s.words, "时的极限")
+			return
+		}
+		s.words = append(s.words, "the limit as")
+		s.node(lower)
+		s.words = append(s.words, "of")
+		return
+	}
+
+	if s.lang == LangChinese {
+		if lower != nil {
+			s.words = append(s.words, "从")
+			s.node(lower)
+		}
+		if upper != nil {
+			s.words = append(s.words, "到")
+			s.node(upper)
+			s.words = append(s.words, "的")
+		}
+		s.operator(op)
+		return
+	}
+	s.operator(op)
+	if lower != nil {
+		s.words = append(s.words, "from")
+		s.node(lower)
+	}
+	if upper != nil {
+		s.words = append(s.words, "to")
+		s.node(upper)
+	}
+	s.words = append(s.words, "of")
+}
+
+func (s *speaker) fenced(n *Fenced) {
+	if table, ok := n.Body.(*Table); ok {
+		s.table(table)
+		return
+	}
+	if n.Open != "" {
+		s.operator(&Operator{Text: n.Open})
+	}
+	s.node(n.Body)
+	if n.Close != "" {
+		s.operator(&Operator{Text: n.Close})
+	}
+}
+
+func (s *speaker) table(n *Table) {
+	rows := strconv.Itoa(len(n.Rows))
+	if n.Env == "cases" {
+		s.say("cases", "分段")
+	} else {
+		s.say(rows+" row matrix", rows+" 行矩阵")
+	}
+	for i, row := range n.Rows {
+		s.say("row "+strconv.Itoa(i+1), "第 "+strconv.Itoa(i+1)+" 行")
+		for j, cell := range row {
+			if j > 0 {
+				s.say("and", "和")
+			}
+			s.node(cell)
+		}
+	}
+	s.say("end", "结束")
+}
+
+// spokenOrdinal 将根指数读作序数，例如 3 读作 cube、n 读作 n-th
+func spokenOrdinal(index Node, lang string) string {
+	if row, ok := index.(*Row); ok && len(row.Children) == 1 {
+		index = row.Children[0]
+	}
+	text := ""
+	switch v := index.(type) {
+	case *Number:
+		text = v.Text
+	case *Ident:
+		text = v.Text
+	}
+	if lang == LangChinese {
+		if text == "" {
+			return "n"
+		}
+		return text
+	}
+	switch text {
+	case "3":
+		return "cube"
+	case "":
+		return "n-th"
+	default:
+		return text + "-th"
+	}
+}
@@ -0,0 +1,29 @@
+package latex
+
+import "testing"
+
+func TestSpeak(t *testing.T) {
+	cases := []struct {
+		tex  string
+		lang string
+		want string
+	}{
+		{`E=mc^2`, LangEnglish, "E equals m c squared"},
+		{`E=mc^2`, LangChinese, "E 等于 m c 的平方"},
+		{`\frac{a}{b}`, LangEnglish, "fraction a over b end fraction"},
+		{`\frac{a}{b}`, LangChinese, "b 分之 a"},
+		{`\sqrt{x}`, LangChinese, "x 的平方根"},
+		{`\sum_{i=1}^{n} i`, LangEnglish, "sum from i equals 1 to n of i"},
+		{`\sin\alpha`, LangChinese, "正弦 阿尔法"},
+		{`x^{n}`, LangEnglish, "x to the power of n"},
+	}
+	for _, tc := range cases {
+		got, err := Speak(tc.tex, tc.lang)
+		if err != nil {
+			t.Fatalf("%s 朗读失败: %v", tc.tex, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s (%s) 期望 %q，实际 %q", tc.tex, tc.lang, tc.want, got)
+		}
+	}
+}
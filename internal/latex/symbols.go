@@ -0,0 +1,192 @@
+package latex
+
+// symbolKind 区分命令映射为哪一类 MathML 记号
+type symbolKind int
+
+const (
+	symIdent    symbolKind = iota // mi，例如希腊字母
+	symOperator                   // mo，关系符与二元运算符
+	symLarge                      // 大型运算符，默认上下限可移动
+	symIntegral                   // 积分类大型运算符，上下限默认在右侧
+)
+
+type symbol struct {
+	text string
+	kind symbolKind
+}
+
+// symbols 收录可直接映射为单个字符的命令
+var symbols = map[string]symbol{
+	// 小写希腊字母
+	"alpha": {"α", symIdent}, "beta": {"β", symIdent}, "gamma": {"γ", symIdent}, "delta": {"δ", symIdent},
+	"epsilon": {"ϵ", symIdent}, "varepsilon": {"ε", symIdent}, "zeta": {"ζ", symIdent}, "eta": {"η", symIdent},
+	"theta": {"θ", symIdent}, "vartheta": {"ϑ", symIdent}, "iota": {"ι", symIdent}, "kappa": {"κ", symIdent},
+	"lambda": {"λ", symIdent}, "mu": {"μ", symIdent}, "nu": {"ν", symIdent}, "xi": {"ξ", symIdent},
+	"omicron": {"ο", symIdent}, "pi": {"π", symIdent}, "varpi": {"ϖ", symIdent}, "rho": {"ρ", symIdent},
+	"varrho": {"ϱ", symIdent}, "sigma": {"σ", symIdent}, "varsigma": {"ς", symIdent}, "tau": {"τ", symIdent},
+	"upsilon": {"υ", symIdent}, "phi": {"ϕ", symIdent}, "varphi": {"φ", symIdent}, "chi": {"χ", symIdent},
+	"psi": {"ψ", symIdent}, "omega": {"ω", symIdent},
+
+	// 大写希腊字母
+	"Gamma": {"Γ", symIdent}, "Delta": {"Δ", symIdent}, "Theta": {"Θ", symIdent}, "Lambda": {"Λ", symIdent},
+	"Xi": {"Ξ", symIdent}, "Pi": {"Π", symIdent}, "Sigma": {"Σ", symIdent}, "Upsilon": {"Υ", symIdent},
+	"Phi": {"Φ", symIdent}, "Psi": {"Ψ", symIdent}, "Omega": {"Ω", symIdent},
+
+	// 其他标识符
+	"infty": {"∞", symIdent}, "partial": {"∂", symIdent}, "nabla": {"∇", symIdent}, "hbar": {"ℏ", symIdent},
+	"ell": {"ℓ", symIdent}, "Re": {"ℜ", symIdent}, "Im": {"ℑ", symIdent}, "aleph": {"ℵ", symIdent},
+	"emptyset": {"∅", symIdent}, "varnothing": {"∅", symIdent}, "imath": {"ı", symIdent}, "jmath": {"ȷ", symIdent},
+	"wp": {"℘", symIdent}, "top": {"⊤", symIdent}, "bot": {"⊥", symIdent}, "angle": {"∠", symIdent},
+	"triangle": {"△", symIdent}, "prime": {"′", symIdent},
+
+	// 二元运算符
+	"times": {"×", symOperator}, "cdot": {"⋅", symOperator}, "pm": {"±", symOperator}, "mp": {"∓", symOperator},
+	"div": {"÷", symOperator}, "ast": {"∗", symOperator}, "star": {"⋆", symOperator}, "circ": {"∘", symOperator},
+	"bullet": {"∙", symOperator}, "cup": {"∪", symOperator}, "cap": {"∩", symOperator}, "setminus": {"∖", symOperator},
+	"wedge": {"∧", symOperator}, "land": {"∧", symOperator}, "vee": {"∨", symOperator}, "lor": {"∨", symOperator},
+	"oplus": {"⊕", symOperator}, "ominus": {"⊖", symOperator}, "otimes": {"⊗", symOperator}, "odot": {"⊙", symOperator},
+	"neg": {"¬", symOperator}, "lnot": {"¬", symOperator},
+
+	// 关系符
+	"leq": {"≤", symOperator}, "le": {"≤", symOperator}, "geq": {"≥", symOperator}, "ge": {"≥", symOperator},
+	"neq": {"≠", symOperator}, "ne": {"≠", symOperator}, "approx": {"≈", symOperator}, "equiv": {"≡", symOperator},
+	"sim": {"∼", symOperator}, "simeq": {"≃", symOperator}, "cong": {"≅", symOperator}, "propto": {"∝", symOperator},
+	"ll": {"≪", symOperator}, "gg": {"≫", symOperator}, "prec": {"≺", symOperator}, "succ": {"≻", symOperator},
+	"in": {"∈", symOperator}, "notin": {"∉", symOperator}, "ni": {"∋", symOperator}, "subset": {"⊂", symOperator},
+	"supset": {"⊃", symOperator}, "subseteq": {"⊆", symOperator}, "supseteq": {"⊇", symOperator},
+	"perp": {"⊥", symOperator}, "parallel": {"∥", symOperator}, "mid": {"∣", symOperator},
+	"models": {"⊨", symOperator}, "vdash": {"⊢", symOperator}, "therefore": {"∴", symOperator},
+	"because": {"∵", symOperator}, "forall": {"∀", symOperator}, "exists": {"∃", symOperator},
+	"nexists": {"∄", symOperator}, "colon": {":", symOperator},
+
+	// 箭头
+	"to": {"→", symOperator}, "rightarrow": {"→", symOperator}, "leftarrow": {"←", symOperator},
+	"gets": {"←", symOperator}, "leftrightarrow": {"↔", symOperator}, "Rightarrow": {"⇒", symOperator},
+	"Leftarrow": {"⇐", symOperator}, "Leftrightarrow": {"⇔", symOperator}, "implies": {"⟹", symOperator},
+	"iff": {"⟺", symOperator}, "mapsto": {"↦", symOperator}, "uparrow": {"↑", symOperator},
+	"downarrow": {"↓", symOperator}, "longrightarrow": {"⟶", symOperator}, "longleftarrow": {"⟵", symOperator},
+
+	// 省略号与分隔符
+	"ldots": {"…", symOperator}, "dots": {"…", symOperator}, "cdots": {"⋯", symOperator},
+	"vdots": {"⋮", symOperator}, "ddots": {"⋱", symOperator}, "langle": {"⟨", symOperator},
+	"rangle": {"⟩", symOperator}, "lfloor": {"⌊", symOperator}, "rfloor": {"⌋", symOperator},
+	"lceil": {"⌈", symOperator}, "rceil": {"⌉", symOperator}, "vert": {"|", symOperator},
+	"Vert": {"‖", symOperator}, "|": {"‖", symOperator}, "lvert": {"|", symOperator}, "rvert": {"|", symOperator},
+	"lVert": {"‖", symOperator}, "rVert": {"‖", symOperator}, "backslash": {"∖", symOperator},
+	"{": {"{", symOperator}, "}": {"}", symOperator}, "lbrace": {"{", symOperator}, "rbrace": {"}", symOperator},
+
+	// 转义字符
+	"%": {"%", symOperator}, "$": {"$", symOperator}, "&": {"&", symOperator}, "#": {"#", symOperator},
+	"_": {"_", symOperator},
+
+	// 大型运算符
+	"sum": {"∑", symLarge}, "prod": {"∏", symLarge}, "coprod": {"∐", symLarge}, "bigcup": {"⋃", symLarge},
+	"bigcap": {"⋂", symLarge}, "bigoplus": {"⨁", symLarge}, "bigotimes": {"⨂", symLarge},
+	"bigvee": {"⋁", symLarge}, "bigwedge": {"⋀", symLarge},
+	"int": {"∫", symIntegral}, "iint": {"∬", symIntegral}, "iiint": {"∭", symIntegral}, "oint": {"∮", symIntegral},
+}
+
+// functions 是以正体输出并带函数应用符的函数名
+var functions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"sinh": true, "cosh": true, "tanh": true, "coth": true,
+	"arcsin": true, "arccos": true, "arctan": true,
+	"log": true, "ln": true, "lg": true, "exp": true,
+	"det": true, "dim": true, "ker": true, "deg": true, "gcd": true, "arg": true, "hom": true, "Pr": true,
+}
+
+// limitFunctions 是上下限位于正下方的函数型运算符
+var limitFunctions = map[string]string{
+	"lim": "lim", "limsup": "lim sup", "liminf": "lim inf",
+	"max": "max", "min": "min", "sup": "sup", "inf": "inf",
+}
+
+// fontVariants 是数学字体命令与 mathvariant 的对应关系
+var fontVariants = map[string]string{
+	"mathrm": "normal", "mathbf": "bold", "mathit": "italic", "mathbb": "double-struck",
+	"mathcal": "script", "mathscr": "script", "mathfrak": "fraktur", "mathsf": "sans-serif",
+	"mathtt": "monospace", "boldsymbol": "bold-italic", "bm": "bold-italic",
+}
+
+// textVariants 是文本命令与 mathvariant 的对应关系
+var textVariants = map[string]string{
+	"text": "", "textrm": "", "textnormal": "", "mbox": "", "hbox": "",
+	"textbf": "bold", "textit": "italic", "texttt": "monospace", "textsf": "sans-serif",
+}
+
+type accentSpec struct {
+	mark     string
+	under    bool
+	stretchy bool
+	limits   bool
+}
+
+// accents 是附加在底数上方或下方的记号
+var accents = map[string]accentSpec{
+	"hat": {"^", false, false, false}, "widehat": {"^", false, true, false},
+	"bar": {"¯", false, false, false}, "overline": {"¯", false, true, false},
+	"underline": {"_", true, true, false}, "vec": {"→", false, false, false},
+	"overrightarrow": {"→", false, true, false}, "overleftarrow": {"←", false, true, false},
+	"dot": {"˙", false, false, false}, "ddot": {"¨", false, false, false},
+	"tilde": {"~", false, false, false}, "widetilde": {"~", false, true, false},
+	"check": {"ˇ", false, false, false}, "breve": {"˘", false, false, false},
+	"acute": {"´", false, false, false}, "grave": {"`", false, false, false},
+	"overbrace": {"⏞", false, true, true}, "underbrace": {"⏟", true, true, true},
+}
+
+// spaces 是显式间距命令对应的宽度
+var spaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", "!": "-0.1667em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em", "thinspace": "0.1667em",
+	"medspace": "0.2222em", "thickspace": "0.2778em", "enspace": "0.5em",
+}
+
+// bigSizes 是 \big 系列命令对应的分隔符尺寸
+var bigSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "bigm": "1.2em",
+	"Big": "1.623em", "Bigl": "1.623em", "Bigr": "1.623em", "Bigm": "1.623em",
+	"bigg": "2.047em", "biggl": "2.047em", "biggr": "2.047em", "biggm": "2.047em",
+	"Bigg": "2.470em", "Biggl": "2.470em", "Biggr": "2.470em", "Biggm": "2.470em",
+}
+
+// delimiterCommands 是可以出现在 \left、\right 之后的命令形式分隔符
+var delimiterCommands = map[string]string{
+	"{": "{", "}": "}", "lbrace": "{", "rbrace": "}", "langle": "⟨", "rangle": "⟩",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉", "vert": "|", "Vert": "‖",
+	"|": "‖", "lvert": "|", "rvert": "|", "lVert": "‖", "rVert": "‖",
+	"uparrow": "↑", "downarrow": "↓", "backslash": "∖",
+}
+
+// styleSwitches 是作用于当前分组剩余内容的显示样式命令
+var styleSwitches = map[string][2]string{
+	"displaystyle":      {"true", "0"},
+	"textstyle":         {"false", "0"},
+	"scriptstyle":       {"false", "1"},
+	"scriptscriptstyle": {"false", "2"},
+}
+
+type environmentSpec struct {
+	open  string
+	close string
+	align string // 列对齐方式循环使用，空表示居中
+}
+
+// environments 是支持的矩阵与对齐环境
+var environments = map[string]environmentSpec{
+	"matrix":      {},
+	"smallmatrix": {},
+	"pmatrix":     {open: "(", close: ")"},
+	"bmatrix":     {open: "[", close: "]"},
+	"Bmatrix":     {open: "{", close: "}"},
+	"vmatrix":     {open: "|", close: "|"},
+	"Vmatrix":     {open: "‖", close: "‖"},
+	"cases":       {open: "{", align: "left"},
+	"array":       {},
+	"aligned":     {align: "right left"},
+	"align":       {align: "right left"},
+	"align*":      {align: "right left"},
+	"split":       {align: "right left"},
+	"gathered":    {},
+	"gather":      {},
+	"gather*":     {},
+}
@@ -0,0 +1,721 @@
+// Package redistest 提供进程内的 Redis 协议替身，供测试使用。
+// 只实现服务与 go-redis 客户端实际用到的命令，并可扮演 Sentinel 或单节点 Cluster
+package redistest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScriptFunc 模拟一段 Lua 脚本，返回值按 RESP 规则编码（nil、int64、string、[]byte）
+type ScriptFunc func(s *Server, keys, args []string) any
+
+type item struct {
+	value   []byte
+	expires time.Time
+}
+
+// Server 是监听在本机端口上的 Redis 替身
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	data     map[string]item
+	username string
+	password string
+	// sentinelName 非空时响应 SENTINEL 命令，报告 sentinelAddr 为主节点地址
+	sentinelName string
+	sentinelAddr string
+	cluster      bool
+	scripts      map[string]ScriptFunc
+	subs         map[string]map[*conn]struct{}
+	conns        map[*conn]struct{}
+	commands     map[string]int
+
+	wg     sync.WaitGroup
+	closed bool
+}
+
+type conn struct {
+	net.Conn
+	writeMu sync.Mutex
+	w       *bufio.Writer
+	authed  bool
+	subs    map[string]struct{}
+}
+
+// Start 在 addr 上启动替身，addr 为空时使用随机端口
+func Start(addr string) (*Server, error) {
+	return StartTLS(addr, nil)
+}
+
+// StartTLS 与 Start 相同，tlsConfig 非空时只接受 TLS 连接
+func StartTLS(addr string, tlsConfig *tls.Config) (*Server, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	s := &Server{
+		ln:       ln,
+		data:     make(map[string]item),
+		scripts:  make(map[string]ScriptFunc),
+		subs:     make(map[string]map[*conn]struct{}),
+		conns:    make(map[*conn]struct{}),
+		commands: make(map[string]int),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 返回监听地址
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// RequireAuth 要求客户端先认证，username 为空时等同 default 用户
+func (s *Server) RequireAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if username == "" {
+		username = "default"
+	}
+	s.username, s.password = username, password
+}
+
+// ServeSentinel 让替身同时扮演 Sentinel，报告 name 对应的主节点地址
+func (s *Server) ServeSentinel(name, masterAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sentinelName, s.sentinelAddr = name, masterAddr
+}
+
+// ServeCluster 让替身以单节点 Cluster 的身份响应 CLUSTER SLOTS，负责全部槽位
+func (s *Server) ServeCluster() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cluster = true
+}
+
+// HandleScript 注册脚本的模拟实现，EVAL 与 EVALSHA 按脚本 SHA1 查找
+func (s *Server) HandleScript(src string, fn ScriptFunc) {
+	sum := sha1.Sum([]byte(src))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[hex.EncodeToString(sum[:])] = fn
+}
+
+// Get 直接读取数据，供测试断言
+func (s *Server) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(key)
+	return it.value, ok
+}
+
+// Set 直接写入数据，ttl 为 0 表示不过期；供脚本模拟与测试预置数据
+func (s *Server) Set(key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value, ttl)
+}
+
+// Del 直接删除数据，返回键是否存在
+func (s *Server) Del(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.lookup(key)
+	delete(s.data, key)
+	return ok
+}
+
+// TTL 返回键的剩余有效期，不存在或不过期时返回 0
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(key)
+	if !ok || it.expires.IsZero() {
+		return 0
+	}
+	return time.Until(it.expires)
+}
+
+// Len 返回当前键数量
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data)
+}
+
+// Commands 返回某个命令（小写）被调用的次数
+func (s *Server) Commands(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[name]
+}
+
+// Publish 向订阅者推送消息，返回收到消息的连接数
+func (s *Server) Publish(channel, message string) int {
+	s.mu.Lock()
+	receivers := make([]*conn, 0, len(s.subs[channel]))
+	for c := range s.subs[channel] {
+		receivers = append(receivers, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range receivers {
+		c.writeMu.Lock()
+		writeArrayHeader(c.w, 3)
+		writeBulk(c.w, []byte("message"))
+		writeBulk(c.w, []byte(channel))
+		writeBulk(c.w, []byte(message))
+		_ = c.w.Flush()
+		c.writeMu.Unlock()
+	}
+	return len(receivers)
+}
+
+// Close 停止监听并断开所有连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.ln.Close()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc, w: bufio.NewWriter(nc), subs: make(map[string]struct{})}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = nc.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		for channel := range c.subs {
+			delete(s.subs[channel], c)
+		}
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		c.writeMu.Lock()
+		quit := s.dispatch(c, args)
+		err = c.w.Flush()
+		c.writeMu.Unlock()
+		if quit || err != nil {
+			return
+		}
+	}
+}
+
+// dispatch 执行单条命令并写入响应，返回 true 表示关闭连接
+func (s *Server) dispatch(c *conn, args []string) bool {
+	name := strings.ToLower(args[0])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[name]++
+	w := c.w
+
+	switch name {
+	case "hello":
+		// 不支持 RESP3，go-redis 会退回 RESP2 并单独发送 AUTH
+		writeError(w, "ERR unknown command 'HELLO'")
+		return false
+	case "auth":
+		user, pass := "default", ""
+		switch len(args) {
+		case 2:
+			pass = args[1]
+		case 3:
+			user, pass = args[1], args[2]
+		default:
+			writeError(w, "ERR wrong number of arguments for 'auth' command")
+			return false
+		}
+		if s.password == "" || user != s.username || pass != s.password {
+			writeError(w, "WRONGPASS invalid username-password pair or user is disabled.")
+			return false
+		}
+		c.authed = true
+		writeSimple(w, "OK")
+		return false
+	case "quit":
+		writeSimple(w, "OK")
+		return true
+	}
+
+	if s.password != "" && !c.authed {
+		writeError(w, "NOAUTH Authentication required.")
+		return false
+	}
+
+	if len(c.subs) > 0 {
+		switch name {
+		case "subscribe", "unsubscribe", "ping":
+		default:
+			writeError(w, "ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+			return false
+		}
+	}
+
+	switch name {
+	case "ping":
+		if len(c.subs) > 0 {
+			writeArrayHeader(w, 2)
+			writeBulk(w, []byte("pong"))
+			writeBulk(w, []byte(""))
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "client", "select", "readonly":
+		writeSimple(w, "OK")
+	case "command":
+		writeArrayHeader(w, 0)
+	case "get":
+		if !arity(w, args, 2) {
+			return false
+		}
+		if it, ok := s.lookup(args[1]); ok {
+			writeBulk(w, it.value)
+		} else {
+			writeBulk(w, nil)
+		}
+	case "set":
+		s.cmdSet(w, args)
+	case "del", "unlink":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.lookup(key); ok {
+				delete(s.data, key)
+				n++
+			}
+		}
+		writeInt(w, int64(n))
+	case "exists":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.lookup(key); ok {
+				n++
+			}
+		}
+		writeInt(w, int64(n))
+	case "pexpire", "expire":
+		if !arity(w, args, 3) {
+			return false
+		}
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return false
+		}
+		unit := time.Millisecond
+		if name == "expire" {
+			unit = time.Second
+		}
+		it, ok := s.lookup(args[1])
+		if !ok {
+			writeInt(w, 0)
+			return false
+		}
+		it.expires = time.Now().Add(time.Duration(n) * unit)
+		s.data[args[1]] = it
+		writeInt(w, 1)
+	case "incr":
+		if !arity(w, args, 2) {
+			return false
+		}
+		it, _ := s.lookup(args[1])
+		n := int64(0)
+		if it.value != nil {
+			var err error
+			if n, err = strconv.ParseInt(string(it.value), 10, 64); err != nil {
+				writeError(w, "ERR value is not an integer or out of range")
+				return false
+			}
+		}
+		n++
+		it.value = []byte(strconv.FormatInt(n, 10))
+		s.data[args[1]] = it
+		writeInt(w, n)
+	case "dbsize":
+		s.expireAll()
+		writeInt(w, int64(len(s.data)))
+	case "flushall", "flushdb":
+		s.data = make(map[string]item)
+		writeSimple(w, "OK")
+	case "scan":
+		s.cmdScan(w, args)
+	case "eval", "evalsha":
+		s.cmdEval(w, name, args)
+	case "publish":
+		if !arity(w, args, 3) {
+			return false
+		}
+		// Publish 需要自行加锁，这里先释放
+		s.mu.Unlock()
+		n := s.Publish(args[1], args[2])
+		s.mu.Lock()
+		writeInt(w, int64(n))
+	case "subscribe":
+		for _, channel := range args[1:] {
+			if s.subs[channel] == nil {
+				s.subs[channel] = make(map[*conn]struct{})
+			}
+			s.subs[channel][c] = struct{}{}
+			c.subs[channel] = struct{}{}
+			writeArrayHeader(w, 3)
+			writeBulk(w, []byte("subscribe"))
+			writeBulk(w, []byte(channel))
+			writeInt(w, int64(len(c.subs)))
+		}
+	case "unsubscribe":
+		channels := args[1:]
+		if len(channels) == 0 {
+			for channel := range c.subs {
+				channels = append(channels, channel)
+			}
+		}
+		for _, channel := range channels {
+			delete(s.subs[channel], c)
+			delete(c.subs, channel)
+			writeArrayHeader(w, 3)
+			writeBulk(w, []byte("unsubscribe"))
+			writeBulk(w, []byte(channel))
+			writeInt(w, int64(len(c.subs)))
+		}
+	case "sentinel":
+		s.cmdSentinel(w, args)
+	case "cluster":
+		s.cmdCluster(w, args)
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+func (s *Server) cmdSet(w *bufio.Writer, args []string) {
+	if len(args) < 3 {
+		writeError(w, "ERR wrong number of arguments for 'set' command")
+		return
+	}
+	var ttl time.Duration
+	nx := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Millisecond
+			if strings.EqualFold(args[i], "ex") {
+				unit = time.Second
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		case "keepttl":
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+	if _, exists := s.lookup(args[1]); nx && exists {
+		writeBulk(w, nil)
+		return
+	}
+	s.set(args[1], []byte(args[2]), ttl)
+	writeSimple(w, "OK")
+}
+
+// cmdScan 一次返回全部匹配的键，游标总是回到 0
+func (s *Server) cmdScan(w *bufio.Writer, args []string) {
+	pattern := "*"
+	for i := 2; i+1 < len(args); i += 2 {
+		if strings.EqualFold(args[i], "match") {
+			pattern = args[i+1]
+		}
+	}
+	s.expireAll()
+	var keys []string
+	for key := range s.data {
+		if matchGlob(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	writeArrayHeader(w, 2)
+	writeBulk(w, []byte("0"))
+	writeArrayHeader(w, len(keys))
+	for _, key := range keys {
+		writeBulk(w, []byte(key))
+	}
+}
+
+func (s *Server) cmdEval(w *bufio.Writer, name string, args []string) {
+	if len(args) < 3 {
+		writeError(w, "ERR wrong number of arguments")
+		return
+	}
+	sha := strings.ToLower(args[1])
+	if name == "eval" {
+		sum := sha1.Sum([]byte(args[1]))
+		sha = hex.EncodeToString(sum[:])
+	}
+	fn, ok := s.scripts[sha]
+	if !ok {
+		if name == "evalsha" {
+			writeError(w, "NOSCRIPT No matching script. Please use EVAL.")
+		} else {
+			writeError(w, "ERR script not supported by redistest")
+		}
+		return
+	}
+	n, err := strconv.Atoi(args[2])
+	if err != nil || n < 0 || 3+n > len(args) {
+		writeError(w, "ERR Number of keys can't be greater than number of args")
+		return
+	}
+	// 脚本通过 Server 的导出方法读写数据，执行期间释放锁
+	s.mu.Unlock()
+	result := fn(s, args[3:3+n], args[3+n:])
+	s.mu.Lock()
+	writeValue(w, result)
+}
+
+func (s *Server) cmdSentinel(w *bufio.Writer, args []string) {
+	if s.sentinelName == "" || len(args) < 2 {
+		writeError(w, "ERR unknown command 'SENTINEL'")
+		return
+	}
+	switch strings.ToLower(args[1]) {
+	case "get-master-addr-by-name":
+		if len(args) < 3 || args[2] != s.sentinelName {
+			writeNilArray(w)
+			return
+		}
+		host, port, _ := net.SplitHostPort(s.sentinelAddr)
+		writeArrayHeader(w, 2)
+		writeBulk(w, []byte(host))
+		writeBulk(w, []byte(port))
+	case "sentinels", "replicas", "slaves":
+		writeArrayHeader(w, 0)
+	default:
+		writeError(w, "ERR unknown sentinel subcommand")
+	}
+}
+
+func (s *Server) cmdCluster(w *bufio.Writer, args []string) {
+	if !s.cluster || len(args) < 2 || !strings.EqualFold(args[1], "slots") {
+		writeError(w, "ERR This instance has cluster support disabled")
+		return
+	}
+	host, port, _ := net.SplitHostPort(s.Addr())
+	portNum, _ := strconv.Atoi(port)
+	writeArrayHeader(w, 1)
+	writeArrayHeader(w, 3)
+	writeInt(w, 0)
+	writeInt(w, 16383)
+	writeArrayHeader(w, 3)
+	writeBulk(w, []byte(host))
+	writeInt(w, int64(portNum))
+	writeBulk(w, []byte("redistest"))
+}
+
+// lookup 读取未过期的键，调用方需持有 s.mu
+func (s *Server) lookup(key string) (item, bool) {
+	it, ok := s.data[key]
+	if !ok {
+		return item{}, false
+	}
+	if !it.expires.IsZero() && time.Now().After(it.expires) {
+		delete(s.data, key)
+		return item{}, false
+	}
+	return it, true
+}
+
+func (s *Server) set(key string, value []byte, ttl time.Duration) {
+	it := item{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		it.expires = time.Now().Add(ttl)
+	}
+	s.data[key] = it
+}
+
+func (s *Server) expireAll() {
+	for key := range s.data {
+		s.lookup(key)
+	}
+}
+
+func arity(w *bufio.Writer, args []string, n int) bool {
+	if len(args) != n {
+		writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0])))
+		return false
+	}
+	return true
+}
+
+// matchGlob 支持 Redis 模式中的 * 与 ?，足够覆盖按前缀扫描的场景
+func matchGlob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchGlob(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// readCommand 读取一条以 RESP 数组编码的命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errors.New("redistest: 期望批量字符串")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeSimple(w *bufio.Writer, s string) { fmt.Fprintf(w, "+%s\r\n", s) }
+
+func writeError(w *bufio.Writer, s string) { fmt.Fprintf(w, "-%s\r\n", s) }
+
+func writeInt(w *bufio.Writer, n int64) { fmt.Fprintf(w, ":%d\r\n", n) }
+
+func writeArrayHeader(w *bufio.Writer, n int) { fmt.Fprintf(w, "*%d\r\n", n) }
+
+func writeNilArray(w *bufio.Writer) { _, _ = w.WriteString("*-1\r\n") }
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		_, _ = w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	_, _ = w.Write(b)
+	_, _ = w.WriteString("\r\n")
+}
+
+func writeValue(w *bufio.Writer, v any) {
+	switch v := v.(type) {
+	case nil:
+		writeBulk(w, nil)
+	case int:
+		writeInt(w, int64(v))
+	case int64:
+		writeInt(w, v)
+	case string:
+		writeBulk(w, []byte(v))
+	case []byte:
+		writeBulk(w, v)
+	case error:
+		writeError(w, v.Error())
+	default:
+		writeError(w, fmt.Sprintf("ERR unsupported script result %T", v))
+	}
+}
@@ -1,11 +1,13 @@
 package renderer
 
 import (
+	"context"
+	"os"
 	"testing"
 )
 
 func setupFFI(b *testing.B) Renderer {
-	r, err := NewFFIRenderer()
+	r, err := NewFFIRenderer(os.Getenv("MATHSVG_RENDERER_LIBRARY_PATH"))
 	if err != nil {
 		b.Skipf("无法加载 Rust 渲染器: %v", err)
 	}
@@ -17,7 +19,7 @@ func BenchmarkFFISimpleSequential(b *testing.B) {
 	formula := "E=mc^2"
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		if _, err := r.Render(formula); err != nil {
+		if _, err := r.Render(context.Background(), formula); err != nil {
 			b.Fatalf("渲染失败: %v", err)
 		}
 	}
@@ -29,7 +31,7 @@ func BenchmarkFFISimpleParallel(b *testing.B) {
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			if _, err := r.Render(formula); err != nil {
+			if _, err := r.Render(context.Background(), formula); err != nil {
 				b.Fatalf("渲染失败: %v", err)
 			}
 		}
@@ -41,7 +43,7 @@ func BenchmarkFFIComplexSequential(b *testing.B) {
 	formula := `\displaystyle \int_{0}^{\infty} \frac{\sin(x)}{x} e^{-x^2} \left( \sum_{k=1}^{5} \frac{x^k}{k!} \right) dx`
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		if _, err := r.Render(formula); err != nil {
+		if _, err := r.Render(context.Background(), formula); err != nil {
 			b.Fatalf("渲染失败: %v", err)
 		}
 	}
@@ -53,7 +55,7 @@ func BenchmarkFFIComplexParallel(b *testing.B) {
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			if _, err := r.Render(formula); err != nil {
+			if _, err := r.Render(context.Background(), formula); err != nil {
 				b.Fatalf("渲染失败: %v", err)
 			}
 		}
@@ -0,0 +1,74 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 以下错误与构建方式无关，统一定义在此处，方便上层按错误类型分类
+var (
+	// ErrFFINilResult 表示 Rust FFI 返回了空指针
+	ErrFFINilResult = errors.New("Rust 渲染返回空指针")
+
+	// ErrFFIMallocFailed 表示 C 字符串分配失败
+	ErrFFIMallocFailed = errors.New("无法为公式分配 C 字符串")
+
+	// ErrCGODisabled 表示当前构建未启用 cgo
+	ErrCGODisabled = errors.New("未启用 cgo，无法加载 Rust 渲染引擎")
+
+	// ErrLibraryOpen 表示共享库无法通过 dlopen 打开
+	ErrLibraryOpen = errors.New("无法加载 Rust 渲染共享库")
+
+	// ErrLibrarySymbol 表示共享库缺少必需的导出符号
+	ErrLibrarySymbol = errors.New("Rust 渲染共享库缺少导出符号")
+
+	// ErrLibraryClose 表示旧版本共享库卸载失败
+	ErrLibraryClose = errors.New("无法卸载 Rust 渲染共享库")
+
+	// ErrCanaryFailed 表示新版本共享库未通过金丝雀渲染
+	ErrCanaryFailed = errors.New("新版本渲染库未通过金丝雀校验")
+
+	// ErrWorkerStart 表示渲染 worker 子进程启动或加载共享库失败
+	ErrWorkerStart = errors.New("渲染 worker 启动失败")
+
+	// ErrWorkerCrashed 表示渲染 worker 在处理请求时崩溃，只影响当前请求
+	ErrWorkerCrashed = errors.New("渲染 worker 崩溃")
+
+	// ErrWorkerProtocol 表示 worker 返回了无法解析的响应帧
+	ErrWorkerProtocol = errors.New("渲染 worker 响应格式错误")
+
+	// ErrPoolClosed 表示 worker 池已关闭
+	ErrPoolClosed = errors.New("渲染 worker 池已关闭")
+
+	// ErrRenderTimeout 表示渲染在截止时间前未完成或请求已取消
+	ErrRenderTimeout = errors.New("渲染超时")
+
+	// ErrOverloaded 表示渲染队列已满，请求被直接拒绝
+	ErrOverloaded = errors.New("渲染队列已满")
+
+	// ErrQueueTimeout 表示请求在队列中等待超过时限
+	ErrQueueTimeout = errors.New("渲染排队超时")
+)
+
+// timeoutError 将 ctx 的错误包装为 ErrRenderTimeout，同时保留原始原因
+func timeoutError(cause error) error {
+	return fmt.Errorf("%w: %w", ErrRenderTimeout, cause)
+}
+
+// RenderError 携带 Rust 渲染器报告的失败原因与位置，行列从 1 开始，0 表示未知
+type RenderError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *RenderError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s（第 %d 行第 %d 列）", e.Message, e.Line, e.Column)
+	case e.Column > 0:
+		return fmt.Sprintf("%s（第 %d 列）", e.Message, e.Column)
+	default:
+		return e.Message
+	}
+}
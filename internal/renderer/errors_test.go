@@ -0,0 +1,16 @@
+package renderer
+
+import "testing"
+
+func TestRenderError_Error(t *testing.T) {
+	cases := map[string]*RenderError{
+		`undefined control sequence \foo（第 1 行第 12 列）`: {Message: `undefined control sequence \foo`, Line: 1, Column: 12},
+		`missing }（第 5 列）`:                             {Message: "missing }", Column: 5},
+		`stack overflow`:                               {Message: "stack overflow"},
+	}
+	for want, err := range cases {
+		if got := err.Error(); got != want {
+			t.Fatalf("错误文本不符合预期: got=%s want=%s", got, want)
+		}
+	}
+}
@@ -3,46 +3,222 @@
 package renderer
 
 /*
-#cgo darwin LDFLAGS: -L${SRCDIR}/../../../Rust渲染 -lformula -Wl,-rpath,${SRCDIR}/../../../Rust渲染
-#cgo linux  LDFLAGS: -L${SRCDIR}/../../../Rust渲染 -lformula -Wl,-rpath,${SRCDIR}/../../../Rust渲染
+#cgo linux LDFLAGS: -ldl
 #include <stdlib.h>
+#include <stdint.h>
+#include <dlfcn.h>
 
-char* render_svg(const char* formula);
-void  free_svg(char* ptr);
+// formula_error 由扩展接口填充，line/column 从 1 开始，0 表示未知
+typedef struct {
+	char*    message;
+	uint32_t line;
+	uint32_t column;
+} formula_error;
+
+typedef char* (*render_svg_fn)(const char*);
+typedef void  (*free_svg_fn)(char*);
+typedef char* (*render_svg_ex_fn)(const char*, formula_error*);
+typedef void  (*free_formula_error_fn)(formula_error*);
+
+// dlerror 的状态与线程绑定，因此打开与查找都在同一次 C 调用内取回错误信息
+static void* open_library(const char* path, const char** err) {
+	void* handle = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (handle == NULL) {
+		*err = dlerror();
+	}
+	return handle;
+}
+
+static void* lookup_symbol(void* handle, const char* name, const char** err) {
+	dlerror();
+	void* sym = dlsym(handle, name);
+	if (sym == NULL) {
+		*err = dlerror();
+	}
+	return sym;
+}
+
+static char* call_render_svg(void* fn, const char* formula) {
+	return ((render_svg_fn)fn)(formula);
+}
+
+static void call_free_svg(void* fn, char* ptr) {
+	((free_svg_fn)fn)(ptr);
+}
+
+static char* call_render_svg_ex(void* fn, const char* formula, formula_error* err) {
+	return ((render_svg_ex_fn)fn)(formula, err);
+}
+
+static void call_free_formula_error(void* fn, formula_error* err) {
+	((free_formula_error_fn)fn)(err);
+}
 */
 import "C"
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
 	"unsafe"
 )
 
-// ErrFFINilResult 表示 Rust FFI 返回了空指针
-var ErrFFINilResult = errors.New("Rust 渲染返回空指针")
+// ffiRenderer 通过 dlopen 加载的 Rust 共享库渲染公式
+type ffiRenderer struct {
+	path             string
+	handle           unsafe.Pointer
+	renderSVG        unsafe.Pointer
+	freeSVG          unsafe.Pointer
+	renderSVGEx      unsafe.Pointer
+	freeFormulaError unsafe.Pointer
 
-// ErrFFIMallocFailed 表示 C 字符串分配失败
-var ErrFFIMallocFailed = errors.New("无法为公式分配 C 字符串")
+	// calls 统计仍在 C 侧执行的调用（包括超时后被放弃的），卸载前必须等待其归零
+	calls sync.WaitGroup
+}
 
-type ffiRenderer struct{}
+// NewFFIRenderer 在运行时加载指定路径的 Rust 共享库，路径为空时按系统默认规则查找 libformula
+func NewFFIRenderer(libraryPath string) (Renderer, error) {
+	if libraryPath == "" {
+		libraryPath = defaultLibraryName()
+	}
+
+	cpath := C.CString(libraryPath)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr *C.char
+	handle := C.open_library(cpath, &cerr)
+	if handle == nil {
+		// dlerror 的信息已包含库路径
+		return nil, fmt.Errorf("%w: %s", ErrLibraryOpen, C.GoString(cerr))
+	}
 
-// NewFFIRenderer 创建基于 Rust 共享库的渲染器
-func NewFFIRenderer() (Renderer, error) {
-	return &ffiRenderer{}, nil
+	r := &ffiRenderer{path: libraryPath, handle: handle}
+	var err error
+	if r.renderSVG, err = r.symbol("render_svg"); err == nil {
+		r.freeSVG, err = r.symbol("free_svg")
+	}
+	if err != nil {
+		C.dlclose(handle)
+		return nil, err
+	}
+
+	// 扩展接口可选，两个符号需同时存在才启用
+	renderEx, errEx := r.symbol("render_svg_ex")
+	freeErr, errFree := r.symbol("free_formula_error")
+	if errEx == nil && errFree == nil {
+		r.renderSVGEx, r.freeFormulaError = renderEx, freeErr
+	}
+	return r, nil
 }
 
-// Render 调用 Rust 的 render_svg 生成真实 SVG
-func (r *ffiRenderer) Render(tex string) (string, error) {
+// symbol 查找必需或可选的导出符号
+func (r *ffiRenderer) symbol(name string) (unsafe.Pointer, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	var cerr *C.char
+	sym := C.lookup_symbol(r.handle, cname, &cerr)
+	if sym == nil {
+		return nil, fmt.Errorf("%w: %s 缺少 %s: %s", ErrLibrarySymbol, r.path, name, C.GoString(cerr))
+	}
+	return sym, nil
+}
+
+// Render 调用 Rust 的 render_svg 生成真实 SVG。cgo 调用无法中断，
+// 超时后请求协程立即返回，C 侧调用在后台完成并释放结果
+func (r *ffiRenderer) Render(ctx context.Context, tex string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", timeoutError(err)
+	}
+	// 在启动调用前计数，保证 Close 不会先于后台调用卸载共享库
+	r.calls.Add(1)
+	// 没有截止时间时直接调用，省去协程切换
+	if ctx.Done() == nil {
+		defer r.calls.Done()
+		return r.call(tex)
+	}
+
+	type result struct {
+		svg string
+		err error
+	}
+	done := make(chan result, 1)
+	// 超时返回后调用仍占用调度器的槽位，直到 C 侧真正结束
+	release := holdSlot(ctx)
+	go func() {
+		defer release()
+		defer r.calls.Done()
+		svg, err := r.call(tex)
+		done <- result{svg: svg, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.svg, res.err
+	case <-ctx.Done():
+		return "", timeoutError(ctx.Err())
+	}
+}
+
+// call 同步执行一次 C 调用，调用方负责维护 calls 计数
+func (r *ffiRenderer) call(tex string) (string, error) {
 	cstr := C.CString(tex)
 	if cstr == nil {
 		return "", ErrFFIMallocFailed
 	}
 	defer C.free(unsafe.Pointer(cstr))
 
-	out := C.render_svg(cstr)
+	if r.renderSVGEx != nil {
+		return r.renderExtended(cstr)
+	}
+
+	out := C.call_render_svg(r.renderSVG, cstr)
 	if out == nil {
 		return "", ErrFFINilResult
 	}
-	defer C.free_svg(out)
+	defer C.call_free_svg(r.freeSVG, out)
 
 	return C.GoString(out), nil
 }
+
+// renderExtended 调用 render_svg_ex，失败时将 Rust 侧的错误信息转换为 RenderError
+func (r *ffiRenderer) renderExtended(cstr *C.char) (string, error) {
+	var cerr C.formula_error
+	out := C.call_render_svg_ex(r.renderSVGEx, cstr, &cerr)
+	if out == nil {
+		if cerr.message == nil {
+			return "", ErrFFINilResult
+		}
+		defer C.call_free_formula_error(r.freeFormulaError, &cerr)
+		return "", &RenderError{
+			Message: C.GoString(cerr.message),
+			Line:    int(cerr.line),
+			Column:  int(cerr.column),
+		}
+	}
+	defer C.call_free_svg(r.freeSVG, out)
+
+	return C.GoString(out), nil
+}
+
+// Close 等待所有 C 侧调用结束后卸载共享库，调用方需保证不再发起新的渲染
+func (r *ffiRenderer) Close() error {
+	if r.handle == nil {
+		return nil
+	}
+	r.calls.Wait()
+	if C.dlclose(r.handle) != 0 {
+		return fmt.Errorf("%w: %s", ErrLibraryClose, r.path)
+	}
+	r.handle = nil
+	return nil
+}
+
+// defaultLibraryName 返回当前平台共享库的默认文件名
+func defaultLibraryName() string {
+	if runtime.GOOS == "darwin" {
+		return "libformula.dylib"
+	}
+	return "libformula.so"
+}
@@ -0,0 +1,24 @@
+//go:build cgo && linux
+
+package renderer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFFIRenderer_MissingLibrary(t *testing.T) {
+	_, err := NewFFIRenderer(filepath.Join(t.TempDir(), "libformula.so"))
+	if !errors.Is(err, ErrLibraryOpen) {
+		t.Fatalf("不存在的共享库应返回 ErrLibraryOpen，实际: %v", err)
+	}
+}
+
+func TestNewFFIRenderer_MissingSymbol(t *testing.T) {
+	// libc 一定可以加载，但不会导出 render_svg
+	_, err := NewFFIRenderer("libc.so.6")
+	if !errors.Is(err, ErrLibrarySymbol) {
+		t.Fatalf("缺少导出符号应返回 ErrLibrarySymbol，实际: %v", err)
+	}
+}
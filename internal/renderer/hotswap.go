@@ -0,0 +1,403 @@
+package renderer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+// 渲染引擎类型，写入健康检查便于确认当前是否为真实渲染
+const (
+	EngineStub     = "stub"
+	EngineFFI      = "ffi"
+	EngineIsolated = "isolated"
+)
+
+// Versioned 由能报告当前渲染库版本的渲染器实现，版本变化时缓存键随之切换
+type Versioned interface {
+	Version() string
+}
+
+// 无法对共享库文件计算摘要时使用的版本号
+const (
+	VersionStub    = "stub"
+	VersionDefault = "default"
+)
+
+// canaryTimeout 是整组金丝雀公式的渲染时限
+const canaryTimeout = 10 * time.Second
+
+// 渲染模式：进程内直接调用，或在受监管的 worker 子进程中调用
+const (
+	ModeInProcess = "inprocess"
+	ModeIsolated  = "isolated"
+)
+
+// ReloadStatus 描述热更新的当前状态，供 /health 输出
+type ReloadStatus struct {
+	Engine      string    `json:"engine"`
+	Version     string    `json:"version"`
+	LibraryPath string    `json:"library_path"`
+	Generation  uint64    `json:"generation"`
+	LoadedAt    time.Time `json:"loaded_at"`
+	Draining    int       `json:"draining"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// generation 是某个版本的渲染器及其进行中的调用计数
+type generation struct {
+	renderer Renderer
+	engine   string
+	version  string
+	path     string
+	id       uint64
+	loadedAt time.Time
+	inflight sync.WaitGroup
+}
+
+// HotSwap 包装可热更新的渲染器：新版本通过金丝雀校验后原子替换，旧版本排空后卸载
+type HotSwap struct {
+	path      string
+	canaries  []string
+	debounce  time.Duration
+	logger    *zap.Logger
+	engine string
+	// copyOf 将共享库复制为快照副本并返回清理函数；计算版本与加载都基于同一份副本，
+	// 避免两次读取之间文件被替换导致版本号与实际加载的库不符
+	copyOf    func(path string) (string, func(), error)
+	load      func(library string) (Renderer, error)
+	versionOf func(library string) (string, error)
+
+	mu       sync.RWMutex
+	current  *generation
+	draining int
+
+	// reloadMu 保证同一时间只有一次重载，lastAttempt/lastErr 受其保护并由 mu 读取
+	reloadMu    sync.Mutex
+	lastAttempt time.Time
+	lastErr     string
+	watcher     *fsnotify.Watcher
+}
+
+// NewHotSwap 创建热更新包装器，初始为占位渲染器，需调用 Reload 加载共享库
+func NewHotSwap(cfg config.Renderer, logger *zap.Logger) *HotSwap {
+	s := &HotSwap{
+		path:      cfg.LibraryPath,
+		canaries:  cfg.CanaryFormulas,
+		debounce:  cfg.WatchDebounce,
+		logger:    logger,
+		engine:    EngineFFI,
+		copyOf:    copyLibrary,
+		load:      NewFFIRenderer,
+		versionOf: libraryVersion,
+		current:   &generation{renderer: NewStub(), engine: EngineStub, version: VersionStub, loadedAt: time.Now()},
+	}
+	if cfg.Mode == ModeIsolated {
+		s.engine = EngineIsolated
+		// worker 池会再复制一份副本供重启的 worker 使用，这里的副本在加载后即可删除
+		s.load = func(library string) (Renderer, error) {
+			return NewProcessPool(library, cfg.Workers, logger)
+		}
+	}
+	return s
+}
+
+// Render 使用当前版本渲染；替换发生后进行中的调用仍在旧版本上完成
+func (s *HotSwap) Render(ctx context.Context, tex string) (string, error) {
+	s.mu.RLock()
+	gen := s.current
+	gen.inflight.Add(1)
+	s.mu.RUnlock()
+	defer gen.inflight.Done()
+
+	return gen.renderer.Render(ctx, tex)
+}
+
+// Reload 加载指定路径（为空时沿用当前路径）的共享库，金丝雀通过后替换当前版本
+func (s *HotSwap) Reload(path string) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if path == "" {
+		path = s.path
+	}
+	err := s.reload(path)
+
+	s.mu.Lock()
+	s.lastAttempt = time.Now()
+	s.lastErr = ""
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.logger.Warn("渲染库热更新失败，继续使用旧版本", zap.String("library_path", path), zap.Error(err))
+	}
+	return err
+}
+
+func (s *HotSwap) reload(path string) error {
+	library, cleanup, err := s.copyOf(path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	version, err := s.versionOf(library)
+	if err != nil {
+		return err
+	}
+
+	// 内容未变化（如 Broadcast 触发的本进程监听事件）时不重复加载，也不提升代数
+	s.mu.RLock()
+	unchanged := s.current.engine != EngineStub && s.current.version == version && s.current.path == path
+	s.mu.RUnlock()
+	if unchanged {
+		s.logger.Info("渲染库版本未变化，跳过热更新", zap.String("library_path", path), zap.String("version", version))
+		return nil
+	}
+
+	next, err := s.load(library)
+	if err != nil {
+		if path != "" {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return err
+	}
+	if err := s.canary(next); err != nil {
+		closeRenderer(next)
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.current
+	s.current = &generation{renderer: next, engine: s.engine, version: version, path: path, id: old.id + 1, loadedAt: time.Now()}
+	s.draining++
+	s.mu.Unlock()
+
+	if path != s.path {
+		s.path = path
+		s.watchDir(path)
+	}
+	s.logger.Info("渲染库热更新成功", zap.String("library_path", path), zap.String("version", version), zap.Uint64("generation", old.id+1))
+
+	// 新请求已切到新版本，旧版本在调用排空后再卸载
+	go func() {
+		old.inflight.Wait()
+		closeRenderer(old.renderer)
+		s.mu.Lock()
+		s.draining--
+		s.mu.Unlock()
+		if old.engine != EngineStub {
+			s.logger.Info("旧版本渲染库已卸载", zap.String("library_path", old.path), zap.Uint64("generation", old.id))
+		}
+	}()
+	return nil
+}
+
+// canary 用一组固定公式验证新版本，任何一条失败或超时都拒绝替换
+func (s *HotSwap) canary(r Renderer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), canaryTimeout)
+	defer cancel()
+
+	for _, tex := range s.canaries {
+		svg, err := r.Render(ctx, tex)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCanaryFailed, tex, err)
+		}
+		if !strings.Contains(svg, "<svg") {
+			return fmt.Errorf("%w: %s: 输出不是 SVG", ErrCanaryFailed, tex)
+		}
+	}
+	return nil
+}
+
+// Status 返回当前版本与最近一次重载的结果
+func (s *HotSwap) Status() ReloadStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return ReloadStatus{
+		Engine:      s.current.engine,
+		Version:     s.current.version,
+		LibraryPath: s.current.path,
+		Generation:  s.current.id,
+		LoadedAt:    s.current.loadedAt,
+		Draining:    s.draining,
+		LastAttempt: s.lastAttempt,
+		LastError:   s.lastErr,
+	}
+}
+
+// Version 返回当前渲染库的版本：共享库文件内容摘要的前 12 位
+func (s *HotSwap) Version() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.version
+}
+
+// Close 在停机时卸载当前版本，调用方需保证已没有新的渲染请求
+func (s *HotSwap) Close() error {
+	s.mu.RLock()
+	gen := s.current
+	s.mu.RUnlock()
+
+	gen.inflight.Wait()
+	closeRenderer(gen.renderer)
+	return nil
+}
+
+// Watch 监听共享库文件变化并自动重载，直到 ctx 结束
+func (s *HotSwap) Watch(ctx context.Context) error {
+	if s.path == "" {
+		return fmt.Errorf("未配置 renderer.library_path，无法监听共享库变化")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// 监听所在目录而非文件本身，兼容 mv 覆盖等替换方式
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	s.watcher = watcher
+
+	go s.watchLoop(ctx, watcher)
+	return nil
+}
+
+func (s *HotSwap) watchLoop(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer func() { _ = watcher.Close() }()
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// Chmod 对应 Broadcast 更新修改时间，版本未变化的进程（包括发起通知的进程）会跳过重载
+			if !event.Has(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Chmod) || !s.isLibrary(event.Name) {
+				continue
+			}
+			// 复制大文件会触发多次写事件，等待文件稳定后再加载
+			timer.Reset(s.debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Warn("共享库监听出错", zap.Error(err))
+		case <-timer.C:
+			s.logger.Info("检测到共享库变化，开始热更新", zap.String("library_path", s.currentPath()))
+			_ = s.Reload("")
+		}
+	}
+}
+
+// Broadcast 更新共享库文件的修改时间，监听该文件的所有进程（包括 prefork 子进程）随之各自重载
+func (s *HotSwap) Broadcast() error {
+	path := s.currentPath()
+	if path == "" {
+		return fmt.Errorf("未配置 renderer.library_path，无法通知其他进程重载")
+	}
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// watchDir 在重载到新目录后追加监听，需在持有 reloadMu 时调用
+func (s *HotSwap) watchDir(path string) {
+	if s.watcher == nil || path == "" {
+		return
+	}
+	if err := s.watcher.Add(filepath.Dir(path)); err != nil {
+		s.logger.Warn("共享库目录监听失败", zap.String("library_path", path), zap.Error(err))
+	}
+}
+
+func (s *HotSwap) isLibrary(name string) bool {
+	return filepath.Clean(name) == filepath.Clean(s.currentPath())
+}
+
+func (s *HotSwap) currentPath() string {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.path
+}
+
+// copyLibrary 复制共享库的快照副本供加载：dlopen 会按路径复用已加载的库，
+// 且原地覆盖正在映射的文件会导致旧版本崩溃。加载后文件已映射进内存，副本可以立即删除；
+// 未配置路径时由 dlopen 按系统规则查找，不复制
+func copyLibrary(path string) (string, func(), error) {
+	if path == "" {
+		return "", func() {}, nil
+	}
+	snapshot, err := snapshotLibrary(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return snapshot, func() { _ = os.Remove(snapshot) }, nil
+}
+
+// snapshotLibrary 将共享库复制为唯一的临时文件，返回副本路径
+func snapshotLibrary(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLibraryOpen, err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "mathsvg-formula-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// libraryVersion 以共享库（快照副本）的 SHA-256 摘要作为版本号，内容不变则版本不变；
+// 未配置路径时由 dlopen 按系统规则查找，无法计算摘要
+func libraryVersion(path string) (string, error) {
+	if path == "" {
+		return VersionDefault, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLibraryOpen, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLibraryOpen, err)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+func closeRenderer(r Renderer) {
+	if closer, ok := r.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
@@ -0,0 +1,219 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mathsvg/internal/config"
+)
+
+// fakeLibrary 模拟一个版本的共享库，渲染 slow 时阻塞，并记录是否已卸载
+type fakeLibrary struct {
+	version string
+	broken  bool
+	block   chan struct{}
+	closed  atomic.Bool
+}
+
+func (f *fakeLibrary) Render(ctx context.Context, tex string) (string, error) {
+	if tex == "slow" {
+		<-f.block
+	}
+	if f.broken {
+		return "", errors.New("模拟渲染失败")
+	}
+	return "<svg>" + f.version + ":" + tex + "</svg>", nil
+}
+
+func (f *fakeLibrary) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+func newTestHotSwap(libs map[string]*fakeLibrary) *HotSwap {
+	s := NewHotSwap(config.Renderer{LibraryPath: "v1", CanaryFormulas: []string{"x"}}, zap.NewNop())
+	s.copyOf = func(path string) (string, func(), error) { return path, func() {}, nil }
+	s.load = func(path string) (Renderer, error) {
+		lib, ok := libs[path]
+		if !ok {
+			return nil, ErrLibraryOpen
+		}
+		return lib, nil
+	}
+	s.versionOf = func(path string) (string, error) { return path, nil }
+	return s
+}
+
+func TestHotSwap_CanaryFailureKeepsOld(t *testing.T) {
+	v1, v2 := &fakeLibrary{version: "v1"}, &fakeLibrary{version: "v2", broken: true}
+	s := newTestHotSwap(map[string]*fakeLibrary{"v1": v1, "v2": v2})
+	if err := s.Reload(""); err != nil {
+		t.Fatalf("首次加载失败: %v", err)
+	}
+
+	if err := s.Reload("v2"); !errors.Is(err, ErrCanaryFailed) {
+		t.Fatalf("金丝雀失败应返回 ErrCanaryFailed，实际: %v", err)
+	}
+	if !v2.closed.Load() {
+		t.Fatal("未通过校验的新版本应被卸载")
+	}
+	status := s.Status()
+	if status.Generation != 1 || status.LibraryPath != "v1" || status.Version != "v1" || !strings.Contains(status.LastError, "金丝雀") {
+		t.Fatalf("状态应保留旧版本并记录失败原因: %+v", status)
+	}
+	if svg, _ := s.Render(context.Background(), "y"); svg != "<svg>v1:y</svg>" {
+		t.Fatalf("应继续使用旧版本渲染: %s", svg)
+	}
+}
+
+func TestHotSwap_DrainsOldGeneration(t *testing.T) {
+	v1 := &fakeLibrary{version: "v1", block: make(chan struct{})}
+	v2 := &fakeLibrary{version: "v2"}
+	s := newTestHotSwap(map[string]*fakeLibrary{"v1": v1, "v2": v2})
+	if err := s.Reload(""); err != nil {
+		t.Fatalf("首次加载失败: %v", err)
+	}
+
+	// 让一次旧版本调用停在渲染中
+	done := make(chan string)
+	go func() {
+		svg, _ := s.Render(context.Background(), "slow")
+		done <- svg
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := s.Reload("v2"); err != nil {
+		t.Fatalf("热更新失败: %v", err)
+	}
+	if svg, _ := s.Render(context.Background(), "y"); svg != "<svg>v2:y</svg>" {
+		t.Fatalf("新请求应使用新版本: %s", svg)
+	}
+	if v1.closed.Load() {
+		t.Fatal("旧版本仍有进行中的调用，不应被卸载")
+	}
+
+	close(v1.block)
+	if svg := <-done; svg != "<svg>v1:slow</svg>" {
+		t.Fatalf("进行中的调用应在旧版本上完成: %s", svg)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !v1.closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("旧版本排空后应被卸载")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHotSwap_SkipsUnchangedVersion(t *testing.T) {
+	v1 := &fakeLibrary{version: "v1"}
+	s := newTestHotSwap(map[string]*fakeLibrary{"v1": v1})
+	loads := 0
+	load := s.load
+	s.load = func(path string) (Renderer, error) {
+		loads++
+		return load(path)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Reload(""); err != nil {
+			t.Fatalf("第 %d 次加载失败: %v", i+1, err)
+		}
+	}
+	if status := s.Status(); loads != 1 || status.Generation != 1 || status.LastError != "" {
+		t.Fatalf("版本与路径未变化时不应重复加载: loads=%d %+v", loads, status)
+	}
+}
+
+func TestHotSwap_VersionFromLoadedCopy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "libformula.so")
+	if err := os.WriteFile(path, []byte("lib v1"), 0o644); err != nil {
+		t.Fatalf("创建共享库文件失败: %v", err)
+	}
+	s := NewHotSwap(config.Renderer{LibraryPath: path}, zap.NewNop())
+	s.load = func(library string) (Renderer, error) {
+		// 模拟复制副本之后、加载期间原文件被替换
+		if err := os.WriteFile(path, []byte("lib v2"), 0o644); err != nil {
+			t.Fatalf("替换共享库文件失败: %v", err)
+		}
+		raw, _ := os.ReadFile(library)
+		return &fakeLibrary{version: string(raw)}, nil
+	}
+	if err := s.Reload(""); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+
+	copied := filepath.Join(t.TempDir(), "copy.so")
+	_ = os.WriteFile(copied, []byte("lib v1"), 0o644)
+	want, _ := libraryVersion(copied)
+	if status := s.Status(); status.Version != want {
+		t.Fatalf("版本号应取自实际加载的副本: %s，期望 %s", status.Version, want)
+	}
+	if svg, _ := s.Render(context.Background(), "x"); svg != "<svg>lib v1:x</svg>" {
+		t.Fatalf("应加载替换前的副本: %s", svg)
+	}
+}
+
+func TestHotSwap_BroadcastReloadsWatchers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "libformula.so")
+	if err := os.WriteFile(path, []byte("lib v1"), 0o644); err != nil {
+		t.Fatalf("创建共享库文件失败: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 两个包装器模拟 prefork 的两个子进程，各自监听同一个共享库
+	swaps := make([]*HotSwap, 2)
+	var loads [2]atomic.Int32
+	for i := range swaps {
+		s, counter := NewHotSwap(config.Renderer{LibraryPath: path, WatchDebounce: 10 * time.Millisecond}, zap.NewNop()), &loads[i]
+		s.load = func(library string) (Renderer, error) {
+			counter.Add(1)
+			raw, _ := os.ReadFile(library)
+			return &fakeLibrary{version: string(raw)}, nil
+		}
+		if err := s.Reload(""); err != nil {
+			t.Fatalf("首次加载失败: %v", err)
+		}
+		swaps[i] = s
+	}
+	// 在开始监听前替换文件，模拟某个子进程错过了替换事件
+	if err := os.WriteFile(path, []byte("lib v2"), 0o644); err != nil {
+		t.Fatalf("替换共享库文件失败: %v", err)
+	}
+	for _, s := range swaps {
+		if err := s.Watch(ctx); err != nil {
+			t.Fatalf("监听共享库失败: %v", err)
+		}
+	}
+
+	// 管理接口先在本进程重载，再通知其他进程
+	if err := swaps[0].Reload(""); err != nil {
+		t.Fatalf("重载失败: %v", err)
+	}
+	if err := swaps[0].Broadcast(); err != nil {
+		t.Fatalf("通知重载失败: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for swaps[1].Status().Generation < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("其他监听共享库的进程应重载: %+v", swaps[1].Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 本进程收到自己触发的事件时版本未变，不应再次加载
+	time.Sleep(50 * time.Millisecond)
+	if status := swaps[0].Status(); status.Generation != 2 || loads[0].Load() != 2 {
+		t.Fatalf("发起通知的进程不应重复加载: loads=%d %+v", loads[0].Load(), status)
+	}
+	if swaps[0].Version() != swaps[1].Version() {
+		t.Fatalf("各进程应加载同一版本: %s %s", swaps[0].Version(), swaps[1].Version())
+	}
+}
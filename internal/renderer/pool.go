@@ -0,0 +1,311 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// workerStopTimeout 是关闭管道后等待 worker 自行退出的时间，超时则强制结束
+	workerStopTimeout = 2 * time.Second
+
+	minRespawnBackoff = 100 * time.Millisecond
+	maxRespawnBackoff = 5 * time.Second
+)
+
+// ProcessPool 在一组受监管的子进程中调用 Rust 共享库，worker 崩溃只会让当前请求失败
+type ProcessPool struct {
+	executable string
+	library    string
+	snapshot   string
+	logger     *zap.Logger
+
+	idle chan *worker
+	done chan struct{}
+
+	mu      sync.Mutex
+	workers map[*worker]struct{}
+	nextID  int
+	closed  bool
+}
+
+// worker 表示一个渲染子进程及其请求、响应管道
+type worker struct {
+	id        int
+	cmd       *exec.Cmd
+	requests  *os.File
+	responses *os.File
+	reader    *bufio.Reader
+	exited    chan struct{}
+	exitErr   error
+}
+
+// NewProcessPool 启动 size 个 worker 子进程加载指定共享库，任一 worker 启动失败都会返回错误
+func NewProcessPool(libraryPath string, size int, logger *zap.Logger) (*ProcessPool, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWorkerStart, err)
+	}
+
+	// 与进程内模式一致，worker 加载快照而非原文件，避免原地覆盖影响运行中的 worker
+	library, snapshot := libraryPath, ""
+	if libraryPath != "" {
+		if snapshot, err = snapshotLibrary(libraryPath); err != nil {
+			return nil, err
+		}
+		library = snapshot
+	}
+
+	pool, err := newProcessPool(executable, library, size, logger)
+	if err != nil {
+		if snapshot != "" {
+			_ = os.Remove(snapshot)
+		}
+		return nil, err
+	}
+	pool.snapshot = snapshot
+	return pool, nil
+}
+
+func newProcessPool(executable, library string, size int, logger *zap.Logger) (*ProcessPool, error) {
+	if size <= 0 {
+		size = 1
+	}
+	p := &ProcessPool{
+		executable: executable,
+		library:    library,
+		logger:     logger,
+		idle:       make(chan *worker, size),
+		done:       make(chan struct{}),
+		workers:    make(map[*worker]struct{}, size),
+	}
+	for i := 0; i < size; i++ {
+		w, err := p.spawn()
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.idle <- w
+	}
+	return p, nil
+}
+
+// Render 取一个空闲 worker 渲染；worker 崩溃或超时被结束时在后台重启，不影响其他请求
+func (p *ProcessPool) Render(ctx context.Context, tex string) (string, error) {
+	var w *worker
+	select {
+	case w = <-p.idle:
+	case <-p.done:
+		return "", ErrPoolClosed
+	case <-ctx.Done():
+		return "", timeoutError(ctx.Err())
+	}
+
+	// 超时后直接结束 worker 进程，阻塞中的管道读取随之返回
+	stop := context.AfterFunc(ctx, w.kill)
+	svg, err := w.call(tex)
+	if !stop() {
+		p.logger.Warn("渲染超时，已结束 worker", zap.Int("worker", w.id), zap.Error(ctx.Err()))
+		p.discard(w)
+		go p.respawn()
+		if err == nil {
+			return svg, nil
+		}
+		return "", timeoutError(ctx.Err())
+	}
+
+	if errors.Is(err, ErrWorkerCrashed) || errors.Is(err, ErrWorkerProtocol) {
+		p.logger.Warn("渲染 worker 异常，准备重启", zap.Int("worker", w.id), zap.Error(err))
+		p.discard(w)
+		go p.respawn()
+		return "", err
+	}
+	p.release(w)
+	return svg, err
+}
+
+// Close 关闭所有 worker 并清理共享库快照，调用方需保证已没有进行中的渲染
+func (p *ProcessPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	workers := make([]*worker, 0, len(p.workers))
+	for w := range p.workers {
+		workers = append(workers, w)
+	}
+	p.workers = nil
+	p.mu.Unlock()
+
+	for _, w := range workers {
+		w.stop()
+	}
+	if p.snapshot != "" {
+		_ = os.Remove(p.snapshot)
+	}
+	return nil
+}
+
+// spawn 启动一个 worker 并等待其握手帧，确认共享库加载成功
+func (p *ProcessPool) spawn() (*worker, error) {
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWorkerStart, err)
+	}
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return nil, fmt.Errorf("%w: %v", ErrWorkerStart, err)
+	}
+
+	cmd := exec.Command(p.executable)
+	cmd.Env = append(os.Environ(), WorkerEnv+"="+p.library)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// ExtraFiles 依次对应子进程的 fd 3、fd 4
+	cmd.ExtraFiles = []*os.File{reqR, respW}
+	err = cmd.Start()
+	// 子进程已持有副本，父进程只保留自己需要的一端
+	reqR.Close()
+	respW.Close()
+	if err != nil {
+		reqW.Close()
+		respR.Close()
+		return nil, fmt.Errorf("%w: %v", ErrWorkerStart, err)
+	}
+
+	p.mu.Lock()
+	p.nextID++
+	w := &worker{
+		id:        p.nextID,
+		cmd:       cmd,
+		requests:  reqW,
+		responses: respR,
+		reader:    bufio.NewReader(respR),
+		exited:    make(chan struct{}),
+	}
+	p.mu.Unlock()
+	go func() {
+		w.exitErr = cmd.Wait()
+		close(w.exited)
+	}()
+
+	frame, err := readFrame(w.reader)
+	if err == nil {
+		_, err = decodeResult(frame)
+	} else {
+		err = w.crashed(err)
+	}
+	if err != nil {
+		w.stop()
+		return nil, fmt.Errorf("%w: %v", ErrWorkerStart, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		w.stop()
+		return nil, ErrPoolClosed
+	}
+	p.workers[w] = struct{}{}
+	return w, nil
+}
+
+// respawn 按指数退避重启 worker，直到成功或池被关闭
+func (p *ProcessPool) respawn() {
+	backoff := minRespawnBackoff
+	for {
+		w, err := p.spawn()
+		if err == nil {
+			p.release(w)
+			return
+		}
+		if errors.Is(err, ErrPoolClosed) {
+			return
+		}
+		p.logger.Error("渲染 worker 重启失败", zap.Duration("backoff", backoff), zap.Error(err))
+
+		select {
+		case <-p.done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRespawnBackoff {
+			backoff = maxRespawnBackoff
+		}
+	}
+}
+
+// release 将 worker 放回空闲队列，池已关闭时直接停止
+func (p *ProcessPool) release(w *worker) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		w.stop()
+		return
+	}
+	p.idle <- w
+}
+
+// discard 从池中移除异常的 worker
+func (p *ProcessPool) discard(w *worker) {
+	p.mu.Lock()
+	delete(p.workers, w)
+	p.mu.Unlock()
+	w.stop()
+}
+
+// call 发送一次渲染请求并等待响应，管道读写失败视为 worker 崩溃
+func (w *worker) call(tex string) (string, error) {
+	if err := writeFrame(w.requests, []byte(tex)); err != nil {
+		return "", w.crashed(err)
+	}
+	frame, err := readFrame(w.reader)
+	if err != nil {
+		if errors.Is(err, errFrameTooLarge) {
+			return "", fmt.Errorf("%w: %v", ErrWorkerProtocol, err)
+		}
+		return "", w.crashed(err)
+	}
+	return decodeResult(frame)
+}
+
+// crashed 尽量取得 worker 的退出状态，生成便于排查的错误
+func (w *worker) crashed(ioErr error) error {
+	select {
+	case <-w.exited:
+		return fmt.Errorf("%w: worker %d %v", ErrWorkerCrashed, w.id, w.exitErr)
+	case <-time.After(100 * time.Millisecond):
+		return fmt.Errorf("%w: worker %d %v", ErrWorkerCrashed, w.id, ioErr)
+	}
+}
+
+// kill 强制结束 worker 进程
+func (w *worker) kill() {
+	_ = w.cmd.Process.Kill()
+}
+
+// stop 关闭请求管道让 worker 自行退出，超时后强制结束
+func (w *worker) stop() {
+	_ = w.requests.Close()
+	select {
+	case <-w.exited:
+	case <-time.After(workerStopTimeout):
+		w.kill()
+		<-w.exited
+	}
+	_ = w.responses.Close()
+}
@@ -0,0 +1,106 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TestMain 让测试二进制在带有 WorkerEnv 时充当 worker 子进程
+func TestMain(m *testing.M) {
+	if library, ok := os.LookupEnv(WorkerEnv); ok {
+		if library == "broken" {
+			os.Exit(serveWorkerProcess(nil, errors.New("模拟加载失败")))
+		}
+		os.Exit(serveWorkerProcess(crashRenderer{}, nil))
+	}
+	os.Exit(m.Run())
+}
+
+// crashRenderer 遇到 crash 时直接退出进程模拟共享库段错误，遇到 hang 时永不返回
+type crashRenderer struct{}
+
+func (crashRenderer) Render(ctx context.Context, tex string) (string, error) {
+	switch tex {
+	case "crash":
+		os.Exit(139)
+	case "hang":
+		time.Sleep(time.Hour)
+	case "bad":
+		return "", &RenderError{Message: "undefined control sequence", Line: 1, Column: 4}
+	case "nil":
+		return "", ErrFFINilResult
+	}
+	return "<svg>" + tex + "</svg>", nil
+}
+
+func newTestPool(t *testing.T, library string, size int) (*ProcessPool, error) {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("无法定位测试二进制: %v", err)
+	}
+	return newProcessPool(executable, library, size, zap.NewNop())
+}
+
+func TestProcessPool_RestartsCrashedWorker(t *testing.T) {
+	pool, err := newTestPool(t, "ok", 1)
+	if err != nil {
+		t.Fatalf("worker 池启动失败: %v", err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	if svg, err := pool.Render(ctx, "x"); err != nil || svg != "<svg>x</svg>" {
+		t.Fatalf("渲染结果不符合预期: %q %v", svg, err)
+	}
+	if _, err := pool.Render(ctx, "crash"); !errors.Is(err, ErrWorkerCrashed) {
+		t.Fatalf("worker 崩溃应返回 ErrWorkerCrashed，实际: %v", err)
+	}
+	// 唯一的 worker 崩溃后应被重启，后续请求继续可用
+	if svg, err := pool.Render(ctx, "y"); err != nil || svg != "<svg>y</svg>" {
+		t.Fatalf("重启后渲染结果不符合预期: %q %v", svg, err)
+	}
+
+	var renderErr *RenderError
+	if _, err := pool.Render(ctx, "bad"); !errors.As(err, &renderErr) || renderErr.Column != 4 {
+		t.Fatalf("渲染错误应跨进程保留位置: %v", err)
+	}
+	if _, err := pool.Render(ctx, "nil"); !errors.Is(err, ErrFFINilResult) {
+		t.Fatalf("空指针错误应还原为 ErrFFINilResult，实际: %v", err)
+	}
+}
+
+func TestProcessPool_StartFailure(t *testing.T) {
+	_, err := newTestPool(t, "broken", 2)
+	if !errors.Is(err, ErrWorkerStart) || !strings.Contains(err.Error(), "模拟加载失败") {
+		t.Fatalf("加载失败应返回 ErrWorkerStart 与原因，实际: %v", err)
+	}
+}
+
+func TestProcessPool_TimeoutKillsWorker(t *testing.T) {
+	pool, err := newTestPool(t, "ok", 1)
+	if err != nil {
+		t.Fatalf("worker 池启动失败: %v", err)
+	}
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := pool.Render(ctx, "hang"); !errors.Is(err, ErrRenderTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("超时应返回 ErrRenderTimeout，实际: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("超时后应尽快返回，实际耗时 %v", elapsed)
+	}
+
+	if svg, err := pool.Render(context.Background(), "x"); err != nil || svg != "<svg>x</svg>" {
+		t.Fatalf("被结束的 worker 应被重启: %q %v", svg, err)
+	}
+}
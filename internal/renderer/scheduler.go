@@ -0,0 +1,204 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mathsvg/internal/config"
+)
+
+// OverloadError 表示请求被准入控制拒绝，RetryAfter 提示调用方稍后重试的时间
+type OverloadError struct {
+	Reason     error
+	RetryAfter time.Duration
+}
+
+func (e *OverloadError) Error() string {
+	return fmt.Sprintf("%v，请 %v 后重试", e.Reason, e.RetryAfter)
+}
+
+func (e *OverloadError) Unwrap() error {
+	return e.Reason
+}
+
+// SchedulerStats 描述调度器的排队与拒绝情况，供 /health 输出
+type SchedulerStats struct {
+	MaxConcurrency int   `json:"max_concurrency"`
+	Running        int64 `json:"running"`
+	// Detached 为请求已超时返回、底层调用仍在执行而继续占用的槽位数，计入 Running
+	Detached      int64   `json:"detached"`
+	MaxQueue      int     `json:"max_queue"`
+	QueueDepth    int64   `json:"queue_depth"`
+	Admitted      uint64  `json:"admitted"`
+	Rejected      uint64  `json:"rejected"`
+	QueueTimeouts uint64  `json:"queue_timeouts"`
+	AvgWaitMS     float64 `json:"avg_wait_ms"`
+	MaxWaitMS     float64 `json:"max_wait_ms"`
+}
+
+// Scheduler 限制同时进行的渲染数量：超出并发的请求进入有界队列等待，
+// 队列已满或等待超时则立即拒绝，避免突发的缓存未命中占满所有线程
+type Scheduler struct {
+	next         Renderer
+	slots        chan struct{}
+	maxQueue     int
+	queueTimeout time.Duration
+	retryAfter   time.Duration
+
+	running       atomic.Int64
+	detached      atomic.Int64
+	waiting       atomic.Int64
+	admitted      atomic.Uint64
+	rejected      atomic.Uint64
+	queueTimeouts atomic.Uint64
+	totalWait     atomic.Int64
+	maxWait       atomic.Int64
+}
+
+// NewScheduler 根据配置包装渲染器，max_concurrency 为 0 时取 CPU 核数
+func NewScheduler(next Renderer, cfg config.Renderer) *Scheduler {
+	concurrency := cfg.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	return &Scheduler{
+		next:         next,
+		slots:        make(chan struct{}, concurrency),
+		maxQueue:     cfg.MaxQueue,
+		queueTimeout: cfg.QueueTimeout,
+		retryAfter:   cfg.RetryAfter,
+	}
+}
+
+// Render 取得执行槽位后调用下游渲染器。下游超时返回后仍在后台执行的调用（进程内 cgo）
+// 通过 holdSlot 继续占用槽位，直到真正结束，避免失控的渲染绕过并发上限
+func (s *Scheduler) Render(ctx context.Context, tex string) (string, error) {
+	if err := s.acquire(ctx); err != nil {
+		return "", err
+	}
+	s.running.Add(1)
+	hold := &slotHold{scheduler: s}
+	hold.refs.Store(1)
+
+	svg, err := s.next.Render(context.WithValue(ctx, slotHoldKey{}, hold), tex)
+	// 在放下请求自身的引用前标记，保证释放时能看到标记
+	if hold.refs.Load() > 1 {
+		hold.detached.Store(true)
+		s.detached.Add(1)
+	}
+	hold.done()
+	return svg, err
+}
+
+// slotHold 是一个执行槽位的引用计数：请求本身持有一份，后台继续执行的底层调用各持有一份
+type slotHold struct {
+	scheduler *Scheduler
+	refs      atomic.Int32
+	detached  atomic.Bool
+}
+
+type slotHoldKey struct{}
+
+// holdSlot 由超时后仍在后台执行的渲染器在启动调用前获取，返回的函数须在底层调用结束时调用；
+// ctx 不经过调度器时返回空操作
+func holdSlot(ctx context.Context) func() {
+	hold, ok := ctx.Value(slotHoldKey{}).(*slotHold)
+	if !ok {
+		return func() {}
+	}
+	hold.refs.Add(1)
+	var once sync.Once
+	return func() { once.Do(hold.done) }
+}
+
+// done 放下一份引用，全部放下后释放槽位
+func (h *slotHold) done() {
+	if h.refs.Add(-1) != 0 {
+		return
+	}
+	s := h.scheduler
+	if h.detached.Load() {
+		s.detached.Add(-1)
+	}
+	s.running.Add(-1)
+	<-s.slots
+}
+
+// Version 透传下游渲染器的版本，下游不区分版本时返回空
+func (s *Scheduler) Version() string {
+	if v, ok := s.next.(Versioned); ok {
+		return v.Version()
+	}
+	return ""
+}
+
+// acquire 优先直接占用空闲槽位，否则在队列中等待，受队列长度、排队时限与 ctx 三重约束
+func (s *Scheduler) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		s.admit(0)
+		return nil
+	default:
+	}
+
+	if s.waiting.Add(1) > int64(s.maxQueue) {
+		s.waiting.Add(-1)
+		s.rejected.Add(1)
+		return &OverloadError{Reason: ErrOverloaded, RetryAfter: s.retryAfter}
+	}
+	defer s.waiting.Add(-1)
+
+	var expired <-chan time.Time
+	if s.queueTimeout > 0 {
+		timer := time.NewTimer(s.queueTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	start := time.Now()
+	select {
+	case s.slots <- struct{}{}:
+		s.admit(time.Since(start))
+		return nil
+	case <-expired:
+		s.queueTimeouts.Add(1)
+		return &OverloadError{Reason: ErrQueueTimeout, RetryAfter: s.retryAfter}
+	case <-ctx.Done():
+		return timeoutError(ctx.Err())
+	}
+}
+
+// admit 记录一次准入及其排队耗时
+func (s *Scheduler) admit(wait time.Duration) {
+	s.admitted.Add(1)
+	s.totalWait.Add(int64(wait))
+	for {
+		current := s.maxWait.Load()
+		if int64(wait) <= current || s.maxWait.CompareAndSwap(current, int64(wait)) {
+			return
+		}
+	}
+}
+
+// Stats 返回调度器的实时指标
+func (s *Scheduler) Stats() SchedulerStats {
+	stats := SchedulerStats{
+		MaxConcurrency: cap(s.slots),
+		Running:        s.running.Load(),
+		Detached:       s.detached.Load(),
+		MaxQueue:       s.maxQueue,
+		QueueDepth:     s.waiting.Load(),
+		Admitted:       s.admitted.Load(),
+		Rejected:       s.rejected.Load(),
+		QueueTimeouts:  s.queueTimeouts.Load(),
+		MaxWaitMS:      float64(s.maxWait.Load()) / float64(time.Millisecond),
+	}
+	if stats.Admitted > 0 {
+		stats.AvgWaitMS = float64(s.totalWait.Load()) / float64(stats.Admitted) / float64(time.Millisecond)
+	}
+	return stats
+}
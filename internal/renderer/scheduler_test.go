@@ -0,0 +1,121 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mathsvg/internal/config"
+)
+
+func TestScheduler_ShedsWhenQueueFull(t *testing.T) {
+	lib := &fakeLibrary{version: "v1", block: make(chan struct{})}
+	s := NewScheduler(lib, config.Renderer{MaxConcurrency: 1, MaxQueue: 1, QueueTimeout: 50 * time.Millisecond, RetryAfter: 2 * time.Second})
+	ctx := context.Background()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Render(ctx, "slow")
+		done <- err
+	}()
+	for s.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 队列容量为 1：第二个请求排队直至超时，排队期间第三个请求被直接拒绝
+	queued := make(chan error, 1)
+	go func() {
+		_, err := s.Render(ctx, "x")
+		queued <- err
+	}()
+	for s.Stats().QueueDepth == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var overload *OverloadError
+	if _, err := s.Render(ctx, "y"); !errors.Is(err, ErrOverloaded) || !errors.As(err, &overload) || overload.RetryAfter != 2*time.Second {
+		t.Fatalf("队列已满应返回 ErrOverloaded，实际: %v", err)
+	}
+	if err := <-queued; !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("排队超时应返回 ErrQueueTimeout，实际: %v", err)
+	}
+
+	close(lib.block)
+	if err := <-done; err != nil {
+		t.Fatalf("占用槽位的请求应正常完成: %v", err)
+	}
+	stats := s.Stats()
+	if stats.Admitted != 1 || stats.Rejected != 1 || stats.QueueTimeouts != 1 || stats.Running != 0 || stats.QueueDepth != 0 {
+		t.Fatalf("调度指标不符合预期: %+v", stats)
+	}
+}
+
+func TestScheduler_QueuedRequestRespectsContext(t *testing.T) {
+	lib := &fakeLibrary{version: "v1", block: make(chan struct{})}
+	defer close(lib.block)
+	s := NewScheduler(lib, config.Renderer{MaxConcurrency: 1, MaxQueue: 4, QueueTimeout: time.Second})
+
+	go func() { _, _ = s.Render(context.Background(), "slow") }()
+	for s.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Render(ctx, "x"); !errors.Is(err, ErrRenderTimeout) {
+		t.Fatalf("请求截止时间早于排队时限时应返回 ErrRenderTimeout，实际: %v", err)
+	}
+}
+
+// detachedLibrary 模拟进程内 cgo 调用：超时后立即返回，底层调用在后台继续执行直到 block 关闭
+type detachedLibrary struct {
+	block chan struct{}
+}
+
+func (d *detachedLibrary) Render(ctx context.Context, tex string) (string, error) {
+	release := holdSlot(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer release()
+		<-d.block
+		close(done)
+	}()
+	select {
+	case <-done:
+		return "<svg/>", nil
+	case <-ctx.Done():
+		return "", timeoutError(ctx.Err())
+	}
+}
+
+func TestScheduler_DetachedCallKeepsSlot(t *testing.T) {
+	lib := &detachedLibrary{block: make(chan struct{})}
+	s := NewScheduler(lib, config.Renderer{MaxConcurrency: 1, MaxQueue: 1, QueueTimeout: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Render(ctx, "x"); !errors.Is(err, ErrRenderTimeout) {
+		t.Fatalf("应返回渲染超时，实际: %v", err)
+	}
+	if stats := s.Stats(); stats.Running != 1 || stats.Detached != 1 {
+		t.Fatalf("超时后仍在执行的调用应继续占用槽位: %+v", stats)
+	}
+	if _, err := s.Render(context.Background(), "y"); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("槽位被后台调用占用时应排队超时，实际: %v", err)
+	}
+
+	close(lib.block)
+	deadline := time.Now().Add(time.Second)
+	for s.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("底层调用结束后应释放槽位: %+v", s.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := s.Render(context.Background(), "z"); err != nil {
+		t.Fatalf("槽位释放后应能正常渲染: %v", err)
+	}
+	if stats := s.Stats(); stats.Detached != 0 {
+		t.Fatalf("后台调用计数应归零: %+v", stats)
+	}
+}
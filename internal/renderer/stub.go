@@ -1,15 +1,16 @@
 package renderer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"html"
 	"strings"
 )
 
-// Renderer 定义渲染器应当具备的最小接口
+// Renderer 定义渲染器应当具备的最小接口，实现需在 ctx 结束时尽快返回 ErrRenderTimeout
 type Renderer interface {
-	Render(tex string) (string, error)
+	Render(ctx context.Context, tex string) (string, error)
 }
 
 // Stub 用于在 Rust 模块尚未接入时提供占位 SVG
@@ -27,7 +28,10 @@ func NewStub() *Stub {
 }
 
 // Render 将 LaTeX 文本包裹在提示信息中，方便前端联调
-func (s *Stub) Render(tex string) (string, error) {
+func (s *Stub) Render(ctx context.Context, tex string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", timeoutError(err)
+	}
 	trimmed := strings.TrimSpace(tex)
 	if trimmed == "" {
 		return "", errors.New("公式内容为空")
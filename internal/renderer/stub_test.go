@@ -1,13 +1,14 @@
 package renderer
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
 
 func TestStub_Render(t *testing.T) {
 	renderer := NewStub()
-	svg, err := renderer.Render(`E=mc^2`)
+	svg, err := renderer.Render(context.Background(), `E=mc^2`)
 	if err != nil {
 		t.Fatalf("期望成功却返回错误: %v", err)
 	}
@@ -24,7 +25,7 @@ func TestStub_Render(t *testing.T) {
 
 func TestStub_Render_Empty(t *testing.T) {
 	renderer := NewStub()
-	if _, err := renderer.Render("   "); err == nil {
+	if _, err := renderer.Render(context.Background(), "   "); err == nil {
 		t.Fatal("空字符串应该返回错误")
 	}
 }
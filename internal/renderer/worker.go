@@ -0,0 +1,157 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WorkerEnv 标记进程以渲染 worker 模式运行，值为需要加载的共享库路径
+const WorkerEnv = "MATHSVG_WORKER_LIBRARY"
+
+// worker 与主进程通过 fd 3（请求）与 fd 4（响应）通信，stdout/stderr 留给共享库自身输出
+const (
+	workerRequestFD  = 3
+	workerResponseFD = 4
+
+	// maxFrameSize 限制单帧大小，防止损坏的长度字段导致超大分配
+	maxFrameSize = 64 << 20
+)
+
+// 响应帧首字节表示结果类型，错误类型与包内哨兵错误一一对应
+const (
+	frameOK byte = iota
+	frameRenderError
+	frameNilResult
+	frameMallocFailed
+	frameError
+)
+
+var errFrameTooLarge = errors.New("worker 帧长度超出限制")
+
+// writeFrame 写入 4 字节大端长度前缀与负载
+func writeFrame(w io.Writer, payload []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame 读取一帧完整负载
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// encodeResult 将渲染结果编码为响应帧
+func encodeResult(svg string, err error) []byte {
+	var renderErr *RenderError
+	switch {
+	case err == nil:
+		return append([]byte{frameOK}, svg...)
+	case errors.As(err, &renderErr):
+		buf := make([]byte, 9, 9+len(renderErr.Message))
+		buf[0] = frameRenderError
+		binary.BigEndian.PutUint32(buf[1:5], uint32(renderErr.Line))
+		binary.BigEndian.PutUint32(buf[5:9], uint32(renderErr.Column))
+		return append(buf, renderErr.Message...)
+	case errors.Is(err, ErrFFINilResult):
+		return []byte{frameNilResult}
+	case errors.Is(err, ErrFFIMallocFailed):
+		return []byte{frameMallocFailed}
+	default:
+		return append([]byte{frameError}, err.Error()...)
+	}
+}
+
+// decodeResult 将响应帧还原为渲染结果
+func decodeResult(frame []byte) (string, error) {
+	if len(frame) == 0 {
+		return "", fmt.Errorf("%w: 空响应帧", ErrWorkerProtocol)
+	}
+	body := frame[1:]
+	switch frame[0] {
+	case frameOK:
+		return string(body), nil
+	case frameRenderError:
+		if len(body) < 8 {
+			return "", fmt.Errorf("%w: 渲染错误帧过短", ErrWorkerProtocol)
+		}
+		return "", &RenderError{
+			Line:    int(binary.BigEndian.Uint32(body[0:4])),
+			Column:  int(binary.BigEndian.Uint32(body[4:8])),
+			Message: string(body[8:]),
+		}
+	case frameNilResult:
+		return "", ErrFFINilResult
+	case frameMallocFailed:
+		return "", ErrFFIMallocFailed
+	case frameError:
+		return "", errors.New(string(body))
+	default:
+		return "", fmt.Errorf("%w: 未知帧类型 %d", ErrWorkerProtocol, frame[0])
+	}
+}
+
+// ServeWorker 循环读取请求帧并调用渲染器，直到主进程关闭管道
+func ServeWorker(r Renderer, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	for {
+		frame, err := readFrame(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 超时由主进程结束 worker 实现，这里无需截止时间
+		svg, renderErr := r.Render(context.Background(), string(frame))
+		if err := writeFrame(writer, encodeResult(svg, renderErr)); err != nil {
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// RunWorker 是 worker 子进程的入口，返回进程退出码
+func RunWorker(libraryPath string) int {
+	r, err := NewFFIRenderer(libraryPath)
+	return serveWorkerProcess(r, err)
+}
+
+// serveWorkerProcess 先回传加载结果作为握手帧，加载成功后再进入请求循环
+func serveWorkerProcess(r Renderer, loadErr error) int {
+	in := os.NewFile(workerRequestFD, "worker-request")
+	out := os.NewFile(workerResponseFD, "worker-response")
+	if err := writeFrame(out, encodeResult("", loadErr)); err != nil || loadErr != nil {
+		return 1
+	}
+
+	if err := ServeWorker(r, in, out); err != nil {
+		fmt.Fprintf(os.Stderr, "渲染 worker 异常退出: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"go.uber.org/zap"
+
+	"mathsvg/internal/api"
+	"mathsvg/internal/config"
+)
+
+// AdminServer 是管理接口的独立监听，不启用 prefork，与对外渲染服务隔离
+type AdminServer struct {
+	app     *fiber.App
+	cfg     config.Admin
+	logger  *zap.Logger
+	handler *api.AdminHandler
+}
+
+// NewAdminServer 创建管理服务并挂载缓存管理接口
+func NewAdminServer(cfg config.Admin, logger *zap.Logger, adminHandler *api.AdminHandler) *AdminServer {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		BodyLimit:             cfg.MaxRequestBodyMB * 1024 * 1024,
+		ServerHeader:          "MathSVG-Go",
+	})
+	app.Use(assignRequestID)
+	app.Use(recover.New())
+	adminHandler.Register(app)
+
+	return &AdminServer{
+		app:     app,
+		cfg:     cfg,
+		logger:  logger,
+		handler: adminHandler,
+	}
+}
+
+// Start 启动管理服务
+func (s *AdminServer) Start() error {
+	s.logger.Info("管理服务启动", zap.String("listen", s.cfg.Address))
+	return s.app.Listen(s.cfg.Address)
+}
+
+// Shutdown 取消进行中的预热并停止监听
+func (s *AdminServer) Shutdown(ctx context.Context) error {
+	s.handler.Close()
+	return s.app.ShutdownWithContext(ctx)
+}
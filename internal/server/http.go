@@ -33,12 +33,7 @@ func NewHTTPServer(cfg config.Server, logger *zap.Logger, renderHandler *api.Ren
 		ServerHeader:          "MathSVG-Go",
 	})
 
-	app.Use(func(c *fiber.Ctx) error {
-		requestID := uuid.NewString()
-		c.Set("X-Request-ID", requestID)
-		c.Locals(ctxkeys.RequestID, requestID)
-		return c.Next()
-	})
+	app.Use(assignRequestID)
 
 	app.Use(recover.New())
 	if cfg.EnableCompression {
@@ -60,6 +55,14 @@ func NewHTTPServer(cfg config.Server, logger *zap.Logger, renderHandler *api.Ren
 	}
 }
 
+// assignRequestID 为每个请求生成 ID，写入响应头并供日志关联
+func assignRequestID(c *fiber.Ctx) error {
+	requestID := uuid.NewString()
+	c.Set("X-Request-ID", requestID)
+	c.Locals(ctxkeys.RequestID, requestID)
+	return c.Next()
+}
+
 // Start 启动 HTTP 服务
 func (s *HTTPServer) Start() error {
 	s.logger.Info("HTTP 服务启动", zap.String("listen", s.cfg.Address))
@@ -0,0 +1,42 @@
+package svgutil
+
+import (
+	"strings"
+)
+
+// Accessibility 描述注入到 SVG 中的无障碍信息
+type Accessibility struct {
+	Label string // aria-label 与 <title> 的内容
+	Desc  string // <desc> 的内容，通常包含原始 TeX 与另一种语言的朗读文本
+}
+
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// InjectAccessibility 为根元素加上 role、aria-label，并插入 <title> 与 <desc>
+func InjectAccessibility(svg string, info Accessibility) (string, error) {
+	root, err := parseRoot(svg)
+	if err != nil {
+		return "", err
+	}
+
+	root.set("role", "img")
+	// 属性值原样保存，这里先转义，双引号由 rootTag 负责
+	root.set("aria-label", xmlTextEscaper.Replace(info.Label))
+
+	var children strings.Builder
+	children.WriteString("<title>")
+	children.WriteString(xmlTextEscaper.Replace(info.Label))
+	children.WriteString("</title>")
+	if info.Desc != "" {
+		children.WriteString("<desc>")
+		children.WriteString(xmlTextEscaper.Replace(info.Desc))
+		children.WriteString("</desc>")
+	}
+
+	// 自闭合的根元素需要展开后才能插入子元素
+	if root.self {
+		root.self = false
+		return svg[:root.start] + root.String() + children.String() + "</svg>" + svg[root.end:], nil
+	}
+	return svg[:root.start] + root.String() + children.String() + svg[root.end:], nil
+}
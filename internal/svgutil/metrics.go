@@ -0,0 +1,78 @@
+package svgutil
+
+import (
+	"math"
+	"strings"
+)
+
+// Metrics 描述 SVG 在排版时需要的尺寸信息，单位均为 em
+type Metrics struct {
+	WidthEm  float64 `json:"width_em"`
+	HeightEm float64 `json:"height_em"`
+	DepthEm  float64 `json:"depth_em"` // 基线以下的深度，用于与正文对齐
+	ViewBox  string  `json:"viewBox"`
+}
+
+// Measure 解析 SVG 根元素得到宽高与基线深度，非 em 单位按 fontSize 像素换算
+func Measure(svg string, fontSize float64) (Metrics, error) {
+	root, err := parseRoot(svg)
+	if err != nil {
+		return Metrics{}, err
+	}
+	if fontSize <= 0 {
+		fontSize = defaultFontSize
+	}
+
+	var m Metrics
+	m.ViewBox, _ = root.get("viewBox")
+	_, minY, _, vbHeight, hasViewBox := parseViewBox(root)
+
+	widthPx, heightPx, err := pixelSize(root, fontSize)
+	if err != nil {
+		return Metrics{}, err
+	}
+	m.WidthEm = roundEm(widthPx / fontSize)
+	m.HeightEm = roundEm(heightPx / fontSize)
+
+	// 优先采用 vertical-align 声明的偏移，否则按 viewBox 中基线 y=0 的约定推算
+	if depth, ok := verticalAlignDepth(root, fontSize); ok {
+		m.DepthEm = roundEm(depth)
+	} else if hasViewBox && vbHeight > 0 && minY < 0 && minY+vbHeight > 0 {
+		m.DepthEm = roundEm((minY + vbHeight) / vbHeight * m.HeightEm)
+	}
+	return m, nil
+}
+
+// verticalAlignDepth 从 style 中读取 vertical-align，负值表示基线以下的深度
+func verticalAlignDepth(root *rootTag, fontSize float64) (float64, bool) {
+	style, ok := root.get("style")
+	if !ok {
+		return 0, false
+	}
+	for _, decl := range strings.Split(style, ";") {
+		name, value, found := strings.Cut(decl, ":")
+		if !found || strings.TrimSpace(name) != "vertical-align" {
+			continue
+		}
+		offset, unit, ok := splitLength(value)
+		if !ok {
+			return 0, false
+		}
+		switch strings.ToLower(unit) {
+		case "em":
+		case "ex":
+			offset /= 2
+		case "px", "":
+			offset /= fontSize
+		default:
+			return 0, false
+		}
+		return -offset, true
+	}
+	return 0, false
+}
+
+// roundEm 保留四位小数，既满足排版精度又避免浮点噪声
+func roundEm(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,158 @@
+package svgutil
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+const (
+	// cssDPI 是 CSS 像素对应的基准分辨率
+	cssDPI = 96.0
+	// defaultFontSize 是未指定字号时 em 单位对应的像素值
+	defaultFontSize = 16.0
+	// maxRasterSide 限制单边像素数，避免超大图片耗尽内存
+	maxRasterSide = 8192
+)
+
+var (
+	// ErrRasterTooLarge 表示按请求分辨率栅格化后的尺寸超过上限
+	ErrRasterTooLarge = errors.New("PNG 尺寸超过允许上限")
+	// ErrRasterSize 表示无法从 SVG 中推算出有效尺寸
+	ErrRasterSize = errors.New("无法确定 SVG 的像素尺寸")
+)
+
+// RasterOptions 描述 SVG 转 PNG 时的输出参数
+type RasterOptions struct {
+	DPI        float64     // 输出分辨率，0 表示 96
+	FontSize   float64     // em 单位换算使用的像素字号，0 表示 16
+	Foreground string      // currentColor 的替换值，空字符串表示黑色
+	Background color.Color // 背景色，nil 表示透明
+}
+
+// RasterizePNG 使用纯 Go 的矢量光栅器将 SVG 转为 PNG
+func RasterizePNG(svg string, opts RasterOptions) ([]byte, error) {
+	root, err := parseRoot(svg)
+	if err != nil {
+		return nil, err
+	}
+
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = cssDPI
+	}
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = defaultFontSize
+	}
+
+	widthPx, heightPx, err := pixelSize(root, fontSize)
+	if err != nil {
+		return nil, err
+	}
+	factor := dpi / cssDPI
+	w := int(math.Ceil(widthPx * factor))
+	h := int(math.Ceil(heightPx * factor))
+	if w <= 0 || h <= 0 {
+		return nil, ErrRasterSize
+	}
+	if w > maxRasterSide || h > maxRasterSide {
+		return nil, ErrRasterTooLarge
+	}
+
+	// oksvg 只认识无单位的宽高，这里统一改写为像素值并补齐 viewBox
+	if _, _, _, _, ok := parseViewBox(root); !ok {
+		root.set("viewBox", "0 0 "+formatNumber(widthPx)+" "+formatNumber(heightPx))
+	}
+	root.set("width", formatNumber(widthPx))
+	root.set("height", formatNumber(heightPx))
+	svg = root.replace(svg)
+
+	foreground := opts.Foreground
+	if foreground == "" {
+		foreground = "black"
+	}
+	icon, err := oksvg.ReadReplacingCurrentColor(strings.NewReader(svg), foreground, oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, err
+	}
+	icon.SetTarget(0, 0, float64(w), float64(h))
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if opts.Background != nil {
+		draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1.0)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseColor 将十六进制或 SVG 颜色名解析为 color.Color
+func ParseColor(raw string) (color.Color, error) {
+	return oksvg.ParseSVGColor(raw)
+}
+
+// pixelSize 将根元素的 width/height 换算为 CSS 像素，缺失时回退到 viewBox
+func pixelSize(root *rootTag, fontSize float64) (float64, float64, error) {
+	_, _, vbWidth, vbHeight, hasViewBox := parseViewBox(root)
+
+	width, widthOK := lengthToPixels(root, "width", fontSize)
+	height, heightOK := lengthToPixels(root, "height", fontSize)
+	switch {
+	case widthOK && heightOK:
+		return width, height, nil
+	case widthOK && hasViewBox && vbWidth > 0:
+		return width, width * vbHeight / vbWidth, nil
+	case heightOK && hasViewBox && vbHeight > 0:
+		return height * vbWidth / vbHeight, height, nil
+	case hasViewBox:
+		return vbWidth, vbHeight, nil
+	default:
+		return 0, 0, ErrRasterSize
+	}
+}
+
+func lengthToPixels(root *rootTag, name string, fontSize float64) (float64, bool) {
+	raw, ok := root.get(name)
+	if !ok {
+		return 0, false
+	}
+	value, unit, ok := splitLength(raw)
+	if !ok {
+		return 0, false
+	}
+
+	switch strings.ToLower(unit) {
+	case "", "px":
+		return value, true
+	case "em":
+		return value * fontSize, true
+	case "ex":
+		return value * fontSize / 2, true
+	case "pt":
+		return value * cssDPI / 72, true
+	case "pc":
+		return value * cssDPI / 6, true
+	case "in":
+		return value * cssDPI, true
+	case "cm":
+		return value * cssDPI / 2.54, true
+	case "mm":
+		return value * cssDPI / 25.4, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,160 @@
+package svgutil
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoSVGRoot 表示输入中找不到 <svg> 根元素
+var ErrNoSVGRoot = errors.New("SVG 内容缺少 <svg> 根元素")
+
+// attr 保存单个属性，使用切片保持原始顺序
+type attr struct {
+	name  string
+	value string
+}
+
+// rootTag 描述 SVG 根元素的起始标签及其在原文中的位置
+type rootTag struct {
+	start int
+	end   int // 指向 '>' 之后的位置
+	attrs []attr
+	self  bool
+}
+
+// parseRoot 定位并解析 <svg ...> 起始标签，属性值中的 '>' 不会截断标签
+func parseRoot(svg string) (*rootTag, error) {
+	start := -1
+	for offset := 0; offset < len(svg); {
+		idx := strings.Index(svg[offset:], "<svg")
+		if idx < 0 {
+			break
+		}
+		pos := offset + idx
+		next := pos + len("<svg")
+		if next < len(svg) && isTagBoundary(svg[next]) {
+			start = pos
+			break
+		}
+		offset = next
+	}
+	if start < 0 {
+		return nil, ErrNoSVGRoot
+	}
+
+	tag := &rootTag{start: start}
+	i := start + len("<svg")
+	for i < len(svg) {
+		c := svg[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '>':
+			tag.end = i + 1
+			return tag, nil
+		case c == '/' && i+1 < len(svg) && svg[i+1] == '>':
+			tag.self = true
+			tag.end = i + 2
+			return tag, nil
+		default:
+			nameStart := i
+			for i < len(svg) && !isSpace(svg[i]) && svg[i] != '=' && svg[i] != '>' && svg[i] != '/' {
+				i++
+			}
+			name := svg[nameStart:i]
+			for i < len(svg) && isSpace(svg[i]) {
+				i++
+			}
+			if i >= len(svg) || svg[i] != '=' {
+				tag.attrs = append(tag.attrs, attr{name: name})
+				continue
+			}
+			i++
+			for i < len(svg) && isSpace(svg[i]) {
+				i++
+			}
+			if i >= len(svg) {
+				return nil, ErrNoSVGRoot
+			}
+			quote := svg[i]
+			if quote != '"' && quote != '\'' {
+				valueStart := i
+				for i < len(svg) && !isSpace(svg[i]) && svg[i] != '>' {
+					i++
+				}
+				tag.attrs = append(tag.attrs, attr{name: name, value: svg[valueStart:i]})
+				continue
+			}
+			closing := strings.IndexByte(svg[i+1:], quote)
+			if closing < 0 {
+				return nil, ErrNoSVGRoot
+			}
+			tag.attrs = append(tag.attrs, attr{name: name, value: svg[i+1 : i+1+closing]})
+			i += closing + 2
+		}
+	}
+	return nil, ErrNoSVGRoot
+}
+
+// get 返回属性值，第二个返回值表示属性是否存在
+func (t *rootTag) get(name string) (string, bool) {
+	for _, a := range t.attrs {
+		if a.name == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// set 覆盖已有属性，不存在时追加到末尾
+func (t *rootTag) set(name, value string) {
+	for i := range t.attrs {
+		if t.attrs[i].name == name {
+			t.attrs[i].value = value
+			return
+		}
+	}
+	t.attrs = append(t.attrs, attr{name: name, value: value})
+}
+
+// appendStyle 在 style 属性末尾追加声明
+func (t *rootTag) appendStyle(decl string) {
+	style, _ := t.get("style")
+	style = strings.TrimSpace(style)
+	if style != "" && !strings.HasSuffix(style, ";") {
+		style += ";"
+	}
+	t.set("style", style+decl)
+}
+
+// String 重新序列化起始标签，属性值统一使用双引号
+func (t *rootTag) String() string {
+	var b strings.Builder
+	b.WriteString("<svg")
+	for _, a := range t.attrs {
+		b.WriteByte(' ')
+		b.WriteString(a.name)
+		b.WriteString(`="`)
+		b.WriteString(strings.ReplaceAll(a.value, `"`, "&quot;"))
+		b.WriteByte('"')
+	}
+	if t.self {
+		b.WriteString("/>")
+	} else {
+		b.WriteByte('>')
+	}
+	return b.String()
+}
+
+// replace 将修改后的根标签写回原始 SVG
+func (t *rootTag) replace(svg string) string {
+	return svg[:t.start] + t.String() + svg[t.end:]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isTagBoundary(c byte) bool {
+	return isSpace(c) || c == '>' || c == '/'
+}
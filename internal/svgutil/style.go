@@ -0,0 +1,120 @@
+package svgutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Style 描述渲染完成后对 SVG 根元素的外观调整
+type Style struct {
+	FontSize float64 // 像素字号，0 表示沿用渲染器默认值
+	Color    string  // 前景色，空字符串表示不修改
+	Scale    float64 // 整体缩放倍数，0 或 1 表示不缩放
+}
+
+// IsZero 判断是否无需任何调整
+func (s Style) IsZero() bool {
+	return s.FontSize == 0 && s.Color == "" && (s.Scale == 0 || s.Scale == 1)
+}
+
+// ApplyStyle 按照 Style 调整 SVG 根元素的尺寸、字号与颜色
+func ApplyStyle(svg string, style Style) (string, error) {
+	if style.IsZero() {
+		return svg, nil
+	}
+
+	root, err := parseRoot(svg)
+	if err != nil {
+		return "", err
+	}
+
+	if style.Scale != 0 && style.Scale != 1 {
+		scaleRoot(root, style.Scale)
+	}
+	if style.FontSize > 0 {
+		root.appendStyle("font-size:" + formatNumber(style.FontSize) + "px")
+	}
+	if style.Color != "" {
+		// 同时设置 fill 与 color，兼容直接填充与 currentColor 两种写法
+		root.set("fill", style.Color)
+		root.appendStyle("color:" + style.Color)
+	}
+
+	return root.replace(svg), nil
+}
+
+// scaleRoot 缩放根元素的 width/height，缺失时根据 viewBox 推算
+func scaleRoot(root *rootTag, scale float64) {
+	width, hasWidth := root.get("width")
+	height, hasHeight := root.get("height")
+	if !hasWidth && !hasHeight {
+		if _, _, w, h, ok := parseViewBox(root); ok {
+			root.set("width", formatNumber(w*scale))
+			root.set("height", formatNumber(h*scale))
+		}
+		return
+	}
+	if hasWidth {
+		if scaled, ok := scaleLength(width, scale); ok {
+			root.set("width", scaled)
+		}
+	}
+	if hasHeight {
+		if scaled, ok := scaleLength(height, scale); ok {
+			root.set("height", scaled)
+		}
+	}
+}
+
+// parseViewBox 解析根元素的 viewBox 属性
+func parseViewBox(root *rootTag) (minX, minY, width, height float64, ok bool) {
+	raw, exists := root.get("viewBox")
+	if !exists {
+		return 0, 0, 0, 0, false
+	}
+	fields := strings.FieldsFunc(raw, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) != 4 {
+		return 0, 0, 0, 0, false
+	}
+	var values [4]float64
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, 0, 0, 0, false
+		}
+		values[i] = v
+	}
+	return values[0], values[1], values[2], values[3], true
+}
+
+// splitLength 将 "1.5em" 之类的长度拆分为数值与单位
+func splitLength(raw string) (float64, string, bool) {
+	raw = strings.TrimSpace(raw)
+	end := len(raw)
+	for end > 0 {
+		c := raw[end-1]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '%' {
+			end--
+			continue
+		}
+		break
+	}
+	value, err := strconv.ParseFloat(raw[:end], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return value, raw[end:], true
+}
+
+func scaleLength(raw string, scale float64) (string, bool) {
+	value, unit, ok := splitLength(raw)
+	if !ok || unit == "%" {
+		return "", false
+	}
+	return formatNumber(value*scale) + unit, true
+}
+
+// formatNumber 输出紧凑的小数表示，避免出现 1.5000000001 之类的噪声
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
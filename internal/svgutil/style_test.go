@@ -0,0 +1,88 @@
+package svgutil
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestApplyStyle_ScaleAndColor(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="2em" height="1.5em" viewBox="0 0 20 15"><path d="M0 0"/></svg>`
+	out, err := ApplyStyle(svg, Style{Scale: 2, Color: "#ff0000", FontSize: 18})
+	if err != nil {
+		t.Fatalf("调整样式失败: %v", err)
+	}
+	for _, want := range []string{`width="4em"`, `height="3em"`, `fill="#ff0000"`, `style="font-size:18px;color:#ff0000"`, `<path d="M0 0"/>`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("结果缺少 %s: %s", want, out)
+		}
+	}
+}
+
+func TestApplyStyle_ScaleFromViewBox(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 400 80"><rect/></svg>`
+	out, err := ApplyStyle(svg, Style{Scale: 0.5})
+	if err != nil {
+		t.Fatalf("调整样式失败: %v", err)
+	}
+	if !strings.Contains(out, `width="200"`) || !strings.Contains(out, `height="40"`) {
+		t.Fatalf("应根据 viewBox 推算尺寸: %s", out)
+	}
+}
+
+func TestApplyStyle_NoRoot(t *testing.T) {
+	if _, err := ApplyStyle("<div></div>", Style{Color: "red"}); err != ErrNoSVGRoot {
+		t.Fatalf("应返回 ErrNoSVGRoot，实际: %v", err)
+	}
+}
+
+func TestRasterizePNG(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="2em" height="1em" viewBox="0 0 20 10"><path d="M0 0H20V10H0Z" fill="currentColor"/></svg>`
+	out, err := RasterizePNG(svg, RasterOptions{DPI: 192, FontSize: 10})
+	if err != nil {
+		t.Fatalf("栅格化失败: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("输出不是合法 PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 20 {
+		t.Fatalf("尺寸应按 DPI 放大到 40x20，实际 %dx%d", b.Dx(), b.Dy())
+	}
+	if _, _, _, a := img.At(20, 10).RGBA(); a == 0 {
+		t.Fatal("填充区域不应透明")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="3em" height="1.2em" viewBox="0 -800 3000 1200" style="vertical-align:-0.25em"></svg>`
+	m, err := Measure(svg, 0)
+	if err != nil {
+		t.Fatalf("解析尺寸失败: %v", err)
+	}
+	if m.WidthEm != 3 || m.HeightEm != 1.2 || m.DepthEm != 0.25 || m.ViewBox != "0 -800 3000 1200" {
+		t.Fatalf("尺寸不符合预期: %+v", m)
+	}
+
+	noAlign := `<svg xmlns="http://www.w3.org/2000/svg" width="32px" height="16px" viewBox="0 -750 2000 1000"></svg>`
+	m, err = Measure(noAlign, 16)
+	if err != nil {
+		t.Fatalf("解析尺寸失败: %v", err)
+	}
+	if m.WidthEm != 2 || m.HeightEm != 1 || m.DepthEm != 0.25 {
+		t.Fatalf("应根据 viewBox 推算基线: %+v", m)
+	}
+}
+
+func TestInjectAccessibility(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><path d="M0 0"/></svg>`
+	out, err := InjectAccessibility(svg, Accessibility{Label: "a less than b", Desc: "LaTeX: a<b"})
+	if err != nil {
+		t.Fatalf("注入失败: %v", err)
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10" role="img" aria-label="a less than b"><title>a less than b</title><desc>LaTeX: a&lt;b</desc><path d="M0 0"/></svg>`
+	if out != want {
+		t.Fatalf("注入结果不符合预期:\n%s", out)
+	}
+}